@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// run generates the file of the messages declared in the package of dir, after checking the directives of the
+// whole module.
+func run(dir string, output string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	root, err := findModuleRoot(dir)
+	if err != nil {
+		return err
+	}
+
+	messages, err := scanModule(root)
+	if err != nil {
+		return err
+	}
+	messages = slices.DeleteFunc(messages, func(m message) bool { return m.Dir != dir })
+	if len(messages) == 0 {
+		return fmt.Errorf("no mx directive found in %s", dir)
+	}
+
+	src, err := render(messages)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, output), src, 0o644)
+}
+
+// kindTemplateData describes how the messages of a kind are generated.
+type kindTemplateData struct {
+	TypeName string // misas type of the type names, e.g. CommandTypeName
+	Message  string // misas interface implemented, e.g. Command
+	Registry string // field of mx.MessageRegistries, e.g. Commands
+	Messages []messageTemplateData
+}
+
+type messageTemplateData struct {
+	message
+	Constant string
+}
+
+var kindTemplates = map[messageKind]kindTemplateData{
+	commandKind: {TypeName: "CommandTypeName", Message: "Command", Registry: "Commands"},
+	queryKind:   {TypeName: "QueryTypeName", Message: "Query", Registry: "Queries"},
+	eventKind:   {TypeName: "EventTypeName", Message: "Event", Registry: "Events"},
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{"lower": strings.ToLower}).Parse(`// Code generated by mxgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+)
+
+{{ range .Kinds }}
+const (
+{{- $kind := . }}
+{{- range .Messages }}
+	{{ .Constant }} misas.{{ $kind.TypeName }} = {{ printf "%q" .TypeName }}
+{{- end }}
+)
+
+{{ range .Messages }}
+func ({{ .GoType }}) TypeName() misas.{{ $kind.TypeName }} { return {{ .Constant }} }
+{{ end }}
+
+// Register{{ .Registry }} registers the {{ .Registry | lower }} of the package.
+func Register{{ .Registry }}(r *mx.MessageRegistry[misas.{{ .TypeName }}, misas.{{ .Message }}]) {
+{{- range .Messages }}
+	r.Register({{ .Constant }}, {{ .GoType }}{})
+{{- end }}
+}
+{{ end }}
+
+// RegisterMessages registers the messages of the package.
+func RegisterMessages(r mx.MessageRegistries) {
+{{- range .Kinds }}
+	Register{{ .Registry }}(r.{{ .Registry }})
+{{- end }}
+}
+`))
+
+// render generates the source of the messages of a package.
+func render(messages []message) ([]byte, error) {
+	var kinds []kindTemplateData
+	for _, kind := range messageKinds {
+		data := kindTemplates[kind]
+		for _, m := range messages {
+			if m.Kind == kind {
+				data.Messages = append(data.Messages, messageTemplateData{message: m, Constant: m.GoType + "TypeName"})
+			}
+		}
+		if len(data.Messages) == 0 {
+			continue
+		}
+		slices.SortFunc(data.Messages, func(a, b messageTemplateData) int { return cmp.Compare(a.GoType, b.GoType) })
+		kinds = append(kinds, data)
+	}
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]any{"Package": messages[0].Package, "Kinds": kinds})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+
+	return src, nil
+}
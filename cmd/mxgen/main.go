@@ -0,0 +1,47 @@
+// Command mxgen generates the boilerplate of message types annotated with mx directives.
+//
+// A struct type is declared a command, query or event by a directive in its doc comment, followed by its type name:
+//
+//	//mx:command inventory.adjust_stock
+//	type AdjustStockCommand struct {
+//		ProductID string `json:"productId"`
+//	}
+//
+// For the annotated types of a package, mxgen generates into mx_messages_gen.go:
+//   - a type name constant, e.g. AdjustStockCommandTypeName;
+//   - the TypeName method implementing misas.Command, misas.Query or misas.Event;
+//   - RegisterCommands, RegisterQueries and RegisterEvents functions registering the types with a registry, and a
+//     RegisterMessages function registering them all with mx.MessageRegistries.
+//
+// mxgen scans every package of the enclosing module, and fails when a type name is declared more than once.
+// It is meant to be run by go generate:
+//
+//	//go:generate go run github.com/morebec/misas/cmd/mxgen
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const defaultOutput = "mx_messages_gen.go"
+
+func main() {
+	output := flag.String("output", defaultOutput, "name of the generated file, within the package directory")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mxgen [-output file] [package directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if err := run(dir, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "mxgen: %s\n", err)
+		os.Exit(1)
+	}
+}
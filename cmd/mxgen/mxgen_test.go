@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeModule writes the files of a module into a temporary directory, and returns its path.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/shop\n\ngo 1.24\n"
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	return root
+}
+
+func TestRun(t *testing.T) {
+	t.Run("GIVEN annotated structs WHEN generating THEN should generate type names and registrations", func(t *testing.T) {
+		root := writeModule(t, map[string]string{
+			"inventory/messages.go": `package inventory
+
+// AdjustStockCommand adjusts the stock of a product.
+//
+//mx:command inventory.adjust_stock
+type AdjustStockCommand struct{}
+
+type (
+	//mx:event inventory.stock_adjusted
+	stockAdjustedEvent struct{}
+)
+`,
+		})
+
+		require.NoError(t, run(filepath.Join(root, "inventory"), defaultOutput))
+
+		src, err := os.ReadFile(filepath.Join(root, "inventory", defaultOutput))
+		require.NoError(t, err)
+		require.Equal(t, `// Code generated by mxgen. DO NOT EDIT.
+
+package inventory
+
+import (
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+)
+
+const (
+	AdjustStockCommandTypeName misas.CommandTypeName = "inventory.adjust_stock"
+)
+
+func (AdjustStockCommand) TypeName() misas.CommandTypeName { return AdjustStockCommandTypeName }
+
+// RegisterCommands registers the commands of the package.
+func RegisterCommands(r *mx.MessageRegistry[misas.CommandTypeName, misas.Command]) {
+	r.Register(AdjustStockCommandTypeName, AdjustStockCommand{})
+}
+
+const (
+	stockAdjustedEventTypeName misas.EventTypeName = "inventory.stock_adjusted"
+)
+
+func (stockAdjustedEvent) TypeName() misas.EventTypeName { return stockAdjustedEventTypeName }
+
+// RegisterEvents registers the events of the package.
+func RegisterEvents(r *mx.MessageRegistry[misas.EventTypeName, misas.Event]) {
+	r.Register(stockAdjustedEventTypeName, stockAdjustedEvent{})
+}
+
+// RegisterMessages registers the messages of the package.
+func RegisterMessages(r mx.MessageRegistries) {
+	RegisterCommands(r.Commands)
+	RegisterEvents(r.Events)
+}
+`, string(src))
+
+		// the generated file is ignored when generating again
+		require.NoError(t, run(filepath.Join(root, "inventory"), defaultOutput))
+	})
+
+	t.Run("GIVEN type name declared in another package WHEN generating THEN should fail", func(t *testing.T) {
+		root := writeModule(t, map[string]string{
+			"inventory/messages.go": "package inventory\n\n//mx:command inventory.adjust_stock\ntype AdjustStockCommand struct{}\n",
+			"sales/messages.go":     "package sales\n\n//mx:event inventory.adjust_stock\ntype StockAdjusted struct{}\n",
+		})
+
+		err := run(filepath.Join(root, "sales"), defaultOutput)
+		require.ErrorContains(t, err, `type name "inventory.adjust_stock" of event StockAdjusted is already declared by command AdjustStockCommand`)
+		require.NoFileExists(t, filepath.Join(root, "sales", defaultOutput))
+	})
+
+	t.Run("GIVEN invalid directives WHEN generating THEN should report them all", func(t *testing.T) {
+		root := writeModule(t, map[string]string{
+			"inventory/messages.go": `package inventory
+
+//mx:message inventory.unknown
+type Unknown struct{}
+
+//mx:command
+type Unnamed struct{}
+
+//mx:query inventory.ids
+type IDs []string
+
+//mx:event inventory.misplaced
+func misplaced() {}
+
+//mx:command inventory.hand_written
+type HandWritten struct{}
+
+func (HandWritten) TypeName() string { return "inventory.hand_written" }
+`,
+		})
+
+		err := run(filepath.Join(root, "inventory"), defaultOutput)
+		require.ErrorContains(t, err, `unknown mx directive "mx:message"`)
+		require.ErrorContains(t, err, "mx:command directive expects a single type name")
+		require.ErrorContains(t, err, "mx directives apply to non-generic struct types, IDs is not one")
+		require.ErrorContains(t, err, "mx directive must be in the doc comment of a struct type")
+		require.ErrorContains(t, err, "type HandWritten annotated at")
+	})
+
+	t.Run("GIVEN package without directives WHEN generating THEN should fail", func(t *testing.T) {
+		root := writeModule(t, map[string]string{"inventory/doc.go": "package inventory\n"})
+
+		require.ErrorContains(t, run(filepath.Join(root, "inventory"), defaultOutput), "no mx directive found")
+	})
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const directivePrefix = "//mx:"
+
+// messageKind is the kind of message declared by a directive.
+type messageKind string
+
+const (
+	commandKind messageKind = "command"
+	queryKind   messageKind = "query"
+	eventKind   messageKind = "event"
+)
+
+var messageKinds = []messageKind{commandKind, queryKind, eventKind}
+
+// message is a type annotated with a directive.
+type message struct {
+	Kind     messageKind
+	TypeName string
+	GoType   string
+	Dir      string
+	Package  string
+	Pos      token.Position
+}
+
+// scanModule parses the non-test Go files of every package of the module rooted at root, and returns the messages
+// they declare. Nested modules, vendor and testdata directories, and directories starting with "." or "_" are skipped.
+func scanModule(root string) ([]message, error) {
+	fset := token.NewFileSet()
+	var messages []message
+	var errs []error
+	typeNameMethods := map[string]token.Position{} // keyed by directory and receiver type
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			name := d.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		found, fileErrs := scanFile(fset, f, filepath.Dir(path))
+		messages = append(messages, found...)
+		errs = append(errs, fileErrs...)
+		if !ast.IsGenerated(f) {
+			for receiver, pos := range typeNameMethodsOf(fset, f) {
+				typeNameMethods[filepath.Join(filepath.Dir(path), receiver)] = pos
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range messages {
+		if pos, declared := typeNameMethods[filepath.Join(m.Dir, m.GoType)]; declared {
+			errs = append(errs, fmt.Errorf("%s: type %s annotated at %s already declares a TypeName method", pos, m.GoType, m.Pos))
+		}
+	}
+	if err := checkDuplicates(messages); err != nil {
+		errs = append(errs, err)
+	}
+
+	return messages, errors.Join(errs...)
+}
+
+// scanFile returns the messages declared by the directives of a file. Directives must be placed in the doc comment
+// of a struct type.
+func scanFile(fset *token.FileSet, f *ast.File, dir string) ([]message, []error) {
+	var messages []message
+	var errs []error
+	consumed := map[*ast.Comment]bool{}
+
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if doc == nil {
+				continue
+			}
+
+			var directives []*ast.Comment
+			for _, c := range doc.List {
+				if strings.HasPrefix(c.Text, directivePrefix) {
+					directives = append(directives, c)
+					consumed[c] = true
+				}
+			}
+			if len(directives) == 0 {
+				continue
+			}
+			if len(directives) > 1 {
+				errs = append(errs, fmt.Errorf("%s: type %s has more than one mx directive", fset.Position(directives[1].Pos()), ts.Name.Name))
+				continue
+			}
+			if _, isStruct := ts.Type.(*ast.StructType); !isStruct || ts.TypeParams != nil {
+				errs = append(errs, fmt.Errorf("%s: mx directives apply to non-generic struct types, %s is not one", fset.Position(directives[0].Pos()), ts.Name.Name))
+				continue
+			}
+
+			kind, typeName, err := parseDirective(directives[0].Text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", fset.Position(directives[0].Pos()), err))
+				continue
+			}
+			messages = append(messages, message{
+				Kind:     kind,
+				TypeName: typeName,
+				GoType:   ts.Name.Name,
+				Dir:      dir,
+				Package:  f.Name.Name,
+				Pos:      fset.Position(directives[0].Pos()),
+			})
+		}
+	}
+
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, directivePrefix) && !consumed[c] {
+				errs = append(errs, fmt.Errorf("%s: mx directive must be in the doc comment of a struct type", fset.Position(c.Pos())))
+			}
+		}
+	}
+
+	return messages, errs
+}
+
+// typeNameMethodsOf returns the receiver types of the TypeName methods declared by a file.
+func typeNameMethodsOf(fset *token.FileSet, f *ast.File) map[string]token.Position {
+	methods := map[string]token.Position{}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil || len(fd.Recv.List) != 1 || fd.Name.Name != "TypeName" {
+			continue
+		}
+
+		receiver := fd.Recv.List[0].Type
+		if star, ok := receiver.(*ast.StarExpr); ok {
+			receiver = star.X
+		}
+		if ident, ok := receiver.(*ast.Ident); ok {
+			methods[ident.Name] = fset.Position(fd.Pos())
+		}
+	}
+
+	return methods
+}
+
+// parseDirective parses a directive such as "//mx:command inventory.adjust_stock".
+func parseDirective(text string) (messageKind, string, error) {
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), " ")
+	fields := strings.Fields(rest)
+
+	valid := false
+	for _, k := range messageKinds {
+		valid = valid || messageKind(kind) == k
+	}
+	if !valid {
+		return "", "", fmt.Errorf("unknown mx directive %q, expected one of mx:command, mx:query or mx:event", "mx:"+kind)
+	}
+	if len(fields) != 1 {
+		return "", "", fmt.Errorf("mx:%s directive expects a single type name, got %q", kind, rest)
+	}
+
+	return messageKind(kind), fields[0], nil
+}
+
+// checkDuplicates fails when a type name is declared more than once across the module, whatever the kind of messages.
+func checkDuplicates(messages []message) error {
+	var errs []error
+	declared := map[string]message{}
+	for _, m := range messages {
+		if first, exists := declared[m.TypeName]; exists {
+			errs = append(errs, fmt.Errorf("%s: type name %q of %s %s is already declared by %s %s at %s", m.Pos, m.TypeName, m.Kind, m.GoType, first.Kind, first.GoType, first.Pos))
+			continue
+		}
+		declared[m.TypeName] = m
+	}
+
+	return errors.Join(errs...)
+}
+
+// findModuleRoot returns the closest directory containing a go.mod file, starting from dir.
+func findModuleRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", fmt.Errorf("no go.mod found in %s or its parents", dir)
+		}
+		current = parent
+	}
+}
@@ -0,0 +1,14 @@
+// Command mxlint reports common mistakes in the use of misas and mx, see package mxlint for the analyzers it runs:
+//
+//	go run github.com/morebec/misas/cmd/mxlint ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"github.com/morebec/misas/mxlint"
+)
+
+func main() {
+	multichecker.Main(mxlint.Analyzers...)
+}
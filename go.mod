@@ -6,11 +6,14 @@ require (
 	github.com/logrusorgru/aurora/v4 v4.0.0
 	github.com/samber/lo v1.52.0
 	github.com/stretchr/testify v1.11.1
+	golang.org/x/tools v0.38.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/mod v0.29.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -123,6 +123,7 @@ func NewInternalErrorFrom(err error) Error {
 func (e Error) Kind() ErrorKind { return e.kind }
 func (e Error) Code() ErrorCode { return e.code }
 func (e Error) Cause() error    { return e.cause }
+func (e Error) Message() string { return e.message }
 
 func (e Error) Error() string {
 	msg := string(e.kind)
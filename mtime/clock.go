@@ -14,10 +14,10 @@ func (f ClockFunc) Now() time.Time { return f() }
 // time of the running operating system in a given time zone. if nil is passed,
 // will default to the local time zone.
 func NewRealTimeClock(tz *time.Location) ClockFunc {
-	if tz != nil {
-		time.Local = tz
+	if tz == nil {
+		return time.Now
 	}
-	return time.Now
+	return func() time.Time { return time.Now().In(tz) }
 }
 
 // ManualClock implementation of a clock that allows to manually specify the
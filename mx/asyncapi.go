@@ -0,0 +1,187 @@
+package mx
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
+)
+
+const asyncAPIVersion = "3.0.0"
+
+// AsyncAPIDocument is an AsyncAPI 3.0 document describing the messages of a system, so that other teams can
+// consume its events without reading its code.
+type AsyncAPIDocument struct {
+	AsyncAPI   string                       `json:"asyncapi"`
+	Info       AsyncAPIInfo                 `json:"info"`
+	Channels   map[string]AsyncAPIChannel   `json:"channels"`
+	Operations map[string]AsyncAPIOperation `json:"operations"`
+	Components AsyncAPIComponents           `json:"components"`
+}
+
+type AsyncAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// AsyncAPIChannel describes an event bus and the events published on it.
+type AsyncAPIChannel struct {
+	Address  string                 `json:"address"`
+	Messages map[string]AsyncAPIRef `json:"messages"`
+}
+
+// AsyncAPIOperation describes a subsystem publishing events on an event bus (send), or subscribed to it (receive).
+type AsyncAPIOperation struct {
+	Action   string        `json:"action"`
+	Channel  AsyncAPIRef   `json:"channel"`
+	Messages []AsyncAPIRef `json:"messages,omitempty"`
+	// Subsystem is the name of the subsystem performing the operation.
+	Subsystem string `json:"x-subsystem"`
+}
+
+type AsyncAPIComponents struct {
+	Messages map[string]AsyncAPIMessage `json:"messages"`
+}
+
+// AsyncAPIMessage describes a registered command, query or event.
+type AsyncAPIMessage struct {
+	Name    string      `json:"name"`
+	Payload *JSONSchema `json:"payload"`
+	// Kind is either "command", "query" or "event".
+	Kind string `json:"x-kind"`
+	// Subsystem is the name of the subsystem owning the message: the one handling a command or query,
+	// or producing an event. It is empty when no subsystem of the system declares the message.
+	Subsystem string `json:"x-subsystem,omitempty"`
+}
+
+type AsyncAPIRef struct {
+	Ref string `json:"$ref"`
+}
+
+// AsyncAPI returns the AsyncAPI document of the system. Its components list every message of the message registries
+// of the system with the JSON Schema of its payload. Its channels are the event buses of the system
+// with the events declared through BusinessSubsystemConf.ProducesEventsOn. It fails if the JSON Schema of a message
+// cannot be generated.
+func (sc *SystemConf) AsyncAPI() (AsyncAPIDocument, error) {
+	doc := AsyncAPIDocument{
+		AsyncAPI:   asyncAPIVersion,
+		Info:       AsyncAPIInfo{Title: sc.name, Version: sc.version},
+		Channels:   map[string]AsyncAPIChannel{},
+		Operations: map[string]AsyncAPIOperation{},
+		Components: AsyncAPIComponents{Messages: map[string]AsyncAPIMessage{}},
+	}
+
+	owners := map[string]string{}
+	for _, bs := range sc.businessSubsystems {
+		for tn := range bs.commandHandlers {
+			owners[string(tn)] = bs.name
+		}
+		for _, tn := range bs.producedEvents {
+			owners[string(tn)] = bs.name
+		}
+	}
+	for _, qs := range sc.querySubsystems {
+		for tn := range qs.queryHandlers {
+			owners[string(tn)] = qs.name
+		}
+	}
+
+	if err := sc.registerMessages(); err != nil {
+		return AsyncAPIDocument{}, err
+	}
+	if err := addAsyncAPIMessages(doc.Components.Messages, "command", sc.registries.Commands, owners); err != nil {
+		return AsyncAPIDocument{}, err
+	}
+	if err := addAsyncAPIMessages(doc.Components.Messages, "query", sc.registries.Queries, owners); err != nil {
+		return AsyncAPIDocument{}, err
+	}
+	if err := addAsyncAPIMessages(doc.Components.Messages, "event", sc.registries.Events, owners); err != nil {
+		return AsyncAPIDocument{}, err
+	}
+
+	for bus := range sc.eventBuses {
+		channel := AsyncAPIChannel{Address: string(bus), Messages: map[string]AsyncAPIRef{}}
+		if bus == SystemEventBusName && sc.systemEvents {
+			for _, hookName := range systemEventHookNames {
+				tn := string(SystemEventTypeName(hookName))
+				channel.Messages[tn] = asyncAPIRef("components", "messages", tn)
+			}
+		}
+		doc.Channels[string(bus)] = channel
+	}
+
+	for _, bs := range sc.businessSubsystems {
+		for bus, typeNames := range bs.publishedEvents {
+			channel, ok := doc.Channels[string(bus)]
+			if !ok {
+				continue // unknown buses are reported by the topology validation
+			}
+
+			operation := AsyncAPIOperation{Action: "send", Channel: asyncAPIRef("channels", string(bus)), Subsystem: bs.name}
+			for _, tn := range lo.Uniq(slices.Sorted(slices.Values(typeNames))) {
+				channel.Messages[string(tn)] = asyncAPIRef("components", "messages", string(tn))
+				operation.Messages = append(operation.Messages, asyncAPIRef("channels", string(bus), "messages", string(tn)))
+			}
+			doc.Operations[bs.name+".send."+string(bus)] = operation
+		}
+	}
+
+	addSubscriptions := func(subsystemName string, eventHandlers map[EventBusName][]misas.EventHandler) {
+		for _, bus := range slices.Sorted(maps.Keys(eventHandlers)) {
+			if _, ok := doc.Channels[string(bus)]; !ok {
+				continue
+			}
+			doc.Operations[subsystemName+".receive."+string(bus)] = AsyncAPIOperation{
+				Action:    "receive",
+				Channel:   asyncAPIRef("channels", string(bus)),
+				Subsystem: subsystemName,
+			}
+		}
+	}
+	for _, bs := range sc.businessSubsystems {
+		addSubscriptions(bs.name, bs.eventHandlers)
+	}
+	for _, qs := range sc.querySubsystems {
+		addSubscriptions(qs.name, qs.eventHandlers)
+	}
+
+	return doc, nil
+}
+
+// JSON renders the document as indented JSON.
+func (d AsyncAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// asyncAPIRef references a component of the document, escaping the segments of the JSON pointer.
+func asyncAPIRef(segments ...string) AsyncAPIRef {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	ref := "#"
+	for _, s := range segments {
+		ref += "/" + escaper.Replace(s)
+	}
+
+	return AsyncAPIRef{Ref: ref}
+}
+
+func addAsyncAPIMessages[TN ~string, T any](messages map[string]AsyncAPIMessage, kind string, r *MessageRegistry[TN, T], owners map[string]string) error {
+	for _, tn := range r.TypeNames() {
+		typ, _ := r.Lookup(tn)
+		payload, err := jsonSchemaOf(typ)
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON schema of %s %q: %w", kind, tn, err)
+		}
+		messages[string(tn)] = AsyncAPIMessage{
+			Name:      string(tn),
+			Payload:   payload,
+			Kind:      kind,
+			Subsystem: owners[string(tn)],
+		}
+	}
+
+	return nil
+}
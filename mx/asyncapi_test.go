@@ -0,0 +1,87 @@
+package mx_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type stockReservedEvent struct {
+	ProductID string            `json:"productId"`
+	Quantity  int               `json:"quantity"`
+	Tags      []string          `json:"tags"`
+	Metadata  map[string]string `json:"metadata"`
+	Internal  string            `json:"-"`
+	Warehouse struct {
+		Code string
+	} `json:"warehouse"`
+}
+
+func (stockReservedEvent) TypeName() misas.EventTypeName { return "inventory.stock_reserved" }
+
+func TestSystemConf_AsyncAPI(t *testing.T) {
+	system := mx.NewSystem("shop").WithVersion("1.2.0")
+	system.EventBus("inventory.events")
+	system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+		ProducesEventsOn("inventory.events", stockReservedEvent{}),
+	)
+	system.WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+		WithEventHandlers("inventory.events", misas.EventHandlerFunc(func(context.Context, misas.Event) error { return nil })),
+	)
+
+	t.Run("GIVEN event published on a bus WHEN exporting AsyncAPI THEN should describe channel, operations and payload", func(t *testing.T) {
+		doc, err := system.AsyncAPI()
+		require.NoError(t, err)
+
+		require.Equal(t, "3.0.0", doc.AsyncAPI)
+		require.Equal(t, mx.AsyncAPIInfo{Title: "shop", Version: "1.2.0"}, doc.Info)
+		require.Equal(t, mx.AsyncAPIChannel{
+			Address: "inventory.events",
+			Messages: map[string]mx.AsyncAPIRef{
+				"inventory.stock_reserved": {Ref: "#/components/messages/inventory.stock_reserved"},
+			},
+		}, doc.Channels["inventory.events"])
+
+		require.Equal(t, mx.AsyncAPIOperation{
+			Action:    "send",
+			Channel:   mx.AsyncAPIRef{Ref: "#/channels/inventory.events"},
+			Messages:  []mx.AsyncAPIRef{{Ref: "#/channels/inventory.events/messages/inventory.stock_reserved"}},
+			Subsystem: "inventory",
+		}, doc.Operations["inventory.send.inventory.events"])
+		require.Equal(t, mx.AsyncAPIOperation{
+			Action:    "receive",
+			Channel:   mx.AsyncAPIRef{Ref: "#/channels/inventory.events"},
+			Subsystem: "reporting",
+		}, doc.Operations["reporting.receive.inventory.events"])
+
+		message := doc.Components.Messages["inventory.stock_reserved"]
+		require.Equal(t, "event", message.Kind)
+		require.Equal(t, "inventory", message.Subsystem)
+		require.Equal(t, &mx.JSONSchema{
+			Type: "object",
+			Properties: map[string]*mx.JSONSchema{
+				"productId": {Type: "string"},
+				"quantity":  {Type: "integer"},
+				"tags":      {Type: "array", Items: &mx.JSONSchema{Type: "string"}},
+				"metadata":  {Type: "object", AdditionalProperties: &mx.JSONSchema{Type: "string"}},
+				"warehouse": {Type: "object", Properties: map[string]*mx.JSONSchema{"Code": {Type: "string"}}, Required: []string{"Code"}},
+			},
+			Required: []string{"metadata", "productId", "quantity", "tags", "warehouse"},
+		}, message.Payload)
+	})
+
+	t.Run("GIVEN system WHEN rendering AsyncAPI as JSON THEN should be valid JSON", func(t *testing.T) {
+		doc, err := system.AsyncAPI()
+		require.NoError(t, err)
+		js, err := doc.JSON()
+		require.NoError(t, err)
+
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(js, &decoded))
+		require.Equal(t, "3.0.0", decoded["asyncapi"])
+	})
+}
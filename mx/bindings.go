@@ -51,10 +51,16 @@ type DynamicBinding[T any] struct {
 	bound atomic.Bool
 }
 
+// dynamicBindingValue boxes bound values, since atomic.Value only accepts values of a consistent concrete type
+// while a binding of an interface type can be rebound to different implementations.
+type dynamicBindingValue[T any] struct {
+	value T
+}
+
 func NewDynamicBinding[T any]() *DynamicBinding[T] { return &DynamicBinding[T]{} }
 
 func (d *DynamicBinding[T]) Bind(value T) {
-	d.ptr.Store(value)
+	d.ptr.Store(dynamicBindingValue[T]{value: value})
 	d.bound.Store(true)
 }
 
@@ -62,8 +68,7 @@ func (d *DynamicBinding[T]) Get() T {
 	if !d.bound.Load() {
 		panic(fmt.Sprintf("dynamic binding %T: value not bound", *new(T)))
 	}
-	v := d.ptr.Load()
-	return v.(T)
+	return d.ptr.Load().(dynamicBindingValue[T]).value
 }
 
 func (d *DynamicBinding[T]) IsBound() bool { return d.bound.Load() }
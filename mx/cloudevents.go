@@ -0,0 +1,305 @@
+package mx
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mtime"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	// CloudEventsContentTypeStructured is the content type of events in the structured content mode.
+	CloudEventsContentTypeStructured = "application/cloudevents+json"
+
+	// cloudEventsSchemaVersionExtension holds the schema version of the event, see VersionedMessage.
+	cloudEventsSchemaVersionExtension = "schemaversion"
+
+	cloudEventsHeaderPrefix = "ce-"
+)
+
+// CloudEvent is a misas.Event expressed as a CloudEvents 1.0 event. It renders in the structured content mode with its
+// JSON methods, and in the binary content mode with BinaryHeaders.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	Type            misas.EventTypeName
+	Time            time.Time
+	DataContentType string
+	Data            []byte
+	// Extensions holds the extension context attributes, such as the schema version of the event.
+	Extensions map[string]string
+}
+
+// CloudEventsCodec encodes registered events as CloudEvents and decodes CloudEvents back into registered events.
+type CloudEventsCodec struct {
+	registry *MessageRegistry[misas.EventTypeName, misas.Event]
+	clock    mtime.Clock
+	system   string
+	newID    func() string
+}
+
+// NewCloudEventsCodec returns a codec encoding the events of the registry, with their payload encoded by the codec
+// of the registry, and stamped with the time of the clock. The system name is the source of events published outside
+// subsystems.
+func NewCloudEventsCodec(registry *MessageRegistry[misas.EventTypeName, misas.Event], system string, clock mtime.Clock) *CloudEventsCodec {
+	if registry == nil {
+		panic("cloud events codec: registry cannot be nil")
+	}
+	if clock == nil {
+		panic("cloud events codec: clock cannot be nil")
+	}
+
+	return &CloudEventsCodec{registry: registry, clock: clock, system: system, newID: newUUID}
+}
+
+// CloudEventsCodec returns a codec encoding the events of the system as CloudEvents, see NewCloudEventsCodec.
+func (sc *SystemConf) CloudEventsCodec() *CloudEventsCodec {
+	return NewCloudEventsCodec(sc.MessageRegistries().Events, sc.name, sc.clock)
+}
+
+// WithIDGenerator sets the function generating the identifiers of events, random UUIDs by default.
+func (c *CloudEventsCodec) WithIDGenerator(newID func() string) *CloudEventsCodec {
+	if newID == nil {
+		panic("cloud events codec: id generator cannot be nil")
+	}
+	c.newID = newID
+
+	return c
+}
+
+// Encode expresses an event as a CloudEvent. Its source is "/<system>/<subsystem>", after the system and subsystem
+// publishing it according to the context, or the system of the codec.
+func (c *CloudEventsCodec) Encode(ctx context.Context, event misas.Event) (CloudEvent, error) {
+	envelope, err := c.registry.MarshalEnvelope(event, nil)
+	if err != nil {
+		return CloudEvent{}, err
+	}
+
+	return CloudEvent{
+		ID:              c.newID(),
+		Source:          c.source(ctx),
+		Type:            misas.EventTypeName(envelope.TypeName),
+		Time:            c.clock.Now(),
+		DataContentType: envelope.ContentType,
+		Data:            envelope.Payload,
+		Extensions: map[string]string{
+			cloudEventsSchemaVersionExtension: strconv.Itoa(envelope.SchemaVersion),
+		},
+	}, nil
+}
+
+func (c *CloudEventsCodec) source(ctx context.Context) string {
+	system := cmp.Or(Ctx(ctx).SystemInfo().Name, c.system)
+	if subsystem := Ctx(ctx).SubsystemInfo().Name; subsystem != "" {
+		return "/" + system + "/" + subsystem
+	}
+
+	return "/" + system
+}
+
+// Decode decodes the data of a CloudEvent as the event registered under its type, upcasting it from the schema version
+// of its extension if any. Parameters of its content type, such as a charset, are ignored.
+func (c *CloudEventsCodec) Decode(ce CloudEvent) (misas.Event, error) {
+	version := defaultSchemaVersion
+	if v, ok := ce.Extensions[cloudEventsSchemaVersionExtension]; ok {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil {
+			return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("cloud event %q has an invalid schema version %q", ce.ID, v))
+		}
+	}
+
+	contentType := ce.DataContentType
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("cloud event %q has an invalid content type %q", ce.ID, contentType))
+		}
+		contentType = mediaType
+	}
+
+	return c.registry.UnmarshalEnvelope(MessageEnvelope{
+		TypeName:      string(ce.Type),
+		SchemaVersion: version,
+		ContentType:   contentType,
+		Payload:       ce.Data,
+	})
+}
+
+// Validate reports a misas.ErrInvalid if the required context attributes of the event are missing.
+func (ce CloudEvent) Validate() error {
+	var missing []string
+	for _, attribute := range [][2]string{{"id", ce.ID}, {"source", ce.Source}, {"type", string(ce.Type)}} {
+		if attribute[1] == "" {
+			missing = append(missing, attribute[0])
+		}
+	}
+	if len(missing) != 0 {
+		return misas.ErrInvalid.WithMessage(fmt.Sprintf("cloud event is missing required attributes: %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// MarshalJSON renders the event in the structured content mode. JSON data is embedded as is, other data is base64
+// encoded in data_base64.
+func (ce CloudEvent) MarshalJSON() ([]byte, error) {
+	attributes := map[string]any{}
+	for name, value := range ce.Extensions {
+		attributes[name] = value
+	}
+	attributes["specversion"] = CloudEventsSpecVersion
+	attributes["id"] = ce.ID
+	attributes["source"] = ce.Source
+	attributes["type"] = ce.Type
+	if !ce.Time.IsZero() {
+		attributes["time"] = ce.Time.Format(time.RFC3339Nano)
+	}
+	if ce.DataContentType != "" {
+		attributes["datacontenttype"] = ce.DataContentType
+	}
+	if ce.Data != nil {
+		if isJSONContentType(ce.DataContentType) {
+			attributes["data"] = json.RawMessage(ce.Data)
+		} else {
+			attributes["data_base64"] = ce.Data
+		}
+	}
+
+	return json.Marshal(attributes)
+}
+
+// UnmarshalJSON reads an event in the structured content mode.
+func (ce *CloudEvent) UnmarshalJSON(js []byte) error {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(js, &attributes); err != nil {
+		return err
+	}
+
+	*ce = CloudEvent{Extensions: map[string]string{}}
+	for name, raw := range attributes {
+		var err error
+		switch name {
+		case "data":
+			ce.Data = raw
+		case "data_base64":
+			err = json.Unmarshal(raw, &ce.Data)
+		default:
+			var value any
+			if err = json.Unmarshal(raw, &value); err == nil {
+				err = ce.setAttribute(name, fmt.Sprint(value))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid cloud event attribute %q: %w", name, err)
+		}
+	}
+
+	return ce.checkSpecVersion()
+}
+
+// BinaryHeaders renders the context attributes of the event in the binary content mode, as ce- prefixed headers along
+// the Content-Type header. The data of the event is the body of the message.
+func (ce CloudEvent) BinaryHeaders() map[string]string {
+	headers := map[string]string{}
+	for name, value := range ce.Extensions {
+		headers[cloudEventsHeaderPrefix+name] = value
+	}
+	headers[cloudEventsHeaderPrefix+"specversion"] = CloudEventsSpecVersion
+	headers[cloudEventsHeaderPrefix+"id"] = ce.ID
+	headers[cloudEventsHeaderPrefix+"source"] = ce.Source
+	headers[cloudEventsHeaderPrefix+"type"] = string(ce.Type)
+	if !ce.Time.IsZero() {
+		headers[cloudEventsHeaderPrefix+"time"] = ce.Time.Format(time.RFC3339Nano)
+	}
+	if ce.DataContentType != "" {
+		headers["content-type"] = ce.DataContentType
+	}
+
+	return headers
+}
+
+// ParseBinaryCloudEvent reads an event in the binary content mode from the headers and body of a message.
+// Header names are case-insensitive.
+func ParseBinaryCloudEvent(headers map[string]string, body []byte) (CloudEvent, error) {
+	ce := CloudEvent{Data: body, Extensions: map[string]string{}}
+	for name, value := range headers {
+		name = strings.ToLower(name)
+		switch {
+		case name == "content-type":
+			ce.DataContentType = value
+		case strings.HasPrefix(name, cloudEventsHeaderPrefix):
+			if err := ce.setAttribute(strings.TrimPrefix(name, cloudEventsHeaderPrefix), value); err != nil {
+				return CloudEvent{}, fmt.Errorf("invalid cloud event header %q: %w", textproto.CanonicalMIMEHeaderKey(name), err)
+			}
+		}
+	}
+
+	if err := ce.checkSpecVersion(); err != nil {
+		return CloudEvent{}, err
+	}
+
+	return ce, nil
+}
+
+func (ce *CloudEvent) setAttribute(name string, value string) error {
+	switch name {
+	case "specversion":
+		ce.Extensions["specversion"] = value // checked once all attributes are read
+	case "id":
+		ce.ID = value
+	case "source":
+		ce.Source = value
+	case "type":
+		ce.Type = misas.EventTypeName(value)
+	case "datacontenttype":
+		ce.DataContentType = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return err
+		}
+		ce.Time = t
+	default:
+		ce.Extensions[name] = value
+	}
+
+	return nil
+}
+
+func (ce *CloudEvent) checkSpecVersion() error {
+	version := ce.Extensions["specversion"]
+	delete(ce.Extensions, "specversion")
+	if version != CloudEventsSpecVersion {
+		return misas.ErrInvalid.WithMessage(fmt.Sprintf("unsupported cloud events spec version %q", version))
+	}
+
+	return ce.Validate()
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+
+	return mediaType == "" || mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // never fails
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,178 @@
+package mx_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mtime"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEventsCodec(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	newCodec := func(codec mx.Codec) *mx.CloudEventsCodec {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]().WithCodec(codec)
+		registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+
+		return mx.NewCloudEventsCodec(registry, "inventory", mtime.NewManualClock(now)).
+			WithIDGenerator(func() string { return "evt-1" })
+	}
+
+	t.Run("GIVEN registered event WHEN encoding THEN should map it to cloud event attributes", func(t *testing.T) {
+		ce, err := newCodec(mx.JSONCodec{}).Encode(context.Background(), stockCountedEvent{ProductID: "p1", Quantity: 3})
+		require.NoError(t, err)
+
+		require.Equal(t, mx.CloudEvent{
+			ID:              "evt-1",
+			Source:          "/inventory",
+			Type:            "inventory.stock_counted",
+			Time:            now,
+			DataContentType: mx.ContentTypeJSON,
+			Data:            []byte(`{"productId":"p1","quantity":3}`),
+			Extensions:      map[string]string{"schemaversion": "2"},
+		}, ce)
+	})
+
+	t.Run("GIVEN event not registered WHEN encoding THEN should return an error", func(t *testing.T) {
+		_, err := newCodec(mx.JSONCodec{}).Encode(context.Background(), stockReservedEvent{})
+		require.ErrorIs(t, err, misas.ErrNotFound)
+	})
+
+	t.Run("GIVEN JSON data WHEN rendering in structured mode THEN should embed the data", func(t *testing.T) {
+		codec := newCodec(mx.JSONCodec{})
+		ce, err := codec.Encode(context.Background(), stockCountedEvent{ProductID: "p1", Quantity: 3})
+		require.NoError(t, err)
+
+		js, err := json.Marshal(ce)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"specversion": "1.0",
+			"id": "evt-1",
+			"source": "/inventory",
+			"type": "inventory.stock_counted",
+			"time": "2024-05-01T12:30:00Z",
+			"datacontenttype": "application/json",
+			"schemaversion": "2",
+			"data": {"productId": "p1", "quantity": 3}
+		}`, string(js))
+
+		var decoded mx.CloudEvent
+		require.NoError(t, json.Unmarshal(js, &decoded))
+		event, err := codec.Decode(decoded)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 3}, event)
+	})
+
+	t.Run("GIVEN binary data WHEN rendering in structured mode THEN should base64 encode the data", func(t *testing.T) {
+		codec := newCodec(mx.CBORCodec{})
+		ce, err := codec.Encode(context.Background(), stockCountedEvent{ProductID: "p1", Quantity: 3})
+		require.NoError(t, err)
+
+		js, err := json.Marshal(ce)
+		require.NoError(t, err)
+		var attributes map[string]any
+		require.NoError(t, json.Unmarshal(js, &attributes))
+		require.Contains(t, attributes, "data_base64")
+		require.NotContains(t, attributes, "data")
+
+		var decoded mx.CloudEvent
+		require.NoError(t, json.Unmarshal(js, &decoded))
+		event, err := codec.Decode(decoded)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 3}, event)
+	})
+
+	t.Run("GIVEN cloud event WHEN rendering in binary mode THEN should round trip through headers", func(t *testing.T) {
+		codec := newCodec(mx.MessagePackCodec{})
+		ce, err := codec.Encode(context.Background(), stockCountedEvent{ProductID: "p1", Quantity: 3})
+		require.NoError(t, err)
+
+		headers := ce.BinaryHeaders()
+		require.Equal(t, map[string]string{
+			"ce-specversion":   "1.0",
+			"ce-id":            "evt-1",
+			"ce-source":        "/inventory",
+			"ce-type":          "inventory.stock_counted",
+			"ce-time":          "2024-05-01T12:30:00Z",
+			"ce-schemaversion": "2",
+			"content-type":     mx.ContentTypeMessagePack,
+		}, headers)
+
+		canonical := map[string]string{}
+		for name, value := range headers {
+			canonical[textproto.CanonicalMIMEHeaderKey(name)] = value
+		}
+		parsed, err := mx.ParseBinaryCloudEvent(canonical, ce.Data)
+		require.NoError(t, err)
+		require.Equal(t, ce, parsed)
+
+		event, err := codec.Decode(parsed)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 3}, event)
+	})
+
+	t.Run("GIVEN content type with parameters WHEN decoding in binary mode THEN should ignore the parameters", func(t *testing.T) {
+		codec := newCodec(mx.JSONCodec{})
+		ce, err := codec.Encode(context.Background(), stockCountedEvent{ProductID: "p1", Quantity: 3})
+		require.NoError(t, err)
+
+		headers := ce.BinaryHeaders()
+		headers["content-type"] = "application/json; charset=utf-8"
+		parsed, err := mx.ParseBinaryCloudEvent(headers, ce.Data)
+		require.NoError(t, err)
+
+		event, err := codec.Decode(parsed)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 3}, event)
+	})
+
+	t.Run("GIVEN malformed content type WHEN decoding THEN should return an error", func(t *testing.T) {
+		_, err := newCodec(mx.JSONCodec{}).Decode(mx.CloudEvent{
+			ID:              "evt-1",
+			Source:          "/inventory",
+			Type:            "inventory.stock_counted",
+			DataContentType: "application/json; charset",
+			Data:            []byte(`{"productId":"p1","quantity":3}`),
+			Extensions:      map[string]string{"schemaversion": "2"},
+		})
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "invalid content type")
+	})
+
+	t.Run("GIVEN unsupported spec version WHEN parsing THEN should return an error", func(t *testing.T) {
+		var ce mx.CloudEvent
+		err := json.Unmarshal([]byte(`{"specversion": "0.3", "id": "1", "source": "/s", "type": "t"}`), &ce)
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+
+	t.Run("GIVEN missing required attributes WHEN parsing THEN should return an error", func(t *testing.T) {
+		_, err := mx.ParseBinaryCloudEvent(map[string]string{"ce-specversion": "1.0", "ce-type": "t"}, nil)
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "id, source")
+	})
+
+	t.Run("GIVEN cloud event without schema version WHEN decoding THEN should upcast from version 1", func(t *testing.T) {
+		_, err := newCodec(mx.JSONCodec{}).Decode(mx.CloudEvent{
+			ID:              "evt-1",
+			Source:          "/inventory",
+			Type:            "inventory.stock_counted",
+			DataContentType: mx.ContentTypeJSON,
+			Data:            []byte(`{"productId":"p1"}`),
+		})
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "no upcaster")
+	})
+
+	t.Run("GIVEN system WHEN getting its codec THEN should use the system name as source", func(t *testing.T) {
+		system := mx.NewSystem("warehouse").WithMessageRegistries(mx.NewMessageRegistries()).WithSystemEvents()
+		ce, err := system.CloudEventsCodec().Encode(context.Background(), mx.SystemEvent{HookName: mx.SystemExecutionStartedPluginHookName})
+		require.NoError(t, err)
+		require.Equal(t, "/warehouse", ce.Source)
+		require.Len(t, ce.ID, 36)
+	})
+}
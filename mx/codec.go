@@ -0,0 +1,127 @@
+package mx
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/morebec/misas/misas"
+)
+
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeGob         = "application/x-gob"
+	ContentTypeCBOR        = "application/cbor"
+	ContentTypeMessagePack = "application/msgpack"
+)
+
+// Codec encodes and decodes the payload of messages. Its content type is written to the MessageEnvelope of messages,
+// so that readers pick the right codec to decode them.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// builtinCodecs are the codecs known by every registry to decode payloads.
+var builtinCodecs = map[string]Codec{
+	ContentTypeJSON:        JSONCodec{},
+	ContentTypeGob:         GobCodec{},
+	ContentTypeCBOR:        CBORCodec{},
+	ContentTypeMessagePack: MessagePackCodec{},
+}
+
+// JSONCodec encodes payloads with encoding/json. It is the default codec of registries.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// GobCodec encodes payloads with encoding/gob. Concrete types stored in interface fields of messages must be
+// registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) ContentType() string { return ContentTypeGob }
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// WithCodec sets the codec encoding the payload of messages, JSONCodec by default. The codec is also used to decode
+// payloads of its content type.
+func (m *MessageRegistry[TN, T]) WithCodec(c Codec) *MessageRegistry[TN, T] {
+	if c == nil {
+		panic("message registry: codec cannot be nil")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.codec = c
+	m.codecs[c.ContentType()] = c
+
+	return m
+}
+
+// WithTypeCodec sets the codec encoding the payload of the messages of the given type, overriding the codec of the
+// registry. The codec is also used to decode payloads of its content type.
+func (m *MessageRegistry[TN, T]) WithTypeCodec(tn TN, c Codec) *MessageRegistry[TN, T] {
+	if c == nil {
+		panic(fmt.Sprintf("message registry: codec of message %q cannot be nil", tn))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.typeCodecs[tn] = c
+	m.codecs[c.ContentType()] = c
+
+	return m
+}
+
+// codecOf returns the codec encoding the messages of the given type.
+func (m *MessageRegistry[TN, T]) codecOf(tn TN) Codec {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if c, ok := m.typeCodecs[tn]; ok {
+		return c
+	}
+	if m.codec != nil {
+		return m.codec
+	}
+
+	return JSONCodec{}
+}
+
+// decoderOf returns the codec decoding payloads of the given content type, JSON if empty.
+func (m *MessageRegistry[TN, T]) decoderOf(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	m.mu.RLock()
+	c, ok := m.codecs[contentType]
+	m.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+	if c, ok := builtinCodecs[contentType]; ok {
+		return c, nil
+	}
+
+	return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("unsupported content type %q", contentType))
+}
@@ -0,0 +1,574 @@
+package mx
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The CBOR and MessagePack codecs share the reflection of Go values: values are first converted to a binaryValue tree,
+// then written in the format of the codec. Decoding reads a binaryValue tree, then assigns it to a Go value.
+// Struct fields follow the rules of encoding/json: they are named after their json tag, which may omit them, or omit
+// them when empty or zero; fields of embedded structs are promoted. time.Time is encoded natively by each format, and
+// encoding.TextMarshaler implementations as text.
+//
+// A binaryValue is one of:
+//   - nil
+//   - bool
+//   - int64, for negative integers, or uint64
+//   - float32 or float64
+//   - string
+//   - []byte
+//   - []binaryValue
+//   - binaryMap
+//   - time.Time
+type binaryValue = any
+
+// binaryMap is a map in its encoding order.
+type binaryMap []binaryMapEntry
+
+type binaryMapEntry struct {
+	Key   binaryValue
+	Value binaryValue
+}
+
+// maxBinaryDepth bounds the nesting of decoded values, guarding against stack exhaustion on malicious input.
+const maxBinaryDepth = 1000
+
+// toBinaryValue converts a Go value to a binaryValue.
+func toBinaryValue(v reflect.Value) (binaryValue, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	t := v.Type()
+	switch {
+	case t == timeType:
+		return v.Interface().(time.Time), nil
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && t.Implements(textMarshalerType):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toBinaryValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i < 0 {
+			return i, nil
+		}
+		return uint64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		return float32(v.Float()), nil
+	case reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return slices.Clone(v.Bytes()), nil
+		}
+		return toBinaryArray(v)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		return toBinaryArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toBinaryMap(v)
+	case reflect.Struct:
+		return toBinaryStruct(v)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func toBinaryArray(v reflect.Value) (binaryValue, error) {
+	values := make([]binaryValue, v.Len())
+	for i := range values {
+		var err error
+		if values[i], err = toBinaryValue(v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func toBinaryMap(v reflect.Value) (binaryValue, error) {
+	m := make(binaryMap, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := toBinaryValue(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, err := toBinaryValue(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, binaryMapEntry{Key: key, Value: value})
+	}
+
+	// sorted for deterministic encodings
+	slices.SortFunc(m, func(a, b binaryMapEntry) int {
+		return cmp.Compare(fmt.Sprint(a.Key), fmt.Sprint(b.Key))
+	})
+
+	return m, nil
+}
+
+func toBinaryStruct(v reflect.Value) (binaryValue, error) {
+	fields := binaryStructFields(v.Type())
+	m := make(binaryMap, 0, len(fields))
+	for _, f := range fields {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			continue // field of a nil embedded pointer
+		}
+		if f.omitEmpty && isEmptyBinaryValue(fv) || f.omitZero && fv.IsZero() {
+			continue
+		}
+
+		value, err := toBinaryValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		m = append(m, binaryMapEntry{Key: f.name, Value: value})
+	}
+
+	return m, nil
+}
+
+// isEmptyBinaryValue reports whether a value is empty according to the omitempty option of encoding/json.
+func isEmptyBinaryValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+type binaryStructField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+}
+
+var binaryStructFieldsCache sync.Map // reflect.Type -> []binaryStructField
+
+// binaryStructFields returns the encoded fields of a struct type, promoting the fields of embedded structs.
+// Fields declared at a shallower depth win over promoted ones of the same name.
+func binaryStructFields(t reflect.Type) []binaryStructField {
+	if fields, ok := binaryStructFieldsCache.Load(t); ok {
+		return fields.([]binaryStructField)
+	}
+
+	var fields []binaryStructField
+	depths := map[string]int{}
+	var collect func(t reflect.Type, index []int, visited map[reflect.Type]bool)
+	collect = func(t reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+
+		var embedded []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, options, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" && options == "" {
+				continue
+			}
+
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && ft != timeType {
+					embedded = append(embedded, f)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			if name == "" {
+				name = f.Name
+			}
+			depth := len(index)
+			if d, exists := depths[name]; exists && d <= depth {
+				continue
+			}
+			depths[name] = depth
+			fields = slices.DeleteFunc(fields, func(bf binaryStructField) bool { return bf.name == name })
+			fields = append(fields, binaryStructField{
+				name:      name,
+				index:     append(slices.Clone(index), i),
+				omitEmpty: hasJSONOption(options, "omitempty"),
+				omitZero:  hasJSONOption(options, "omitzero"),
+			})
+		}
+
+		for _, f := range embedded {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			collect(ft, append(slices.Clone(index), f.Index...), visited)
+		}
+	}
+	collect(t, nil, map[reflect.Type]bool{})
+
+	binaryStructFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+// fromBinaryValue assigns a binaryValue to a settable Go value.
+func fromBinaryValue(bv binaryValue, v reflect.Value) error {
+	t := v.Type()
+	if bv == nil {
+		v.SetZero()
+		return nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return fromBinaryValue(bv, v.Elem())
+	}
+
+	switch {
+	case t == timeType:
+		switch b := bv.(type) {
+		case time.Time:
+			v.Set(reflect.ValueOf(b))
+			return nil
+		case string:
+			tm, err := time.Parse(time.RFC3339Nano, b)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(tm))
+			return nil
+		}
+		return binaryTypeError(bv, t)
+	case t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(textUnmarshalerType):
+		if s, ok := bv.(string); ok {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return fmt.Errorf("cannot decode into non-empty interface %s", t)
+		}
+		v.Set(reflect.ValueOf(naturalBinaryValue(bv)))
+	case reflect.Bool:
+		b, ok := bv.(bool)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n := bv.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return binaryOverflowError(bv, t)
+			}
+			i = int64(n)
+		default:
+			return binaryTypeError(bv, t)
+		}
+		if v.OverflowInt(i) {
+			return binaryOverflowError(bv, t)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := bv.(uint64)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		if v.OverflowUint(n) {
+			return binaryOverflowError(bv, t)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := bv.(type) {
+		case float32:
+			f = float64(n)
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		default:
+			return binaryTypeError(bv, t)
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, ok := bv.(string)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if b, ok := bv.([]byte); ok && t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(slices.Clone(b))
+			return nil
+		}
+		values, ok := bv.([]binaryValue)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		s := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			if err := fromBinaryValue(value, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		if b, ok := bv.([]byte); ok && t.Elem().Kind() == reflect.Uint8 {
+			if len(b) != t.Len() {
+				return fmt.Errorf("cannot decode %d bytes into %s", len(b), t)
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		values, ok := bv.([]binaryValue)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		if len(values) != t.Len() {
+			return fmt.Errorf("cannot decode %d values into %s", len(values), t)
+		}
+		for i, value := range values {
+			if err := fromBinaryValue(value, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := bv.(binaryMap)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		mv := reflect.MakeMapWithSize(t, len(m))
+		for _, e := range m {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromBinaryValue(e.Key, key); err != nil {
+				return err
+			}
+			if !key.Comparable() {
+				return fmt.Errorf("cannot decode map key of type %s into %s: key is not comparable", key.Elem().Type(), t)
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromBinaryValue(e.Value, value); err != nil {
+				return err
+			}
+			mv.SetMapIndex(key, value)
+		}
+		v.Set(mv)
+	case reflect.Struct:
+		m, ok := bv.(binaryMap)
+		if !ok {
+			return binaryTypeError(bv, t)
+		}
+		return fromBinaryStruct(m, v)
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+
+	return nil
+}
+
+func fromBinaryStruct(m binaryMap, v reflect.Value) error {
+	fields := binaryStructFields(v.Type())
+	for _, e := range m {
+		name, ok := e.Key.(string)
+		if !ok {
+			return fmt.Errorf("cannot decode map key %v into a field of %s", e.Key, v.Type())
+		}
+
+		// like encoding/json, names are matched exactly first, then case-insensitively
+		i := slices.IndexFunc(fields, func(f binaryStructField) bool { return f.name == name })
+		if i < 0 {
+			i = slices.IndexFunc(fields, func(f binaryStructField) bool { return strings.EqualFold(f.name, name) })
+		}
+		if i < 0 {
+			continue // unknown fields are ignored
+		}
+
+		fv, err := binaryStructFieldValue(v, fields[i].index)
+		if err != nil {
+			return err
+		}
+		if err := fromBinaryValue(e.Value, fv); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// binaryStructFieldValue returns the field at the given index path, allocating nil embedded pointers.
+func binaryStructFieldValue(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, nil
+}
+
+// naturalBinaryValue converts a binaryValue to the Go value decoded into an empty interface: maps with string keys
+// become map[string]any, other maps map[any]any, and arrays []any.
+func naturalBinaryValue(bv binaryValue) any {
+	switch b := bv.(type) {
+	case []binaryValue:
+		values := make([]any, len(b))
+		for i, value := range b {
+			values[i] = naturalBinaryValue(value)
+		}
+		return values
+	case binaryMap:
+		if !slices.ContainsFunc(b, func(e binaryMapEntry) bool { _, ok := e.Key.(string); return !ok }) {
+			m := make(map[string]any, len(b))
+			for _, e := range b {
+				m[e.Key.(string)] = naturalBinaryValue(e.Value)
+			}
+			return m
+		}
+		m := make(map[any]any, len(b))
+		for _, e := range b {
+			key := naturalBinaryValue(e.Key)
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				key = fmt.Sprint(key)
+			}
+			m[key] = naturalBinaryValue(e.Value)
+		}
+		return m
+	default:
+		return bv
+	}
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func binaryTypeError(bv binaryValue, t reflect.Type) error {
+	return fmt.Errorf("cannot decode %T into %s", bv, t)
+}
+
+func binaryOverflowError(bv binaryValue, t reflect.Type) error {
+	return fmt.Errorf("value %v overflows %s", bv, t)
+}
+
+// binaryReader reads the bytes of an encoded value, failing on truncated input.
+type binaryReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+var errBinaryTruncated = errors.New("unexpected end of data")
+
+func (r *binaryReader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errBinaryTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+
+	return b, nil
+}
+
+func (r *binaryReader) read(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errBinaryTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+
+	return b, nil
+}
+
+// enter tracks the nesting of arrays and maps, see maxBinaryDepth.
+func (r *binaryReader) enter() error {
+	r.depth++
+	if r.depth > maxBinaryDepth {
+		return fmt.Errorf("maximum nesting depth of %d exceeded", maxBinaryDepth)
+	}
+
+	return nil
+}
+
+func (r *binaryReader) leave() { r.depth-- }
+
+// unmarshalBinary decodes data read by readValue into v, which must be a non-nil pointer.
+func unmarshalBinary(data []byte, v any, readValue func(r *binaryReader) (binaryValue, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", v)
+	}
+
+	r := &binaryReader{data: data}
+	bv, err := readValue(r)
+	if err != nil {
+		return err
+	}
+	if r.pos != len(data) {
+		return fmt.Errorf("unexpected %d trailing bytes", len(data)-r.pos)
+	}
+
+	return fromBinaryValue(bv, rv.Elem())
+}
@@ -0,0 +1,407 @@
+package mx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// CBORCodec encodes payloads in CBOR (RFC 8949). Structs are encoded as maps keyed by field name following the rules
+// of encoding/json, and time.Time as standard date/time strings (tag 0).
+type CBORCodec struct{}
+
+func (CBORCodec) ContentType() string { return ContentTypeCBOR }
+
+func (CBORCodec) Marshal(v any) ([]byte, error) {
+	bv, err := toBinaryValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var w cborWriter
+	if err := w.writeValue(bv); err != nil {
+		return nil, err
+	}
+
+	return w.buf, nil
+}
+
+func (CBORCodec) Unmarshal(data []byte, v any) error {
+	return unmarshalBinary(data, v, readCBORValue)
+}
+
+const (
+	cborMajorUnsigned byte = 0
+	cborMajorNegative byte = 1
+	cborMajorBytes    byte = 2
+	cborMajorText     byte = 3
+	cborMajorArray    byte = 4
+	cborMajorMap      byte = 5
+	cborMajorTag      byte = 6
+	cborMajorSimple   byte = 7
+
+	cborFalse     byte = 0xf4
+	cborTrue      byte = 0xf5
+	cborNull      byte = 0xf6
+	cborUndefined byte = 0xf7
+	cborFloat16   byte = 0xf9
+	cborFloat32   byte = 0xfa
+	cborFloat64   byte = 0xfb
+	cborBreak     byte = 0xff
+
+	cborIndefinite byte = 31
+
+	cborTagDateTimeString uint64 = 0
+	cborTagEpochDateTime  uint64 = 1
+)
+
+type cborWriter struct {
+	buf []byte
+}
+
+// writeHead writes the initial byte of a data item of the given major type, followed by its argument.
+func (w *cborWriter) writeHead(major byte, n uint64) {
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, major<<5|26), uint32(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, major<<5|27), n)
+	}
+}
+
+func (w *cborWriter) writeValue(bv binaryValue) error {
+	switch v := bv.(type) {
+	case nil:
+		w.buf = append(w.buf, cborNull)
+	case bool:
+		if v {
+			w.buf = append(w.buf, cborTrue)
+		} else {
+			w.buf = append(w.buf, cborFalse)
+		}
+	case uint64:
+		w.writeHead(cborMajorUnsigned, v)
+	case int64:
+		// negative integers encode -1-n
+		w.writeHead(cborMajorNegative, uint64(^v))
+	case float32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, cborFloat32), math.Float32bits(v))
+	case float64:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, cborFloat64), math.Float64bits(v))
+	case string:
+		w.writeHead(cborMajorText, uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case []byte:
+		w.writeHead(cborMajorBytes, uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case []binaryValue:
+		w.writeHead(cborMajorArray, uint64(len(v)))
+		for _, value := range v {
+			if err := w.writeValue(value); err != nil {
+				return err
+			}
+		}
+	case binaryMap:
+		w.writeHead(cborMajorMap, uint64(len(v)))
+		for _, e := range v {
+			if err := w.writeValue(e.Key); err != nil {
+				return err
+			}
+			if err := w.writeValue(e.Value); err != nil {
+				return err
+			}
+		}
+	case time.Time:
+		w.writeHead(cborMajorTag, cborTagDateTimeString)
+		return w.writeValue(v.Format(time.RFC3339Nano))
+	default:
+		return fmt.Errorf("cbor: unsupported value %T", bv)
+	}
+
+	return nil
+}
+
+func readCBORValue(r *binaryReader) (binaryValue, error) {
+	initial, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial>>5, initial&0x1f
+
+	if major == cborMajorSimple {
+		return readCBORSimple(r, initial)
+	}
+
+	if info == cborIndefinite {
+		return readCBORIndefinite(r, major)
+	}
+	n, err := readCBORArgument(r, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborMajorUnsigned:
+		return n, nil
+	case cborMajorNegative:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: negative integer -1-%d overflows int64", n)
+		}
+		return ^int64(n), nil
+	case cborMajorBytes:
+		b, err := r.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case cborMajorText:
+		b, err := r.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case cborMajorArray:
+		return readCBORArray(r, n)
+	case cborMajorMap:
+		return readCBORMap(r, n)
+	default: // cborMajorTag
+		return readCBORTag(r, n)
+	}
+}
+
+// readCBORArgument reads the argument of a data item following its initial byte.
+func readCBORArgument(r *binaryReader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		b, err := r.read(1 << (info - 24))
+		if err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, x := range b {
+			n = n<<8 | uint64(x)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+}
+
+func readCBORSimple(r *binaryReader, initial byte) (binaryValue, error) {
+	switch initial {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndefined:
+		return nil, nil
+	case cborFloat16:
+		b, err := r.read(2)
+		if err != nil {
+			return nil, err
+		}
+		return float32(float16ToFloat64(binary.BigEndian.Uint16(b))), nil
+	case cborFloat32:
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case cborFloat64:
+		b, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case cborBreak:
+		return nil, fmt.Errorf("cbor: unexpected break")
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value 0x%x", initial)
+	}
+}
+
+func readCBORArray(r *binaryReader, n uint64) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	// every item takes at least one byte, bounding the allocation by the input size
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errBinaryTruncated
+	}
+	values := make([]binaryValue, n)
+	for i := range values {
+		var err error
+		if values[i], err = readCBORValue(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func readCBORMap(r *binaryReader, n uint64) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	if n > uint64(len(r.data)-r.pos)/2 {
+		return nil, errBinaryTruncated
+	}
+	m := make(binaryMap, n)
+	for i := range m {
+		key, err := readCBORValue(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readCBORValue(r)
+		if err != nil {
+			return nil, err
+		}
+		m[i] = binaryMapEntry{Key: key, Value: value}
+	}
+
+	return m, nil
+}
+
+// readCBORIndefinite reads an indefinite length string, array or map, terminated by a break.
+func readCBORIndefinite(r *binaryReader, major byte) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	atBreak := func() bool { return r.pos < len(r.data) && r.data[r.pos] == cborBreak }
+
+	switch major {
+	case cborMajorBytes, cborMajorText:
+		var chunks []byte
+		for !atBreak() {
+			chunk, err := readCBORValue(r)
+			if err != nil {
+				return nil, err
+			}
+			switch c := chunk.(type) {
+			case []byte:
+				if major != cborMajorBytes {
+					return nil, fmt.Errorf("cbor: invalid chunk of indefinite length text")
+				}
+				chunks = append(chunks, c...)
+			case string:
+				if major != cborMajorText {
+					return nil, fmt.Errorf("cbor: invalid chunk of indefinite length bytes")
+				}
+				chunks = append(chunks, c...)
+			default:
+				return nil, fmt.Errorf("cbor: invalid chunk of indefinite length string")
+			}
+		}
+		r.pos++
+		if major == cborMajorText {
+			return string(chunks), nil
+		}
+		return chunks, nil
+	case cborMajorArray:
+		values := []binaryValue{}
+		for !atBreak() {
+			value, err := readCBORValue(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		r.pos++
+		return values, nil
+	case cborMajorMap:
+		m := binaryMap{}
+		for !atBreak() {
+			key, err := readCBORValue(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readCBORValue(r)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, binaryMapEntry{Key: key, Value: value})
+		}
+		r.pos++
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cbor: major type %d cannot have an indefinite length", major)
+	}
+}
+
+// readCBORTag reads the content of a tag. Date/time tags are decoded as time.Time, other tags are ignored.
+func readCBORTag(r *binaryReader, tag uint64) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	content, err := readCBORValue(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case cborTagDateTimeString:
+		s, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("cbor: date/time tag content must be text, got %T", content)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case cborTagEpochDateTime:
+		switch seconds := content.(type) {
+		case uint64:
+			return time.Unix(int64(seconds), 0).UTC(), nil
+		case int64:
+			return time.Unix(seconds, 0).UTC(), nil
+		case float32:
+			return epochSecondsToTime(float64(seconds)), nil
+		case float64:
+			return epochSecondsToTime(seconds), nil
+		}
+		return nil, fmt.Errorf("cbor: epoch date/time tag content must be a number, got %T", content)
+	default:
+		return content, nil
+	}
+}
+
+func epochSecondsToTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision float.
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exponent, mantissa := int(h>>10&0x1f), float64(h&0x3ff)
+
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(mantissa+1024, exponent-25)
+	}
+}
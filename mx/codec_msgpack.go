@@ -0,0 +1,365 @@
+package mx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// MessagePackCodec encodes payloads in MessagePack. Structs are encoded as maps keyed by field name following the
+// rules of encoding/json, and time.Time with the timestamp extension type.
+type MessagePackCodec struct{}
+
+func (MessagePackCodec) ContentType() string { return ContentTypeMessagePack }
+
+func (MessagePackCodec) Marshal(v any) ([]byte, error) {
+	bv, err := toBinaryValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var w msgpackWriter
+	if err := w.writeValue(bv); err != nil {
+		return nil, err
+	}
+
+	return w.buf, nil
+}
+
+func (MessagePackCodec) Unmarshal(data []byte, v any) error {
+	return unmarshalBinary(data, v, readMessagePackValue)
+}
+
+const (
+	msgpackNil      byte = 0xc0
+	msgpackFalse    byte = 0xc2
+	msgpackTrue     byte = 0xc3
+	msgpackBin8     byte = 0xc4
+	msgpackBin16    byte = 0xc5
+	msgpackBin32    byte = 0xc6
+	msgpackExt8     byte = 0xc7
+	msgpackExt16    byte = 0xc8
+	msgpackExt32    byte = 0xc9
+	msgpackFloat32  byte = 0xca
+	msgpackFloat64  byte = 0xcb
+	msgpackUint8    byte = 0xcc
+	msgpackUint16   byte = 0xcd
+	msgpackUint32   byte = 0xce
+	msgpackUint64   byte = 0xcf
+	msgpackInt8     byte = 0xd0
+	msgpackInt16    byte = 0xd1
+	msgpackInt32    byte = 0xd2
+	msgpackInt64    byte = 0xd3
+	msgpackFixExt1  byte = 0xd4
+	msgpackFixExt16 byte = 0xd8
+	msgpackStr8     byte = 0xd9
+	msgpackStr16    byte = 0xda
+	msgpackStr32    byte = 0xdb
+	msgpackArray16  byte = 0xdc
+	msgpackArray32  byte = 0xdd
+	msgpackMap16    byte = 0xde
+	msgpackMap32    byte = 0xdf
+
+	msgpackTimestampExtType int8 = -1
+)
+
+type msgpackWriter struct {
+	buf []byte
+}
+
+// writeLength writes the header of a string, binary, array or map of the given length, using the fix format if its
+// length is below fixLimit and fixPrefix is non-zero.
+func (w *msgpackWriter) writeLength(n int, fixPrefix byte, fixLimit int, code8, code16, code32 byte) error {
+	switch {
+	case fixPrefix != 0 && n < fixLimit:
+		w.buf = append(w.buf, fixPrefix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		w.buf = append(w.buf, code8, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, code16), uint16(n))
+	case uint64(n) <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, code32), uint32(n))
+	default:
+		return fmt.Errorf("msgpack: length %d exceeds the maximum length", n)
+	}
+
+	return nil
+}
+
+func (w *msgpackWriter) writeValue(bv binaryValue) error {
+	switch v := bv.(type) {
+	case nil:
+		w.buf = append(w.buf, msgpackNil)
+	case bool:
+		if v {
+			w.buf = append(w.buf, msgpackTrue)
+		} else {
+			w.buf = append(w.buf, msgpackFalse)
+		}
+	case uint64:
+		switch {
+		case v <= 0x7f:
+			w.buf = append(w.buf, byte(v)) // positive fixint
+		case v <= math.MaxUint8:
+			w.buf = append(w.buf, msgpackUint8, byte(v))
+		case v <= math.MaxUint16:
+			w.buf = binary.BigEndian.AppendUint16(append(w.buf, msgpackUint16), uint16(v))
+		case v <= math.MaxUint32:
+			w.buf = binary.BigEndian.AppendUint32(append(w.buf, msgpackUint32), uint32(v))
+		default:
+			w.buf = binary.BigEndian.AppendUint64(append(w.buf, msgpackUint64), v)
+		}
+	case int64:
+		switch {
+		case v >= 0:
+			return w.writeValue(uint64(v))
+		case v >= -32:
+			w.buf = append(w.buf, byte(v)) // negative fixint
+		case v >= math.MinInt8:
+			w.buf = append(w.buf, msgpackInt8, byte(v))
+		case v >= math.MinInt16:
+			w.buf = binary.BigEndian.AppendUint16(append(w.buf, msgpackInt16), uint16(v))
+		case v >= math.MinInt32:
+			w.buf = binary.BigEndian.AppendUint32(append(w.buf, msgpackInt32), uint32(v))
+		default:
+			w.buf = binary.BigEndian.AppendUint64(append(w.buf, msgpackInt64), uint64(v))
+		}
+	case float32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, msgpackFloat32), math.Float32bits(v))
+	case float64:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, msgpackFloat64), math.Float64bits(v))
+	case string:
+		if err := w.writeLength(len(v), 0xa0, 32, msgpackStr8, msgpackStr16, msgpackStr32); err != nil {
+			return err
+		}
+		w.buf = append(w.buf, v...)
+	case []byte:
+		if err := w.writeLength(len(v), 0, 0, msgpackBin8, msgpackBin16, msgpackBin32); err != nil {
+			return err
+		}
+		w.buf = append(w.buf, v...)
+	case []binaryValue:
+		if err := w.writeLength(len(v), 0x90, 16, 0, msgpackArray16, msgpackArray32); err != nil {
+			return err
+		}
+		for _, value := range v {
+			if err := w.writeValue(value); err != nil {
+				return err
+			}
+		}
+	case binaryMap:
+		if err := w.writeLength(len(v), 0x80, 16, 0, msgpackMap16, msgpackMap32); err != nil {
+			return err
+		}
+		for _, e := range v {
+			if err := w.writeValue(e.Key); err != nil {
+				return err
+			}
+			if err := w.writeValue(e.Value); err != nil {
+				return err
+			}
+		}
+	case time.Time:
+		// timestamp 96: nanoseconds as uint32, then seconds as int64
+		w.buf = append(w.buf, msgpackExt8, 12, 0xff) // timestamp extension type -1
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(v.Nanosecond()))
+		w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(v.Unix()))
+	default:
+		return fmt.Errorf("msgpack: unsupported value %T", bv)
+	}
+
+	return nil
+}
+
+func readMessagePackValue(r *binaryReader) (binaryValue, error) {
+	code, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return uint64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return readMessagePackMap(r, uint64(code&0x0f))
+	case code&0xf0 == 0x90:
+		return readMessagePackArray(r, uint64(code&0x0f))
+	case code&0xe0 == 0xa0:
+		return readMessagePackString(r, uint64(code&0x1f))
+	}
+
+	switch code {
+	case msgpackNil:
+		return nil, nil
+	case msgpackFalse:
+		return false, nil
+	case msgpackTrue:
+		return true, nil
+	case msgpackUint8, msgpackUint16, msgpackUint32, msgpackUint64:
+		return readMessagePackUint(r, 1<<(code-msgpackUint8))
+	case msgpackInt8, msgpackInt16, msgpackInt32, msgpackInt64:
+		n, err := readMessagePackUint(r, 1<<(code-msgpackInt8))
+		if err != nil {
+			return nil, err
+		}
+		bits := 8 << (code - msgpackInt8)
+		i := int64(n<<(64-bits)) >> (64 - bits) // sign extension
+		if i >= 0 {
+			return uint64(i), nil
+		}
+		return i, nil
+	case msgpackFloat32:
+		n, err := readMessagePackUint(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(uint32(n)), nil
+	case msgpackFloat64:
+		n, err := readMessagePackUint(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case msgpackStr8, msgpackStr16, msgpackStr32:
+		n, err := readMessagePackUint(r, 1<<(code-msgpackStr8))
+		if err != nil {
+			return nil, err
+		}
+		return readMessagePackString(r, n)
+	case msgpackBin8, msgpackBin16, msgpackBin32:
+		n, err := readMessagePackUint(r, 1<<(code-msgpackBin8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case msgpackArray16, msgpackArray32:
+		n, err := readMessagePackUint(r, 2<<(code-msgpackArray16))
+		if err != nil {
+			return nil, err
+		}
+		return readMessagePackArray(r, n)
+	case msgpackMap16, msgpackMap32:
+		n, err := readMessagePackUint(r, 2<<(code-msgpackMap16))
+		if err != nil {
+			return nil, err
+		}
+		return readMessagePackMap(r, n)
+	case msgpackExt8, msgpackExt16, msgpackExt32:
+		n, err := readMessagePackUint(r, 1<<(code-msgpackExt8))
+		if err != nil {
+			return nil, err
+		}
+		return readMessagePackExt(r, n)
+	}
+
+	if code >= msgpackFixExt1 && code <= msgpackFixExt16 {
+		return readMessagePackExt(r, 1<<(code-msgpackFixExt1))
+	}
+
+	return nil, fmt.Errorf("msgpack: invalid format 0x%x", code)
+}
+
+// readMessagePackUint reads a big endian unsigned integer of the given size in bytes.
+func readMessagePackUint(r *binaryReader, size uint64) (uint64, error) {
+	b, err := r.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, x := range b {
+		n = n<<8 | uint64(x)
+	}
+
+	return n, nil
+}
+
+func readMessagePackString(r *binaryReader, n uint64) (binaryValue, error) {
+	b, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func readMessagePackArray(r *binaryReader, n uint64) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	// every item takes at least one byte, bounding the allocation by the input size
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errBinaryTruncated
+	}
+	values := make([]binaryValue, n)
+	for i := range values {
+		var err error
+		if values[i], err = readMessagePackValue(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func readMessagePackMap(r *binaryReader, n uint64) (binaryValue, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	if n > uint64(len(r.data)-r.pos)/2 {
+		return nil, errBinaryTruncated
+	}
+	m := make(binaryMap, n)
+	for i := range m {
+		key, err := readMessagePackValue(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readMessagePackValue(r)
+		if err != nil {
+			return nil, err
+		}
+		m[i] = binaryMapEntry{Key: key, Value: value}
+	}
+
+	return m, nil
+}
+
+// readMessagePackExt reads an extension of n data bytes. Only the timestamp extension type is supported.
+func readMessagePackExt(r *binaryReader, n uint64) (binaryValue, error) {
+	extType, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(extType) != msgpackTimestampExtType {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", int8(extType))
+	}
+
+	switch n {
+	case 4: // timestamp 32: seconds as uint32
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8: // timestamp 64: nanoseconds on 30 bits, then seconds on 34 bits
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)).UTC(), nil
+	case 12: // timestamp 96: nanoseconds as uint32, then seconds as int64
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data[:4]))).UTC(), nil
+	default:
+		return nil, fmt.Errorf("msgpack: invalid timestamp of %d bytes", n)
+	}
+}
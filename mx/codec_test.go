@@ -0,0 +1,289 @@
+package mx_test
+
+import (
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type codecTestLocation struct {
+	Warehouse string `json:"warehouse"`
+	Aisle     *int   `json:"aisle,omitempty"`
+}
+
+type codecTestEvent struct {
+	codecTestLocation
+	ProductID  string            `json:"productId"`
+	Quantity   int               `json:"quantity"`
+	Delta      int64             `json:"delta"`
+	Weight     float64           `json:"weight"`
+	Ratio      float32           `json:"ratio"`
+	Available  bool              `json:"available"`
+	Tags       []string          `json:"tags"`
+	Counts     map[string]uint16 `json:"counts"`
+	Checksum   []byte            `json:"checksum"`
+	Origin     netip.Addr        `json:"origin"`
+	OccurredAt time.Time         `json:"occurredAt"`
+	ExpiresAt  *time.Time        `json:"expiresAt"`
+	Note       string            `json:"note,omitempty"`
+	Attributes map[string]any    `json:"attributes"`
+	Ignored    string            `json:"-"`
+}
+
+func (codecTestEvent) TypeName() misas.EventTypeName { return "inventory.codec_tested" }
+
+func codecTestEventValue() codecTestEvent {
+	aisle := 7
+	return codecTestEvent{
+		codecTestLocation: codecTestLocation{Warehouse: "north", Aisle: &aisle},
+		ProductID:         "p1",
+		Quantity:          300,
+		Delta:             -70000,
+		Weight:            12.5,
+		Ratio:             0.25,
+		Available:         true,
+		Tags:              []string{"fragile", "perishable"},
+		Counts:            map[string]uint16{"a": 1, "b": 65535},
+		Checksum:          []byte{0xde, 0xad},
+		Origin:            netip.MustParseAddr("10.0.0.1"),
+		OccurredAt:        time.Date(2026, 10, 18, 12, 30, 0, 123456789, time.UTC),
+		Attributes:        map[string]any{"batch": "b1", "lines": []any{"l1", "l2"}},
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	codecs := []mx.Codec{mx.JSONCodec{}, mx.GobCodec{}, mx.CBORCodec{}, mx.MessagePackCodec{}}
+	gob.Register([]any{}) // stored in the attributes of codecTestEvent
+
+	for _, codec := range codecs {
+		t.Run("GIVEN "+codec.ContentType()+" codec WHEN round tripping a message THEN should decode it unchanged", func(t *testing.T) {
+			data, err := codec.Marshal(codecTestEventValue())
+			require.NoError(t, err)
+
+			var decoded codecTestEvent
+			require.NoError(t, codec.Unmarshal(data, &decoded))
+
+			expected := codecTestEventValue()
+			if _, ok := codec.(mx.GobCodec); ok {
+				expected.codecTestLocation = codecTestLocation{} // gob ignores unexported embedded structs
+			}
+			require.Equal(t, expected, decoded)
+		})
+	}
+}
+
+func TestCBORCodec(t *testing.T) {
+	t.Run("GIVEN values WHEN marshalling THEN should produce RFC 8949 encodings", func(t *testing.T) {
+		for value, expected := range map[any]string{
+			uint64(0):           "00",
+			uint64(23):          "17",
+			uint64(24):          "1818",
+			uint64(1000):        "1903e8",
+			uint64(1000000):     "1a000f4240",
+			uint64(1 << 40):     "1b0000010000000000",
+			int64(-1):           "20",
+			int64(-1000):        "3903e7",
+			"IETF":              "6449455446",
+			true:                "f5",
+			1.5:                 "fb3ff8000000000000",
+			float32(100000.0):   "fa47c35000",
+			[2]byte{0x01, 0x02}: "420102",
+		} {
+			data, err := mx.CBORCodec{}.Marshal(value)
+			require.NoError(t, err)
+			require.Equal(t, expected, hex.EncodeToString(data), "%v", value)
+		}
+
+		data, err := mx.CBORCodec{}.Marshal(struct {
+			A []int `json:"a"`
+		}{A: []int{1, 2}})
+		require.NoError(t, err)
+		require.Equal(t, "a16161820102", hex.EncodeToString(data))
+
+		data, err = mx.CBORCodec{}.Marshal(time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, "c074323031332d30332d32315432303a30343a30305a", hex.EncodeToString(data))
+	})
+
+	t.Run("GIVEN RFC 8949 encodings WHEN unmarshalling THEN should decode them", func(t *testing.T) {
+		var v any
+		decode := func(h string) any {
+			data, err := hex.DecodeString(h)
+			require.NoError(t, err)
+			require.NoError(t, mx.CBORCodec{}.Unmarshal(data, &v))
+			return v
+		}
+
+		require.Equal(t, float32(1.5), decode("f93e00")) // half precision
+		require.Equal(t, float32(math.Inf(1)), decode("f97c00"))
+		require.Equal(t, "streaming", decode("7f657374726561646d696e67ff")) // indefinite length text
+		require.Equal(t, []any{uint64(1), []any{uint64(2), uint64(3)}}, decode("9f01820203ff"))
+		require.Equal(t, map[string]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}, decode("bf61610161629f0203ffff"))
+		require.Equal(t, time.Unix(1363896240, 0).UTC(), decode("c11a514b67b0"))
+		require.Nil(t, decode("f7"))
+	})
+
+	t.Run("GIVEN malformed data WHEN unmarshalling THEN should fail", func(t *testing.T) {
+		var v any
+		for _, h := range []string{"", "19", "62", "9b00000000ffffffff", "ff", "00ff", "1c"} {
+			data, _ := hex.DecodeString(h)
+			require.Error(t, mx.CBORCodec{}.Unmarshal(data, &v), h)
+		}
+
+		var n int8
+		require.Error(t, mx.CBORCodec{}.Unmarshal([]byte{0x19, 0x03, 0xe8}, &n)) // 1000 overflows int8
+		var s string
+		require.Error(t, mx.CBORCodec{}.Unmarshal([]byte{0x01}, &s))
+	})
+
+	t.Run("GIVEN map with array key WHEN unmarshalling into interface keyed map THEN should fail", func(t *testing.T) {
+		require.Error(t, mx.CBORCodec{}.Unmarshal([]byte{0xa1, 0x81, 0x01, 0x01}, &map[any]any{}))
+
+		var event struct {
+			Attributes map[any]any `json:"attributes"`
+		}
+		data, _ := hex.DecodeString("a16a61747472696275746573a1a1616101f5") // {"attributes": {{"a": 1}: true}}
+		require.Error(t, mx.CBORCodec{}.Unmarshal(data, &event))
+	})
+}
+
+func TestMessagePackCodec(t *testing.T) {
+	t.Run("GIVEN values WHEN marshalling THEN should produce MessagePack encodings", func(t *testing.T) {
+		for value, expected := range map[any]string{
+			nil:                 "c0",
+			uint64(127):         "7f",
+			uint64(128):         "cc80",
+			uint64(256):         "cd0100",
+			uint64(1 << 32):     "cf0000000100000000",
+			int64(-32):          "e0",
+			int64(-33):          "d0df",
+			int64(-129):         "d1ff7f",
+			int64(-40000):       "d2ffff63c0",
+			false:               "c2",
+			"abc":               "a3616263",
+			0.5:                 "cb3fe0000000000000",
+			float32(0.5):        "ca3f000000",
+			[2]byte{0x01, 0x02}: "c4020102",
+		} {
+			data, err := mx.MessagePackCodec{}.Marshal(value)
+			require.NoError(t, err)
+			require.Equal(t, expected, hex.EncodeToString(data), "%v", value)
+		}
+
+		data, err := mx.MessagePackCodec{}.Marshal(map[string][]int{"a": {1, -1}})
+		require.NoError(t, err)
+		require.Equal(t, "81a1619201ff", hex.EncodeToString(data))
+	})
+
+	t.Run("GIVEN timestamp extensions WHEN unmarshalling THEN should decode times", func(t *testing.T) {
+		var tm time.Time
+		require.NoError(t, mx.MessagePackCodec{}.Unmarshal([]byte{0xd6, 0xff, 0x51, 0x4b, 0x67, 0xb0}, &tm))
+		require.Equal(t, time.Unix(1363896240, 0).UTC(), tm)
+
+		// timestamp 64 with 5 nanoseconds
+		require.NoError(t, mx.MessagePackCodec{}.Unmarshal([]byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x14, 0x51, 0x4b, 0x67, 0xb0}, &tm))
+		require.Equal(t, time.Unix(1363896240, 5).UTC(), tm)
+	})
+
+	t.Run("GIVEN malformed data WHEN unmarshalling THEN should fail", func(t *testing.T) {
+		var v any
+		for _, h := range []string{"", "c1", "a2", "dd0000ffff", "d401", "d40100", "c0c0"} {
+			data, _ := hex.DecodeString(h)
+			require.Error(t, mx.MessagePackCodec{}.Unmarshal(data, &v), h)
+		}
+	})
+
+	t.Run("GIVEN map with array key WHEN unmarshalling into interface keyed map THEN should fail", func(t *testing.T) {
+		require.Error(t, mx.MessagePackCodec{}.Unmarshal([]byte{0x81, 0x91, 0x01, 0x01}, &map[any]any{}))
+	})
+}
+
+func FuzzBinaryCodecs(f *testing.F) {
+	for _, h := range []string{"a1810101", "81910101", "a16161820102", "bf61610161629f0203ffff", "81a1619201ff", "d6ff514b67b0", "c11a514b67b0"} {
+		data, _ := hex.DecodeString(h)
+		f.Add(data)
+	}
+	for _, codec := range []mx.Codec{mx.CBORCodec{}, mx.MessagePackCodec{}} {
+		data, err := codec.Marshal(codecTestEventValue())
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, codec := range []mx.Codec{mx.CBORCodec{}, mx.MessagePackCodec{}} {
+			var v any
+			_ = codec.Unmarshal(data, &v)
+			_ = codec.Unmarshal(data, &map[any]any{})
+			_ = codec.Unmarshal(data, &codecTestEvent{})
+		}
+	})
+}
+
+func TestMessageRegistry_Codecs(t *testing.T) {
+	t.Run("GIVEN registry codec WHEN marshalling THEN envelope should tag the content type", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]().WithCodec(mx.CBORCodec{})
+		registry.Register(codecTestEvent{}.TypeName(), codecTestEvent{})
+
+		envelope, err := registry.MarshalEnvelope(codecTestEventValue(), nil)
+		require.NoError(t, err)
+		require.Equal(t, mx.ContentTypeCBOR, envelope.ContentType)
+
+		event, err := registry.UnmarshalEnvelope(envelope)
+		require.NoError(t, err)
+		require.Equal(t, codecTestEventValue(), event)
+	})
+
+	t.Run("GIVEN type codec WHEN marshalling to JSON THEN payload should be base64 encoded and decodable", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		registry.Register(codecTestEvent{}.TypeName(), codecTestEvent{})
+		registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+		registry.WithTypeCodec(codecTestEvent{}.TypeName(), mx.MessagePackCodec{})
+
+		js, err := registry.MarshalToJSON(codecTestEventValue(), nil)
+		require.NoError(t, err)
+		var raw map[string]any
+		require.NoError(t, json.Unmarshal(js, &raw))
+		require.Equal(t, mx.ContentTypeMessagePack, raw["contentType"])
+		require.IsType(t, "", raw["payload"])
+
+		event, envelope, err := registry.UnmarshalEnvelopeFromJSON(js)
+		require.NoError(t, err)
+		require.Equal(t, mx.ContentTypeMessagePack, envelope.ContentType)
+		require.Equal(t, codecTestEventValue(), event)
+
+		// other types keep the registry codec
+		envelope, err = registry.MarshalEnvelope(stockCountedEvent{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, mx.ContentTypeJSON, envelope.ContentType)
+	})
+
+	t.Run("GIVEN envelope encoded by another registry WHEN unmarshalling THEN should pick the decoder of its content type", func(t *testing.T) {
+		writer := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]().WithCodec(mx.GobCodec{})
+		writer.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+		reader := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		reader.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+
+		envelope, err := writer.MarshalEnvelope(stockCountedEvent{ProductID: "p1", Quantity: 2}, nil)
+		require.NoError(t, err)
+
+		event, err := reader.UnmarshalEnvelope(envelope)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 2}, event)
+	})
+
+	t.Run("GIVEN unknown content type WHEN unmarshalling THEN should return an invalid error", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+
+		_, err := registry.UnmarshalEnvelope(mx.MessageEnvelope{TypeName: "inventory.stock_counted", ContentType: "application/xml"})
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+}
@@ -0,0 +1,178 @@
+package mx
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/morebec/misas/misas"
+)
+
+// defaultSchemaVersion is the schema version of messages not implementing VersionedMessage.
+const defaultSchemaVersion = 1
+
+// MessageEnvelope is the standard wire format of commands, queries and events.
+//
+// Its payload is encoded with the codec identified by its content type, JSON if empty. In the JSON representation of
+// the envelope, JSON payloads are embedded as is, while other payloads are base64 encoded.
+type MessageEnvelope struct {
+	TypeName      string            `json:"type"`
+	SchemaVersion int               `json:"schemaVersion"`
+	ContentType   string            `json:"contentType,omitempty"`
+	Payload       []byte            `json:"payload"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// jsonMessageEnvelope is the JSON representation of a MessageEnvelope, before decoding its payload.
+type jsonMessageEnvelope struct {
+	TypeName      string            `json:"type"`
+	SchemaVersion int               `json:"schemaVersion"`
+	ContentType   string            `json:"contentType,omitempty"`
+	Payload       json.RawMessage   `json:"payload"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+func (e MessageEnvelope) MarshalJSON() ([]byte, error) {
+	payload := json.RawMessage(e.Payload)
+	if !e.hasJSONPayload() {
+		var err error
+		if payload, err = json.Marshal(e.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(jsonMessageEnvelope{
+		TypeName:      e.TypeName,
+		SchemaVersion: e.SchemaVersion,
+		ContentType:   e.ContentType,
+		Payload:       payload,
+		Metadata:      e.Metadata,
+	})
+}
+
+func (e *MessageEnvelope) UnmarshalJSON(data []byte) error {
+	var je jsonMessageEnvelope
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+
+	*e = MessageEnvelope{
+		TypeName:      je.TypeName,
+		SchemaVersion: je.SchemaVersion,
+		ContentType:   je.ContentType,
+		Payload:       je.Payload,
+		Metadata:      je.Metadata,
+	}
+	if !e.hasJSONPayload() {
+		return json.Unmarshal(je.Payload, &e.Payload)
+	}
+
+	return nil
+}
+
+func (e MessageEnvelope) hasJSONPayload() bool {
+	return e.ContentType == "" || e.ContentType == ContentTypeJSON
+}
+
+// VersionedMessage is implemented by messages whose schema evolved, to report the version of their schema written to
+// their envelope. Other messages are at version 1. Envelopes at older versions are upcast when decoded,
+// see MessageRegistry.WithJSONUpcaster and MessageRegistry.WithStructUpcaster.
+type VersionedMessage interface {
+	SchemaVersion() int
+}
+
+// MarshalEnvelope encodes a registered message in a MessageEnvelope with the codec of its type, along with the given
+// metadata.
+func (m *MessageRegistry[TN, T]) MarshalEnvelope(msg T, metadata map[string]string) (MessageEnvelope, error) {
+	tn, err := m.TypeNameOf(msg)
+	if err != nil {
+		return MessageEnvelope{}, err
+	}
+
+	codec := m.codecOf(tn)
+	payload, err := codec.Marshal(msg)
+	if err != nil {
+		return MessageEnvelope{}, fmt.Errorf("failed to marshal message %q: %w", tn, err)
+	}
+	version, _ := m.SchemaVersion(tn)
+
+	return MessageEnvelope{
+		TypeName:      string(tn),
+		SchemaVersion: version,
+		ContentType:   codec.ContentType(),
+		Payload:       payload,
+		Metadata:      metadata,
+	}, nil
+}
+
+// UnmarshalEnvelope decodes the payload of an envelope as the message type registered under its type name, with the
+// codec of its content type. Payloads at older schema versions are upcast to the current version, while payloads at
+// newer versions are rejected with a misas.ErrInvalid.
+func (m *MessageRegistry[TN, T]) UnmarshalEnvelope(envelope MessageEnvelope) (T, error) {
+	var zero T
+	if envelope.TypeName == "" {
+		return zero, misas.ErrInvalid.WithMessage("message envelope has no type")
+	}
+
+	tn := TN(envelope.TypeName)
+	typ, err := m.resolve(tn)
+	if err != nil {
+		return zero, err
+	}
+	codec, err := m.decoderOf(envelope.ContentType)
+	if err != nil {
+		return zero, err
+	}
+
+	ptr := reflect.New(typ)
+	if version, _ := m.SchemaVersion(tn); envelope.SchemaVersion == version {
+		err = codec.Unmarshal(envelope.Payload, ptr.Interface())
+	} else {
+		err = m.upcastEnvelope(envelope, codec, ptr)
+	}
+	if err != nil {
+		return zero, fmt.Errorf("failed to unmarshal message %q: %w", tn, err)
+	}
+
+	msg, ok := ptr.Elem().Interface().(T)
+	if !ok {
+		panic(misas.ErrBadLogic.WithMessage(fmt.Sprintf("unmarshaled type %q does not implement target interface", typ.Name())))
+	}
+
+	return msg, nil
+}
+
+// MarshalToJSON encodes a registered message in a MessageEnvelope rendered as JSON, see MarshalEnvelope.
+func (m *MessageRegistry[TN, T]) MarshalToJSON(msg T, metadata map[string]string) ([]byte, error) {
+	envelope, err := m.MarshalEnvelope(msg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope)
+}
+
+// UnmarshalEnvelopeFromJSON decodes a MessageEnvelope rendered as JSON, and its payload as the message type registered
+// under its type name.
+func (m *MessageRegistry[TN, T]) UnmarshalEnvelopeFromJSON(js []byte) (T, MessageEnvelope, error) {
+	var zero T
+	var envelope MessageEnvelope
+	if err := json.Unmarshal(js, &envelope); err != nil {
+		return zero, MessageEnvelope{}, misas.ErrInvalid.WithMessage(fmt.Sprintf("failed to unmarshal message envelope: %s", err))
+	}
+
+	msg, err := m.UnmarshalEnvelope(envelope)
+	if err != nil {
+		return zero, envelope, err
+	}
+
+	return msg, envelope, nil
+}
+
+func schemaVersionOf(msg any) int {
+	if v, ok := msg.(VersionedMessage); ok {
+		return v.SchemaVersion()
+	}
+
+	return defaultSchemaVersion
+}
@@ -0,0 +1,83 @@
+package mx_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type stockCountedEvent struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+func (stockCountedEvent) TypeName() misas.EventTypeName { return "inventory.stock_counted" }
+
+func (stockCountedEvent) SchemaVersion() int { return 2 }
+
+func TestMessageRegistry_MarshalToJSON(t *testing.T) {
+	registries := mx.NewMessageRegistries()
+	registries.Events.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+	registries.Commands.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+
+	t.Run("GIVEN registered message WHEN marshalling THEN should produce an envelope", func(t *testing.T) {
+		js, err := registries.Events.MarshalToJSON(stockCountedEvent{ProductID: "p1", Quantity: 3}, map[string]string{"correlationId": "c1"})
+		require.NoError(t, err)
+
+		require.JSONEq(t, `{
+			"type": "inventory.stock_counted",
+			"schemaVersion": 2,
+			"contentType": "application/json",
+			"payload": {"productId": "p1", "quantity": 3},
+			"metadata": {"correlationId": "c1"}
+		}`, string(js))
+	})
+
+	t.Run("GIVEN unversioned message WHEN marshalling THEN should be at version 1", func(t *testing.T) {
+		js, err := registries.Commands.MarshalToJSON(adjustStockCommand{ProductID: "p1"}, nil)
+		require.NoError(t, err)
+
+		var envelope mx.MessageEnvelope
+		require.NoError(t, json.Unmarshal(js, &envelope))
+		require.Equal(t, 1, envelope.SchemaVersion)
+		require.Nil(t, envelope.Metadata)
+	})
+
+	t.Run("GIVEN message not registered WHEN marshalling THEN should return an error", func(t *testing.T) {
+		_, err := registries.Events.MarshalToJSON(stockReservedEvent{}, nil)
+		require.ErrorIs(t, err, misas.ErrNotFound)
+	})
+}
+
+func TestMessageRegistry_UnmarshalEnvelopeFromJSON(t *testing.T) {
+	registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+	registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+
+	t.Run("GIVEN marshalled message WHEN unmarshalling envelope THEN should resolve the message", func(t *testing.T) {
+		js, err := registry.MarshalToJSON(&stockCountedEvent{ProductID: "p1", Quantity: 3}, map[string]string{"correlationId": "c1"})
+		require.NoError(t, err)
+
+		event, envelope, err := registry.UnmarshalEnvelopeFromJSON(js)
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 3}, event)
+		require.Equal(t, "inventory.stock_counted", envelope.TypeName)
+		require.Equal(t, 2, envelope.SchemaVersion)
+		require.Equal(t, map[string]string{"correlationId": "c1"}, envelope.Metadata)
+	})
+
+	t.Run("GIVEN malformed envelope WHEN unmarshalling THEN should return an invalid error", func(t *testing.T) {
+		_, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`[]`))
+		require.ErrorIs(t, err, misas.ErrInvalid)
+
+		_, _, err = registry.UnmarshalEnvelopeFromJSON([]byte(`{"payload": {}}`))
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+
+	t.Run("GIVEN envelope of unregistered type WHEN unmarshalling THEN should return an error", func(t *testing.T) {
+		_, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "not_registered", "schemaVersion": 1, "payload": {}}`))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,433 @@
+package mx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/morebec/misas/misas"
+)
+
+const (
+	defaultHTTPGatewayMaxBodySize     int64 = 1 << 20
+	defaultHTTPGatewayShutdownTimeout       = 10 * time.Second
+	httpGatewayReadHeaderTimeout            = 10 * time.Second
+)
+
+// HTTPGateway is an ApplicationSubsystem exposing the command and query buses over HTTP:
+//   - POST /commands/{type} dispatches the command of the given type name, decoded from the JSON body of the request.
+//     The payload of the result is returned as JSON, or 204 No Content when nil.
+//   - POST /queries/{type} dispatches the query of the given type name the same way.
+//
+// Messages are resolved through the registries of the gateway, every registered message being exposed unless
+// restricted by allow-lists. Errors are returned as a JSON object with the kind, code and message of the misas.Error,
+// with the status code of its kind, see HTTPStatusCode.
+type HTTPGateway struct {
+	name            string
+	addr            string
+	commandBus      misas.CommandBus
+	queryBus        misas.QueryBus
+	registries      MessageRegistries
+	allowedCommands map[misas.CommandTypeName]bool
+	allowedQueries  map[misas.QueryTypeName]bool
+	maxBodySize     int64
+	authenticator   HTTPAuthenticator
+	shutdownTimeout time.Duration
+	mux             *http.ServeMux
+
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+}
+
+// HTTPAuthenticator authenticates the requests of an HTTPGateway. It returns the context in which the message of the
+// request is dispatched, typically carrying the identity of the caller, or nil to dispatch it in the context of the
+// request. Errors other than misas errors are reported as misas.ErrUnauthenticated.
+type HTTPAuthenticator interface {
+	Authenticate(r *http.Request) (context.Context, error)
+}
+
+type HTTPAuthenticatorFunc func(r *http.Request) (context.Context, error)
+
+func (f HTTPAuthenticatorFunc) Authenticate(r *http.Request) (context.Context, error) { return f(r) }
+
+// NewHTTPGateway returns a gateway listening on addr, dispatching commands and queries on the given buses.
+func NewHTTPGateway(name string, addr string, commandBus misas.CommandBus, queryBus misas.QueryBus) *HTTPGateway {
+	if name == "" {
+		panic("http gateway: name cannot be empty")
+	}
+	if commandBus == nil || queryBus == nil {
+		panic(fmt.Sprintf("http gateway %s: command and query buses cannot be nil", name))
+	}
+
+	g := &HTTPGateway{
+		name:            name,
+		addr:            addr,
+		commandBus:      commandBus,
+		queryBus:        queryBus,
+		registries:      DefaultMessageRegistries(),
+		maxBodySize:     defaultHTTPGatewayMaxBodySize,
+		shutdownTimeout: defaultHTTPGatewayShutdownTimeout,
+		mux:             http.NewServeMux(),
+	}
+	g.mux.HandleFunc("POST /commands/{type}", g.handleCommand)
+	g.mux.HandleFunc("POST /queries/{type}", g.handleQuery)
+
+	return g
+}
+
+// HTTPGateway returns a gateway listening on addr, exposing the commands and queries of the system.
+func (sc *SystemConf) HTTPGateway(name string, addr string) *HTTPGateway {
+	return NewHTTPGateway(name, addr, sc.CommandBus(), sc.QueryBus()).WithMessageRegistries(sc.MessageRegistries())
+}
+
+// WithMessageRegistries sets the registries resolving the messages of requests, the default registries by default.
+func (g *HTTPGateway) WithMessageRegistries(r MessageRegistries) *HTTPGateway {
+	if r.Commands == nil || r.Queries == nil {
+		panic(fmt.Sprintf("http gateway %s: command and query registries cannot be nil", g.name))
+	}
+	g.registries = r
+
+	return g
+}
+
+// WithAllowedCommands restricts the commands exposed by the gateway to the given type names.
+func (g *HTTPGateway) WithAllowedCommands(typeNames ...misas.CommandTypeName) *HTTPGateway {
+	if g.allowedCommands == nil {
+		g.allowedCommands = map[misas.CommandTypeName]bool{}
+	}
+	for _, tn := range typeNames {
+		g.allowedCommands[tn] = true
+	}
+
+	return g
+}
+
+// WithAllowedQueries restricts the queries exposed by the gateway to the given type names.
+func (g *HTTPGateway) WithAllowedQueries(typeNames ...misas.QueryTypeName) *HTTPGateway {
+	if g.allowedQueries == nil {
+		g.allowedQueries = map[misas.QueryTypeName]bool{}
+	}
+	for _, tn := range typeNames {
+		g.allowedQueries[tn] = true
+	}
+
+	return g
+}
+
+// WithMaxBodySize limits the size of the body of requests, 1 MiB by default.
+func (g *HTTPGateway) WithMaxBodySize(bytes int64) *HTTPGateway {
+	if bytes <= 0 {
+		panic(fmt.Sprintf("http gateway %s: max body size must be positive", g.name))
+	}
+	g.maxBodySize = bytes
+
+	return g
+}
+
+// WithAuthenticator sets the authenticator of requests. Requests are not authenticated by default.
+func (g *HTTPGateway) WithAuthenticator(a HTTPAuthenticator) *HTTPGateway {
+	g.authenticator = a
+
+	return g
+}
+
+// WithShutdownTimeout bounds the time given to in-flight requests when the gateway is stopped by the cancellation of
+// its run, 10 seconds by default. At teardown, in-flight requests are bounded by the teardown timeout instead.
+func (g *HTTPGateway) WithShutdownTimeout(d time.Duration) *HTTPGateway {
+	if d <= 0 {
+		panic(fmt.Sprintf("http gateway %s: shutdown timeout must be positive", g.name))
+	}
+	g.shutdownTimeout = d
+
+	return g
+}
+
+// WithHandler serves additional endpoints next to the commands and queries, such as an OpenAPIHandler:
+//
+//	docs, err := system.OpenAPIHandler()
+//	if err != nil {
+//		return err
+//	}
+//	gateway.WithHandler("GET /api/", http.StripPrefix("/api", docs))
+//
+// The pattern follows the syntax of http.ServeMux, and panics if it conflicts with the endpoints of the gateway.
+func (g *HTTPGateway) WithHandler(pattern string, h http.Handler) *HTTPGateway {
+	g.mux.Handle(pattern, h)
+
+	return g
+}
+
+func (g *HTTPGateway) Name() string { return g.name }
+
+func (g *HTTPGateway) Initialize(context.Context) error { return nil }
+
+// Run serves requests until ctx is cancelled, then shuts the server down gracefully.
+func (g *HTTPGateway) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		return fmt.Errorf("http gateway %s: %w", g.name, err)
+	}
+	server := &http.Server{
+		Handler:           g,
+		ReadHeaderTimeout: httpGatewayReadHeaderTimeout,
+		// requests in flight at shutdown are given a chance to complete
+		BaseContext: func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
+	}
+
+	g.mu.Lock()
+	g.server, g.listener = server, listener
+	g.mu.Unlock()
+
+	Log(ctx).Info(fmt.Sprintf("http gateway %s listening on %s", g.name, listener.Addr()))
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), g.shutdownTimeout)
+		defer cancel()
+		return g.shutdown(shutdownCtx)
+	}
+}
+
+// Teardown shuts the server down gracefully, waiting for in-flight requests until ctx is done.
+func (g *HTTPGateway) Teardown(ctx context.Context) error {
+	return g.shutdown(ctx)
+}
+
+func (g *HTTPGateway) shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	server := g.server
+	g.server = nil
+	g.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		_ = server.Close() // requests outliving the deadline are interrupted
+		return fmt.Errorf("http gateway %s: %w", g.name, err)
+	}
+
+	return nil
+}
+
+// Addr returns the address the gateway listens on once running, such as the port chosen for ":0".
+func (g *HTTPGateway) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.listener == nil {
+		return nil
+	}
+
+	return g.listener.Addr()
+}
+
+func (g *HTTPGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+func (g *HTTPGateway) handleCommand(w http.ResponseWriter, r *http.Request) {
+	tn := misas.CommandTypeName(r.PathValue("type"))
+	if g.allowedCommands != nil && !g.allowedCommands[tn] {
+		g.writeError(r.Context(), w, misas.ErrNotFound.WithMessage(fmt.Sprintf("command %q not found", tn)))
+		return
+	}
+
+	ctx, cmd, err := decodeHTTPMessage(g, r, w, g.registries.Commands, tn)
+	if err != nil {
+		g.writeError(ctx, w, err)
+		return
+	}
+
+	result := g.commandBus.HandleCommand(ctx, cmd)
+	if result.Error != nil {
+		g.writeError(ctx, w, result.Error)
+		return
+	}
+	g.writePayload(ctx, w, result.Payload)
+}
+
+func (g *HTTPGateway) handleQuery(w http.ResponseWriter, r *http.Request) {
+	tn := misas.QueryTypeName(r.PathValue("type"))
+	if g.allowedQueries != nil && !g.allowedQueries[tn] {
+		g.writeError(r.Context(), w, misas.ErrNotFound.WithMessage(fmt.Sprintf("query %q not found", tn)))
+		return
+	}
+
+	ctx, query, err := decodeHTTPMessage(g, r, w, g.registries.Queries, tn)
+	if err != nil {
+		g.writeError(ctx, w, err)
+		return
+	}
+
+	result := g.queryBus.HandleQuery(ctx, query)
+	if result.Error != nil {
+		g.writeError(ctx, w, result.Error)
+		return
+	}
+	g.writePayload(ctx, w, result.Payload)
+}
+
+// decodeHTTPMessage authenticates a request and decodes its JSON body as the registered message of the given type.
+func decodeHTTPMessage[TN ~string, T any](g *HTTPGateway, r *http.Request, w http.ResponseWriter, registry *MessageRegistry[TN, T], tn TN) (context.Context, T, error) {
+	var zero T
+	ctx := r.Context()
+	if g.authenticator != nil {
+		authenticated, err := g.authenticator.Authenticate(r)
+		if err != nil {
+			var me misas.Error
+			if !errors.As(err, &me) {
+				err = misas.ErrUnauthenticated.WithCause(err)
+			}
+			return ctx, zero, err
+		}
+		if authenticated != nil {
+			ctx = authenticated
+		}
+	}
+
+	if _, found := registry.Lookup(tn); !found {
+		return ctx, zero, misas.ErrNotFound.WithMessage(fmt.Sprintf("message %q not found", tn))
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != ContentTypeJSON {
+			return ctx, zero, errHTTPUnsupportedMediaType.WithMessage(fmt.Sprintf("unsupported content type %q, expected %s", contentType, ContentTypeJSON))
+		}
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, g.maxBodySize)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return ctx, zero, errHTTPRequestTooLarge.WithMessage(fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		}
+		return ctx, zero, misas.ErrInvalid.WithMessage(fmt.Sprintf("failed to read request body: %s", err))
+	}
+	if body.Len() == 0 {
+		body.WriteString("{}")
+	}
+
+	version, _ := registry.SchemaVersion(tn)
+	msg, err := registry.UnmarshalEnvelope(MessageEnvelope{
+		TypeName:      string(tn),
+		SchemaVersion: version,
+		ContentType:   ContentTypeJSON,
+		Payload:       body.Bytes(),
+	})
+	if err != nil {
+		var me misas.Error
+		if !errors.As(err, &me) {
+			err = misas.ErrInvalid.WithMessage(fmt.Sprintf("invalid %s: %s", tn, err))
+		}
+		return ctx, zero, err
+	}
+
+	return ctx, msg, nil
+}
+
+// The gateway reports request errors with no misas equivalent as misas errors of their own kinds.
+var (
+	errHTTPUnsupportedMediaType = misas.NewError("unsupported_media_type")
+	errHTTPRequestTooLarge      = misas.NewError("request_too_large")
+)
+
+// HTTPStatusCode returns the HTTP status code reporting an error according to its misas.ErrorKind. Errors other than
+// misas errors are internal errors, unless they are deadlines or network timeouts.
+func HTTPStatusCode(err error) int {
+	switch httpErrorOf(err).Kind() {
+	case misas.ErrorKindInvalid:
+		return http.StatusBadRequest
+	case misas.ErrorKindUnauthenticated:
+		return http.StatusUnauthorized
+	case misas.ErrorKindUnauthorized:
+		return http.StatusForbidden
+	case misas.ErrorKindNotFound:
+		return http.StatusNotFound
+	case misas.ErrorKindConflict:
+		return http.StatusConflict
+	case misas.ErrorKindTimeout:
+		return http.StatusGatewayTimeout
+	case misas.ErrorKindNotImplemented:
+		return http.StatusNotImplemented
+	case errHTTPUnsupportedMediaType.Kind():
+		return http.StatusUnsupportedMediaType
+	case errHTTPRequestTooLarge.Kind():
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// httpError is the body of error responses. Causes are never exposed, and the messages of internal errors are hidden.
+type httpError struct {
+	Kind    misas.ErrorKind `json:"kind"`
+	Code    misas.ErrorCode `json:"code,omitempty"`
+	Message string          `json:"message"`
+}
+
+// httpErrorOf returns the misas error reported for err. Errors other than misas errors are reported as timeouts or
+// internal errors without their message, which may reveal details of the server such as file paths.
+func httpErrorOf(err error) misas.Error {
+	var me misas.Error
+	if errors.As(err, &me) {
+		return me
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return misas.ErrTimeout.WithMessage("the request timed out").WithCause(err)
+	}
+
+	return misas.ErrInternal.WithCause(err)
+}
+
+func (g *HTTPGateway) writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	status := HTTPStatusCode(err)
+	me := httpErrorOf(err)
+
+	body := httpError{Kind: me.Kind(), Code: me.Code(), Message: me.Message()}
+	if status == http.StatusInternalServerError {
+		Log(ctx).Error(fmt.Sprintf("http gateway %s: request failed", g.name), slog.Any(logKeyError, err))
+		body = httpError{Kind: misas.ErrorKindInternal, Message: "an internal error occurred"}
+	}
+
+	g.writeJSON(ctx, w, status, map[string]httpError{"error": body})
+}
+
+func (g *HTTPGateway) writePayload(ctx context.Context, w http.ResponseWriter, payload any) {
+	if payload == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	g.writeJSON(ctx, w, http.StatusOK, payload)
+}
+
+func (g *HTTPGateway) writeJSON(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		Log(ctx).Error(fmt.Sprintf("http gateway %s: failed to encode response", g.name), slog.Any(logKeyError, err))
+		status = http.StatusInternalServerError
+		js, _ = json.Marshal(map[string]httpError{"error": {Kind: misas.ErrorKindInternal, Message: "an internal error occurred"}})
+	}
+
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(status)
+	_, _ = w.Write(js)
+}
@@ -0,0 +1,328 @@
+package mx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type reserveStockCommand struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+func (reserveStockCommand) TypeName() misas.CommandTypeName { return "inventory.reserve_stock" }
+
+type stockAvailabilityQuery struct {
+	ProductID string `json:"productId"`
+}
+
+func (stockAvailabilityQuery) TypeName() misas.QueryTypeName { return "inventory.stock_availability" }
+
+type callerKey struct{}
+
+func TestHTTPGateway(t *testing.T) {
+	newGateway := func() (*mx.HTTPGateway, *misas.InMemoryCommandBus, *misas.InMemoryQueryBus) {
+		registries := mx.MessageRegistries{
+			Commands: mx.NewMessageRegistry[misas.CommandTypeName, misas.Command](),
+			Events:   mx.NewMessageRegistry[misas.EventTypeName, misas.Event](),
+			Queries:  mx.NewMessageRegistry[misas.QueryTypeName, misas.Query](),
+		}
+		registries.Commands.Register(reserveStockCommand{}.TypeName(), reserveStockCommand{})
+		registries.Commands.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+		registries.Queries.Register(stockAvailabilityQuery{}.TypeName(), stockAvailabilityQuery{})
+
+		commandBus := misas.NewInMemoryCommandBus()
+		queryBus := misas.NewInMemoryQueryBus()
+		gateway := mx.NewHTTPGateway("api", "127.0.0.1:0", commandBus, queryBus).WithMessageRegistries(registries)
+
+		return gateway, commandBus, queryBus
+	}
+	post := func(h http.Handler, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("GIVEN registered command WHEN posting it THEN should dispatch it and return its payload", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		var received misas.Command
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(_ context.Context, cmd misas.Command) misas.CommandResult {
+			received = cmd
+			return misas.CommandResult{Payload: map[string]string{"reservationId": "r1"}}
+		}))
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{"productId":"p1","quantity":2}`)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, mx.ContentTypeJSON, rec.Header().Get("Content-Type"))
+		require.JSONEq(t, `{"reservationId":"r1"}`, rec.Body.String())
+		require.Equal(t, reserveStockCommand{ProductID: "p1", Quantity: 2}, received)
+	})
+
+	t.Run("GIVEN command handler returning no payload WHEN posting it THEN should return no content", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+			return misas.CommandResult{}
+		}))
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", ``)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		require.Empty(t, rec.Body.String())
+	})
+
+	t.Run("GIVEN registered query WHEN posting it THEN should dispatch it and return its payload", func(t *testing.T) {
+		gateway, _, queryBus := newGateway()
+		queryBus.RegisterHandler(stockAvailabilityQuery{}.TypeName(), misas.QueryHandlerFunc(func(_ context.Context, q misas.Query) misas.QueryResult {
+			return misas.QueryResult{Payload: map[string]any{"productId": q.(stockAvailabilityQuery).ProductID, "available": 5}}
+		}))
+
+		rec := post(gateway, "/queries/inventory.stock_availability", `{"productId":"p1"}`)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"productId":"p1","available":5}`, rec.Body.String())
+	})
+
+	t.Run("GIVEN message not registered WHEN posting it THEN should return not found", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+
+		rec := post(gateway, "/commands/inventory.unknown", `{}`)
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"not_found","message":"message \"inventory.unknown\" not found"}}`, rec.Body.String())
+	})
+
+	t.Run("GIVEN allow-list WHEN posting a registered command not allowed THEN should return not found", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		gateway.WithAllowedCommands(reserveStockCommand{}.TypeName())
+		commandBus.RegisterHandler(adjustStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+			t.Fatal("command not allowed should not be dispatched")
+			return misas.CommandResult{}
+		}))
+
+		rec := post(gateway, "/commands/inventory.adjust_stock", `{}`)
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GIVEN allow-list WHEN posting an allowed query THEN should dispatch it", func(t *testing.T) {
+		gateway, _, queryBus := newGateway()
+		gateway.WithAllowedQueries(stockAvailabilityQuery{}.TypeName())
+		queryBus.RegisterHandler(stockAvailabilityQuery{}.TypeName(), misas.QueryHandlerFunc(func(context.Context, misas.Query) misas.QueryResult {
+			return misas.QueryResult{Payload: []int{1}}
+		}))
+
+		rec := post(gateway, "/queries/inventory.stock_availability", `{}`)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("GIVEN body exceeding the size limit WHEN posting it THEN should return request entity too large", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+		gateway.WithMaxBodySize(8)
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{"productId":"p1"}`)
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("GIVEN malformed body WHEN posting it THEN should return bad request", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{"quantity":"two"}`)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), `"kind":"invalid"`)
+	})
+
+	t.Run("GIVEN content type other than JSON WHEN posting THEN should return unsupported media type", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+		req := httptest.NewRequest(http.MethodPost, "/commands/inventory.reserve_stock", strings.NewReader(`productId=p1`))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+
+		gateway.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("GIVEN method other than POST WHEN requesting THEN should return method not allowed", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+		rec := httptest.NewRecorder()
+
+		gateway.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/commands/inventory.reserve_stock", nil))
+
+		require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("GIVEN authenticator WHEN posting THEN should dispatch in the context it returns", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		gateway.WithAuthenticator(mx.HTTPAuthenticatorFunc(func(r *http.Request) (context.Context, error) {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found {
+				return nil, errors.New("missing bearer token")
+			}
+			if token != "alice" {
+				return nil, misas.ErrUnauthorized.WithMessage("unknown caller")
+			}
+			return context.WithValue(r.Context(), callerKey{}, token), nil
+		}))
+		var caller any
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(ctx context.Context, _ misas.Command) misas.CommandResult {
+			caller = ctx.Value(callerKey{})
+			return misas.CommandResult{}
+		}))
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{}`)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"unauthenticated","message":"request requires authentication"}}`, rec.Body.String())
+
+		req := httptest.NewRequest(http.MethodPost, "/commands/inventory.reserve_stock", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer bob")
+		rec = httptest.NewRecorder()
+		gateway.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/commands/inventory.reserve_stock", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer alice")
+		rec = httptest.NewRecorder()
+		gateway.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		require.Equal(t, "alice", caller)
+	})
+
+	t.Run("GIVEN handler failing WHEN posting THEN should map the error kind to a status code", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		var failure error
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+			return misas.CommandResult{Error: failure}
+		}))
+
+		failure = misas.ErrConflict.WithCode("insufficient_stock").WithMessage("only 1 unit left").WithCause(errors.New("secret"))
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{}`)
+		require.Equal(t, http.StatusConflict, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"conflict","code":"insufficient_stock","message":"only 1 unit left"}}`, rec.Body.String())
+
+		failure = errors.New("connection refused to db.internal:5432")
+		rec = post(gateway, "/commands/inventory.reserve_stock", `{}`)
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"internal","message":"an internal error occurred"}}`, rec.Body.String())
+
+		failure = context.DeadlineExceeded
+		rec = post(gateway, "/commands/inventory.reserve_stock", `{}`)
+		require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"timeout","message":"the request timed out"}}`, rec.Body.String())
+
+		failure = fmt.Errorf("opening ledger: %w", &fs.PathError{Op: "open", Path: "/srv/data/ledger.db", Err: fs.ErrNotExist})
+		rec = post(gateway, "/commands/inventory.reserve_stock", `{}`)
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"internal","message":"an internal error occurred"}}`, rec.Body.String())
+	})
+
+	t.Run("GIVEN request cancelled by the client WHEN handling it THEN should not report a timeout", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(ctx context.Context, _ misas.Command) misas.CommandResult {
+			return misas.CommandResult{Error: ctx.Err()}
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/commands/inventory.reserve_stock", strings.NewReader(`{}`))
+		rec := httptest.NewRecorder()
+		gateway.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.JSONEq(t, `{"error":{"kind":"internal","message":"an internal error occurred"}}`, rec.Body.String())
+	})
+
+	t.Run("GIVEN authenticator returning no context WHEN posting THEN should dispatch in the context of the request", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		gateway.WithAuthenticator(mx.HTTPAuthenticatorFunc(func(*http.Request) (context.Context, error) { return nil, nil }))
+		var dispatched context.Context
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(ctx context.Context, _ misas.Command) misas.CommandResult {
+			dispatched = ctx
+			return misas.CommandResult{}
+		}))
+
+		rec := post(gateway, "/commands/inventory.reserve_stock", `{}`)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		require.NotNil(t, dispatched)
+	})
+
+	t.Run("GIVEN gateway running WHEN tearing it down THEN should stop serving", func(t *testing.T) {
+		gateway, commandBus, _ := newGateway()
+		commandBus.RegisterHandler(reserveStockCommand{}.TypeName(), misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+			return misas.CommandResult{Payload: "reserved"}
+		}))
+		ctx := context.Background()
+		require.NoError(t, gateway.Initialize(ctx))
+
+		ran := make(chan error, 1)
+		go func() { ran <- gateway.Run(ctx) }()
+		require.Eventually(t, func() bool { return gateway.Addr() != nil }, time.Second, time.Millisecond)
+
+		resp, err := http.Post("http://"+gateway.Addr().String()+"/commands/inventory.reserve_stock", mx.ContentTypeJSON, strings.NewReader(`{}`))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		require.NoError(t, gateway.Teardown(ctx))
+		require.NoError(t, <-ran)
+	})
+
+	t.Run("GIVEN gateway running WHEN its run is cancelled THEN should shut down", func(t *testing.T) {
+		gateway, _, _ := newGateway()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		ran := make(chan error, 1)
+		go func() { ran <- gateway.Run(ctx) }()
+		require.Eventually(t, func() bool { return gateway.Addr() != nil }, time.Second, time.Millisecond)
+		cancel()
+
+		require.NoError(t, <-ran)
+		require.NoError(t, gateway.Teardown(context.Background()))
+	})
+}
+
+func TestHTTPStatusCode(t *testing.T) {
+	t.Run("GIVEN misas errors WHEN mapping them THEN should return the status code of their kind", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, mx.HTTPStatusCode(misas.ErrInvalid))
+		require.Equal(t, http.StatusUnauthorized, mx.HTTPStatusCode(misas.ErrUnauthenticated))
+		require.Equal(t, http.StatusForbidden, mx.HTTPStatusCode(misas.ErrUnauthorized))
+		require.Equal(t, http.StatusNotFound, mx.HTTPStatusCode(misas.ErrNotFound))
+		require.Equal(t, http.StatusConflict, mx.HTTPStatusCode(misas.ErrConflict))
+		require.Equal(t, http.StatusGatewayTimeout, mx.HTTPStatusCode(misas.ErrTimeout))
+		require.Equal(t, http.StatusNotImplemented, mx.HTTPStatusCode(misas.ErrNotImplemented))
+		require.Equal(t, http.StatusInternalServerError, mx.HTTPStatusCode(misas.ErrInternal))
+		require.Equal(t, http.StatusInternalServerError, mx.HTTPStatusCode(errors.New("boom")))
+	})
+
+	t.Run("GIVEN timeouts other than misas errors WHEN mapping them THEN should return gateway timeout", func(t *testing.T) {
+		require.Equal(t, http.StatusGatewayTimeout, mx.HTTPStatusCode(context.DeadlineExceeded))
+		require.Equal(t, http.StatusGatewayTimeout, mx.HTTPStatusCode(fmt.Errorf("querying stock: %w", context.DeadlineExceeded)))
+		require.Equal(t, http.StatusGatewayTimeout, mx.HTTPStatusCode(&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}))
+	})
+
+	t.Run("GIVEN other errors WHEN mapping them THEN should return internal server error", func(t *testing.T) {
+		require.Equal(t, http.StatusInternalServerError, mx.HTTPStatusCode(fmt.Errorf("open: %w", fs.ErrNotExist)))
+		require.Equal(t, http.StatusInternalServerError, mx.HTTPStatusCode(fs.ErrExist))
+		require.Equal(t, http.StatusInternalServerError, mx.HTTPStatusCode(context.Canceled))
+	})
+}
@@ -0,0 +1,335 @@
+package mx
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/morebec/misas/misas"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema describing the JSON representation of a message.
+//
+// Schemas are derived by reflection from the Go types of messages, following the rules of encoding/json:
+//   - properties are named after the json tag of fields, and fields tagged "-" are omitted;
+//   - fields are required unless tagged omitempty or omitzero;
+//   - pointers are nullable;
+//   - time.Time is a date-time string and encoding.TextMarshaler implementations are strings;
+//   - fields of embedded structs are promoted to the embedding struct.
+//
+// Fields may be annotated with the following struct tags:
+//   - description: describes the property;
+//   - enum: comma separated list of the allowed values of the property;
+//   - format: format of a string property, such as "email" or "uuid".
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+}
+
+// JSONSchema returns the JSON Schema of a registered message, titled after its type name.
+func (m *MessageRegistry[TN, T]) JSONSchema(tn TN) (*JSONSchema, error) {
+	typ, err := m.resolve(tn)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := jsonSchemaOf(typ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JSON schema of message %q: %w", tn, err)
+	}
+	s.Schema = jsonSchemaDialect
+	s.Title = string(tn)
+
+	return s, nil
+}
+
+// JSONSchemas returns the JSON Schema of every registered message, keyed by type name.
+func (m *MessageRegistry[TN, T]) JSONSchemas() (map[TN]*JSONSchema, error) {
+	schemas := make(map[TN]*JSONSchema)
+	for _, tn := range m.TypeNames() {
+		s, err := m.JSONSchema(tn)
+		if err != nil {
+			return nil, err
+		}
+		schemas[tn] = s
+	}
+
+	return schemas, nil
+}
+
+// DumpJSONSchemas writes the JSON Schema of every registered message into dir as <type name>.schema.json.
+func (m *MessageRegistry[TN, T]) DumpJSONSchemas(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	schemas, err := m.JSONSchemas()
+	if err != nil {
+		return err
+	}
+
+	for tn, s := range schemas {
+		js, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON schema of message %q: %w", tn, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, string(tn)+".schema.json"), js, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DumpJSONSchemas writes the JSON Schema of every message of the CommandRegistry, QueryRegistry and EventRegistry
+// into the commands, queries and events subdirectories of dir.
+func DumpJSONSchemas(dir string) error {
+	return DefaultMessageRegistries().DumpJSONSchemas(dir)
+}
+
+// DumpJSONSchemas writes the JSON Schema of every registered message into the commands, queries and events
+// subdirectories of dir.
+func (r MessageRegistries) DumpJSONSchemas(dir string) error {
+	if err := r.Commands.DumpJSONSchemas(filepath.Join(dir, "commands")); err != nil {
+		return err
+	}
+	if err := r.Queries.DumpJSONSchemas(filepath.Join(dir, "queries")); err != nil {
+		return err
+	}
+
+	return r.Events.DumpJSONSchemas(filepath.Join(dir, "events"))
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// jsonSchemaOf derives the JSON Schema of a Go type, as encoded by encoding/json. It fails on invalid struct tag
+// annotations.
+func jsonSchemaOf(t reflect.Type) (*JSONSchema, error) {
+	return jsonSchemaGenerator{visiting: map[reflect.Type]bool{}}.schema(t)
+}
+
+type jsonSchemaGenerator struct {
+	visiting map[reflect.Type]bool // guards against recursive types
+}
+
+func (g jsonSchemaGenerator) schema(t reflect.Type) (*JSONSchema, error) {
+	if t.Kind() == reflect.Pointer {
+		s, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{AnyOf: []*JSONSchema{s, {Type: "null"}}}, nil
+	}
+
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}, nil
+	case t == rawMessageType:
+		return &JSONSchema{}, nil
+	case implements(t, jsonMarshalerType):
+		return &JSONSchema{}, nil // custom encodings cannot be described by reflection
+	case implements(t, textMarshalerType):
+		return &JSONSchema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && !implements(t.Elem(), jsonMarshalerType) {
+			return &JSONSchema{Type: "string", Format: "byte"}, nil // encoded as base64
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return &JSONSchema{}, nil // interfaces accept any value
+	}
+}
+
+func (g jsonSchemaGenerator) structSchema(t reflect.Type) (*JSONSchema, error) {
+	if g.visiting[t] {
+		return &JSONSchema{Type: "object"}, nil
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	s := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+	required := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, options, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded != timeType {
+				// fields declared by the embedding struct win over promoted ones
+				es, err := g.structSchema(embedded)
+				if err != nil {
+					return nil, err
+				}
+				for pn, ps := range es.Properties {
+					if _, exists := s.Properties[pn]; !exists {
+						s.Properties[pn] = ps
+						// the fields of a nil embedded pointer are omitted
+						required[pn] = f.Type.Kind() != reflect.Pointer && slices.Contains(es.Required, pn)
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		ps, err := g.schema(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		if hasJSONOption(options, "string") && isStringableKind(f.Type) {
+			ps = &JSONSchema{Type: "string"}
+		}
+		if err := annotateJSONSchema(ps, f); err != nil {
+			return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("invalid JSON schema annotation of field %s.%s: %s", t.Name(), f.Name, err))
+		}
+		s.Properties[name] = ps
+		required[name] = !hasJSONOption(options, "omitempty") && !hasJSONOption(options, "omitzero")
+	}
+
+	for name, isRequired := range required {
+		if isRequired {
+			s.Required = append(s.Required, name)
+		}
+	}
+	slices.Sort(s.Required)
+
+	return s, nil
+}
+
+// annotateJSONSchema applies the description, enum and format struct tags of a field to its schema.
+func annotateJSONSchema(s *JSONSchema, f reflect.StructField) error {
+	s.Description = f.Tag.Get("description")
+
+	// annotations other than the description apply to the value of nullable fields, and the items of arrays
+	target, typ := nonNullJSONSchema(s, f.Type)
+	if target.Type == "array" {
+		target, typ = nonNullJSONSchema(target.Items, typ.Elem())
+	}
+
+	if format := f.Tag.Get("format"); format != "" {
+		target.Format = format
+	}
+
+	enum, ok := f.Tag.Lookup("enum")
+	if !ok {
+		return nil
+	}
+	for _, v := range strings.Split(enum, ",") {
+		value, err := parseJSONSchemaEnumValue(typ, strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		target.Enum = append(target.Enum, value)
+	}
+
+	return nil
+}
+
+// nonNullJSONSchema returns the schema of the value of a nullable schema, and its type.
+func nonNullJSONSchema(s *JSONSchema, t reflect.Type) (*JSONSchema, reflect.Type) {
+	if t.Kind() != reflect.Pointer {
+		return s, t
+	}
+	if len(s.AnyOf) != 0 {
+		s = s.AnyOf[0]
+	}
+
+	return s, t.Elem()
+}
+
+func parseJSONSchemaEnumValue(t reflect.Type, v string) (any, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(v, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.ParseUint(v, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return v, nil
+	}
+}
+
+func hasJSONOption(options string, option string) bool {
+	return slices.Contains(strings.Split(options, ","), option)
+}
+
+// isStringableKind reports whether the ",string" json option applies to the given type.
+func isStringableKind(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
@@ -0,0 +1,106 @@
+package mx_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type auditFields struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	RecordedBy string    `json:"recordedBy,omitempty"`
+}
+
+type adjustStockCommand struct {
+	auditFields
+	ProductID string     `json:"productId" format:"uuid" description:"Identifier of the adjusted product."`
+	Reason    string     `json:"reason" enum:"loss,restock"`
+	Quantity  int        `json:"quantity,string"`
+	Priority  *int       `json:"priority,omitempty" enum:"1, 2, 3"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Labels    []string   `json:"labels,omitempty" enum:"fragile,perishable"`
+	Payload   []byte     `json:"payload,omitempty"`
+	Ignored   string     `json:"-"`
+	internal  string
+}
+
+func (adjustStockCommand) TypeName() misas.CommandTypeName { return "inventory.adjust_stock" }
+
+type invalidEnumCommand struct {
+	Quantity int `json:"quantity" enum:"one"`
+}
+
+func (invalidEnumCommand) TypeName() misas.CommandTypeName { return "inventory.invalid_enum" }
+
+func TestMessageRegistry_JSONSchema(t *testing.T) {
+	mx.CommandRegistry.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+
+	t.Run("GIVEN message not registered WHEN generating schema THEN should return an error", func(t *testing.T) {
+		_, err := mx.CommandRegistry.JSONSchema("not_registered")
+		require.Error(t, err)
+	})
+
+	t.Run("GIVEN registered message WHEN generating schema THEN should follow json encoding and annotations", func(t *testing.T) {
+		schema, err := mx.CommandRegistry.JSONSchema("inventory.adjust_stock")
+		require.NoError(t, err)
+
+		require.Equal(t, &mx.JSONSchema{
+			Schema: "https://json-schema.org/draft/2020-12/schema",
+			Title:  "inventory.adjust_stock",
+			Type:   "object",
+			Properties: map[string]*mx.JSONSchema{
+				"recordedAt": {Type: "string", Format: "date-time"},
+				"recordedBy": {Type: "string"},
+				"productId":  {Type: "string", Format: "uuid", Description: "Identifier of the adjusted product."},
+				"reason":     {Type: "string", Enum: []any{"loss", "restock"}},
+				"quantity":   {Type: "string"},
+				"priority":   {AnyOf: []*mx.JSONSchema{{Type: "integer", Enum: []any{int64(1), int64(2), int64(3)}}, {Type: "null"}}},
+				"expiresAt":  {AnyOf: []*mx.JSONSchema{{Type: "string", Format: "date-time"}, {Type: "null"}}},
+				"labels":     {Type: "array", Items: &mx.JSONSchema{Type: "string", Enum: []any{"fragile", "perishable"}}},
+				"payload":    {Type: "string", Format: "byte"},
+			},
+			Required: []string{"expiresAt", "productId", "quantity", "reason", "recordedAt"},
+		}, schema)
+	})
+
+	t.Run("GIVEN invalid enum annotation WHEN generating schemas THEN should return an error", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		registries.Commands.Register(invalidEnumCommand{}.TypeName(), invalidEnumCommand{})
+
+		_, err := registries.Commands.JSONSchema("inventory.invalid_enum")
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, `failed to generate JSON schema of message "inventory.invalid_enum"`)
+
+		_, err = registries.Commands.JSONSchemas()
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorIs(t, registries.DumpJSONSchemas(t.TempDir()), misas.ErrInvalid)
+		_, err = registries.Snapshot()
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		_, err = registries.OpenAPI(mx.OpenAPIInfo{Title: "shop"})
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+
+	t.Run("GIVEN registered messages WHEN dumping schemas THEN should write a file per message", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		registries.Events.Register(stockReservedEvent{}.TypeName(), stockReservedEvent{})
+		registries.Commands.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+		dir := t.TempDir()
+
+		require.NoError(t, registries.DumpJSONSchemas(dir))
+
+		js, err := os.ReadFile(filepath.Join(dir, "commands", "inventory.adjust_stock.schema.json"))
+		require.NoError(t, err)
+		var schema mx.JSONSchema
+		require.NoError(t, json.Unmarshal(js, &schema))
+		require.Equal(t, "inventory.adjust_stock", schema.Title)
+
+		_, err = os.Stat(filepath.Join(dir, "events", "inventory.stock_reserved.schema.json"))
+		require.NoError(t, err)
+	})
+}
@@ -246,7 +246,10 @@ func (r *humanReadableLogRecord) formatMessage(colorOverride *aurora.Color) stri
 }
 
 func (h *humanReadableLogHandler) clone() *humanReadableLogHandler {
+	// the scope column size is updated by Handle, possibly concurrently
+	h.mu.Lock()
 	newH := *h
+	h.mu.Unlock()
 	newH.mu = &sync.Mutex{}
 
 	if len(h.attrs) > 0 {
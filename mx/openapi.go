@@ -0,0 +1,269 @@
+package mx
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
+)
+
+const openAPIVersion = "3.1.0"
+
+// OpenAPIDocument is an OpenAPI 3.1 document describing the commands and queries of a system as exposed by an
+// HTTPGateway, so that clients do not have to guess the shape of their payloads.
+type OpenAPIDocument struct {
+	OpenAPI           string                     `json:"openapi"`
+	Info              OpenAPIInfo                `json:"info"`
+	JSONSchemaDialect string                     `json:"jsonSchemaDialect"`
+	Paths             map[string]OpenAPIPathItem `json:"paths"`
+	Components        OpenAPIComponents          `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIPathItem describes the endpoint of a command or query, only accepting POST requests.
+type OpenAPIPathItem struct {
+	Post *OpenAPIOperation `json:"post"`
+}
+
+// OpenAPIOperation describes the dispatch of a command or query.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags"`
+	RequestBody OpenAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response, or references one of the responses of the components when Ref is set.
+type OpenAPIResponse struct {
+	Ref         string                      `json:"$ref,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *JSONSchema `json:"schema"`
+}
+
+// OpenAPIComponents holds the error responses shared by every operation.
+type OpenAPIComponents struct {
+	Responses map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPI returns the OpenAPI document describing one operation per registered command and query, with the JSON
+// Schema of their payload as request body. The error responses are derived from the misas error kinds, see
+// HTTPStatusCode. It fails if the JSON Schema of a message cannot be generated.
+func (r MessageRegistries) OpenAPI(info OpenAPIInfo) (OpenAPIDocument, error) {
+	return newOpenAPIDocument(info, r, nil, nil)
+}
+
+// OpenAPI returns the OpenAPI document of the commands and queries of the message registries of the system.
+func (sc *SystemConf) OpenAPI() (OpenAPIDocument, error) {
+	if err := sc.registerMessages(); err != nil {
+		return OpenAPIDocument{}, err
+	}
+
+	return sc.registries.OpenAPI(OpenAPIInfo{Title: sc.name, Version: sc.version})
+}
+
+// OpenAPI returns the OpenAPI document of the commands and queries exposed by the gateway.
+func (g *HTTPGateway) OpenAPI(info OpenAPIInfo) (OpenAPIDocument, error) {
+	return newOpenAPIDocument(info, g.registries, g.allowedCommands, g.allowedQueries)
+}
+
+// JSON renders the document as indented JSON.
+func (d OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func newOpenAPIDocument(info OpenAPIInfo, r MessageRegistries, allowedCommands map[misas.CommandTypeName]bool, allowedQueries map[misas.QueryTypeName]bool) (OpenAPIDocument, error) {
+	doc := OpenAPIDocument{
+		OpenAPI:           openAPIVersion,
+		Info:              info,
+		JSONSchemaDialect: jsonSchemaDialect,
+		Paths:             map[string]OpenAPIPathItem{},
+		Components:        OpenAPIComponents{Responses: map[string]OpenAPIResponse{}},
+	}
+
+	errorResponses := map[string]OpenAPIResponse{}
+	for status, response := range openAPIErrorResponses() {
+		name := fmt.Sprintf("Error%d", status)
+		doc.Components.Responses[name] = response
+		errorResponses[strconv.Itoa(status)] = OpenAPIResponse{Ref: "#/components/responses/" + name}
+	}
+
+	commandResponses := map[string]OpenAPIResponse{
+		"200": {Description: "The command was handled, returning the payload of its result.", Content: openAPIJSONContent(&JSONSchema{})},
+		"204": {Description: "The command was handled, returning no payload."},
+	}
+	queryResponses := map[string]OpenAPIResponse{
+		"200": {Description: "The query was handled, returning the payload of its result.", Content: openAPIJSONContent(&JSONSchema{})},
+		"204": {Description: "The query was handled, returning no payload."},
+	}
+	if err := addOpenAPIOperations(doc.Paths, "command", r.Commands, allowedCommands, commandResponses, errorResponses); err != nil {
+		return OpenAPIDocument{}, err
+	}
+	if err := addOpenAPIOperations(doc.Paths, "query", r.Queries, allowedQueries, queryResponses, errorResponses); err != nil {
+		return OpenAPIDocument{}, err
+	}
+
+	return doc, nil
+}
+
+func addOpenAPIOperations[TN ~string, T any](paths map[string]OpenAPIPathItem, kind string, r *MessageRegistry[TN, T], allowed map[TN]bool, responses map[string]OpenAPIResponse, errorResponses map[string]OpenAPIResponse) error {
+	tag := map[string]string{"command": "commands", "query": "queries"}[kind]
+	for _, tn := range r.TypeNames() {
+		if allowed != nil && !allowed[tn] {
+			continue
+		}
+		typ, _ := r.Lookup(tn)
+		schema, err := jsonSchemaOf(typ)
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON schema of %s %q: %w", kind, tn, err)
+		}
+		schema.Title = string(tn)
+
+		operation := &OpenAPIOperation{
+			OperationID: kind + "." + string(tn),
+			Summary:     fmt.Sprintf("Dispatches the %s %s", kind, tn),
+			Tags:        []string{tag},
+			RequestBody: OpenAPIRequestBody{Content: openAPIJSONContent(schema)},
+			Responses:   map[string]OpenAPIResponse{},
+		}
+		for status, response := range responses {
+			operation.Responses[status] = response
+		}
+		for status, response := range errorResponses {
+			operation.Responses[status] = response
+		}
+		paths["/"+tag+"/"+url.PathEscape(string(tn))] = OpenAPIPathItem{Post: operation}
+	}
+
+	return nil
+}
+
+// openAPIErrorKinds are the error kinds reported by an HTTPGateway.
+var openAPIErrorKinds = []misas.ErrorKind{
+	misas.ErrorKindInvalid,
+	misas.ErrorKindUnauthenticated,
+	misas.ErrorKindUnauthorized,
+	misas.ErrorKindNotFound,
+	misas.ErrorKindConflict,
+	misas.ErrorKindTimeout,
+	misas.ErrorKindNotImplemented,
+	misas.ErrorKindInternal,
+	errHTTPUnsupportedMediaType.Kind(),
+	errHTTPRequestTooLarge.Kind(),
+}
+
+// openAPIErrorResponses returns the error responses of an HTTPGateway by status code, each listing the error kinds
+// reported with its status code.
+func openAPIErrorResponses() map[int]OpenAPIResponse {
+	kindsByStatus := map[int][]string{}
+	for _, kind := range openAPIErrorKinds {
+		status := HTTPStatusCode(misas.NewError(kind))
+		kindsByStatus[status] = append(kindsByStatus[status], string(kind))
+	}
+
+	responses := make(map[int]OpenAPIResponse, len(kindsByStatus))
+	for status, kinds := range kindsByStatus {
+		slices.Sort(kinds)
+		responses[status] = OpenAPIResponse{
+			Description: fmt.Sprintf("%s: the request failed with an error of kind %s.", http.StatusText(status), strings.Join(kinds, " or ")),
+			Content: openAPIJSONContent(&JSONSchema{
+				Type:     "object",
+				Required: []string{"error"},
+				Properties: map[string]*JSONSchema{
+					"error": {
+						Type:     "object",
+						Required: []string{"kind", "message"},
+						Properties: map[string]*JSONSchema{
+							"kind":    {Type: "string", Enum: lo.ToAnySlice(kinds)},
+							"code":    {Type: "string"},
+							"message": {Type: "string"},
+						},
+					},
+				},
+			}),
+		}
+	}
+
+	return responses
+}
+
+func openAPIJSONContent(schema *JSONSchema) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{ContentTypeJSON: {Schema: schema}}
+}
+
+//go:embed openapi_docs.html
+var openAPIDocsPage []byte
+
+// OpenAPIHandler is an http.Handler serving an OpenAPI document at GET /openapi.json and, when enabled, a
+// documentation page rendering it at GET /docs. Being meant for development, the documentation page is disabled by
+// default.
+type OpenAPIHandler struct {
+	doc      []byte
+	docsPage bool
+}
+
+// NewOpenAPIHandler returns a handler serving the given document.
+func NewOpenAPIHandler(doc OpenAPIDocument) *OpenAPIHandler {
+	js, err := doc.JSON()
+	if err != nil {
+		panic(fmt.Sprintf("openapi handler: failed to marshal document: %s", err))
+	}
+
+	return &OpenAPIHandler{doc: js}
+}
+
+// OpenAPIHandler returns a handler serving the OpenAPI document of the system, with its documentation page enabled
+// in debug mode only.
+func (sc *SystemConf) OpenAPIHandler() (*OpenAPIHandler, error) {
+	doc, err := sc.OpenAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOpenAPIHandler(doc).WithDocsPage(sc.debug), nil
+}
+
+// WithDocsPage enables or disables the documentation page.
+func (h *OpenAPIHandler) WithDocsPage(enabled bool) *OpenAPIHandler {
+	h.docsPage = enabled
+
+	return h
+}
+
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/openapi.json"):
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		_, _ = w.Write(h.doc)
+	case strings.HasSuffix(r.URL.Path, "/docs") && h.docsPage:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(openAPIDocsPage)
+	default:
+		http.NotFound(w, r)
+	}
+}
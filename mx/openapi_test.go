@@ -0,0 +1,160 @@
+package mx_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageRegistries_OpenAPI(t *testing.T) {
+	registries := mx.MessageRegistries{
+		Commands: mx.NewMessageRegistry[misas.CommandTypeName, misas.Command](),
+		Events:   mx.NewMessageRegistry[misas.EventTypeName, misas.Event](),
+		Queries:  mx.NewMessageRegistry[misas.QueryTypeName, misas.Query](),
+	}
+	registries.Commands.Register(reserveStockCommand{}.TypeName(), reserveStockCommand{})
+	registries.Queries.Register(stockAvailabilityQuery{}.TypeName(), stockAvailabilityQuery{})
+
+	t.Run("GIVEN registered commands and queries WHEN generating OpenAPI THEN should describe one operation per message", func(t *testing.T) {
+		doc, err := registries.OpenAPI(mx.OpenAPIInfo{Title: "shop", Version: "1.2.0"})
+		require.NoError(t, err)
+
+		require.Equal(t, "3.1.0", doc.OpenAPI)
+		require.Equal(t, mx.OpenAPIInfo{Title: "shop", Version: "1.2.0"}, doc.Info)
+		require.Len(t, doc.Paths, 2)
+
+		command := doc.Paths["/commands/inventory.reserve_stock"].Post
+		require.NotNil(t, command)
+		require.Equal(t, "command.inventory.reserve_stock", command.OperationID)
+		require.Equal(t, []string{"commands"}, command.Tags)
+		require.Equal(t, &mx.JSONSchema{
+			Title: "inventory.reserve_stock",
+			Type:  "object",
+			Properties: map[string]*mx.JSONSchema{
+				"productId": {Type: "string"},
+				"quantity":  {Type: "integer"},
+			},
+			Required: []string{"productId", "quantity"},
+		}, command.RequestBody.Content[mx.ContentTypeJSON].Schema)
+		require.Contains(t, command.Responses, "200")
+		require.Contains(t, command.Responses, "204")
+
+		query := doc.Paths["/queries/inventory.stock_availability"].Post
+		require.NotNil(t, query)
+		require.Equal(t, []string{"queries"}, query.Tags)
+		require.Contains(t, query.Responses, "200")
+		require.Contains(t, query.Responses, "204")
+	})
+
+	t.Run("GIVEN misas error kinds WHEN generating OpenAPI THEN should reference an error response per status code", func(t *testing.T) {
+		doc, err := registries.OpenAPI(mx.OpenAPIInfo{Title: "shop", Version: "1.2.0"})
+		require.NoError(t, err)
+		command := doc.Paths["/commands/inventory.reserve_stock"].Post
+
+		for _, status := range []string{"400", "401", "403", "404", "409", "413", "415", "500", "501", "504"} {
+			require.Equal(t, mx.OpenAPIResponse{Ref: "#/components/responses/Error" + status}, command.Responses[status], status)
+			require.Contains(t, doc.Components.Responses, "Error"+status)
+		}
+
+		conflict := doc.Components.Responses["Error409"]
+		require.Equal(t, "Conflict: the request failed with an error of kind conflict.", conflict.Description)
+		kind := conflict.Content[mx.ContentTypeJSON].Schema.Properties["error"].Properties["kind"]
+		require.Equal(t, []any{"conflict"}, kind.Enum)
+	})
+
+	t.Run("GIVEN document WHEN rendering it as JSON THEN should be valid JSON", func(t *testing.T) {
+		doc, err := registries.OpenAPI(mx.OpenAPIInfo{Title: "shop", Version: "1.2.0"})
+		require.NoError(t, err)
+		js, err := doc.JSON()
+		require.NoError(t, err)
+
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(js, &decoded))
+		require.Equal(t, "https://json-schema.org/draft/2020-12/schema", decoded["jsonSchemaDialect"])
+	})
+}
+
+func TestHTTPGateway_OpenAPI(t *testing.T) {
+	t.Run("GIVEN allow-lists WHEN generating OpenAPI THEN should only describe the exposed messages", func(t *testing.T) {
+		registries := mx.MessageRegistries{
+			Commands: mx.NewMessageRegistry[misas.CommandTypeName, misas.Command](),
+			Events:   mx.NewMessageRegistry[misas.EventTypeName, misas.Event](),
+			Queries:  mx.NewMessageRegistry[misas.QueryTypeName, misas.Query](),
+		}
+		registries.Commands.Register(reserveStockCommand{}.TypeName(), reserveStockCommand{})
+		registries.Commands.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+		registries.Queries.Register(stockAvailabilityQuery{}.TypeName(), stockAvailabilityQuery{})
+
+		gateway := mx.NewHTTPGateway("api", ":0", misas.NewInMemoryCommandBus(), misas.NewInMemoryQueryBus()).
+			WithMessageRegistries(registries).
+			WithAllowedCommands(reserveStockCommand{}.TypeName()).
+			WithAllowedQueries()
+
+		doc, err := gateway.OpenAPI(mx.OpenAPIInfo{Title: "shop", Version: "1.0.0"})
+		require.NoError(t, err)
+
+		require.Len(t, doc.Paths, 1)
+		require.Contains(t, doc.Paths, "/commands/inventory.reserve_stock")
+	})
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("GIVEN document WHEN requesting openapi.json THEN should serve it", func(t *testing.T) {
+		doc := mx.OpenAPIDocument{OpenAPI: "3.1.0", Info: mx.OpenAPIInfo{Title: "shop", Version: "1.0.0"}}
+		js, err := doc.JSON()
+		require.NoError(t, err)
+
+		rec := get(mx.NewOpenAPIHandler(doc), "/openapi.json")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, mx.ContentTypeJSON, rec.Header().Get("Content-Type"))
+		require.JSONEq(t, string(js), rec.Body.String())
+	})
+
+	t.Run("GIVEN docs page disabled WHEN requesting it THEN should return not found", func(t *testing.T) {
+		rec := get(mx.NewOpenAPIHandler(mx.OpenAPIDocument{}), "/docs")
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GIVEN docs page enabled WHEN requesting it THEN should serve the documentation page", func(t *testing.T) {
+		rec := get(mx.NewOpenAPIHandler(mx.OpenAPIDocument{}).WithDocsPage(true), "/docs")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		require.Contains(t, rec.Body.String(), `fetch("openapi.json")`)
+	})
+
+	t.Run("GIVEN system WHEN getting its handler THEN should enable the docs page in debug mode only", func(t *testing.T) {
+		debug, err := mx.NewSystem("shop").WithDebug(true).OpenAPIHandler()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, get(debug, "/docs").Code)
+
+		production, err := mx.NewSystem("shop").WithDebug(false).OpenAPIHandler()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, get(production, "/docs").Code)
+	})
+
+	t.Run("GIVEN handler mounted on a gateway WHEN requesting the document THEN should serve it", func(t *testing.T) {
+		gateway := mx.NewHTTPGateway("api", ":0", misas.NewInMemoryCommandBus(), misas.NewInMemoryQueryBus())
+		doc, err := gateway.OpenAPI(mx.OpenAPIInfo{Title: "shop"})
+		require.NoError(t, err)
+		gateway.WithHandler("GET /api/", http.StripPrefix("/api", mx.NewOpenAPIHandler(doc)))
+
+		rec := get(gateway, "/api/openapi.json")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"title": "shop"`)
+	})
+}
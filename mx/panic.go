@@ -0,0 +1,25 @@
+package mx
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/morebec/misas/misas"
+)
+
+// PanicError is the cause of the misas.ErrInternal errors produced when a panic is recovered
+// from an application subsystem or a message handler.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e PanicError) Error() string { return fmt.Sprintf("panic: %v", e.Value) }
+
+// newPanicError converts a recovered panic value into a misas.ErrInternal carrying the value and
+// the stack trace of the panicking goroutine. It must be called from the deferred recovering function.
+func newPanicError(recovered any) misas.Error {
+	cause := PanicError{Value: recovered, Stack: debug.Stack()}
+
+	return misas.ErrInternal.WithCause(cause).WithMessage(cause.Error())
+}
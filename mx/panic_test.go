@@ -0,0 +1,163 @@
+package mx_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/morebec/misas/mxtest"
+	"github.com/stretchr/testify/require"
+)
+
+type funcApplicationSubsystem struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (a funcApplicationSubsystem) Name() string                     { return a.name }
+func (a funcApplicationSubsystem) Initialize(context.Context) error { return nil }
+func (a funcApplicationSubsystem) Teardown(context.Context) error   { return nil }
+func (a funcApplicationSubsystem) Run(ctx context.Context) error    { return a.run(ctx) }
+
+type hookRecorderPlugin struct {
+	mu    sync.Mutex
+	hooks []mx.SystemPluginHook
+}
+
+func (p *hookRecorderPlugin) OnHook(_ context.Context, hook mx.SystemPluginHook) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks = append(p.hooks, hook)
+	return nil
+}
+
+func (p *hookRecorderPlugin) Hooks() []mx.SystemPluginHook {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]mx.SystemPluginHook(nil), p.hooks...)
+}
+
+func (p *hookRecorderPlugin) Name() string { return "test.recorder" }
+
+// panicRecoveredHooks filters the PanicRecoveredHook of dispatched hooks.
+func panicRecoveredHooks(hooks []mx.SystemPluginHook) []mx.PanicRecoveredHook {
+	var recovered []mx.PanicRecoveredHook
+	for _, h := range hooks {
+		if h, ok := h.(mx.PanicRecoveredHook); ok {
+			recovered = append(recovered, h)
+		}
+	}
+
+	return recovered
+}
+
+// requirePanicError asserts an error is a misas.ErrInternal caused by the recovery of the given panic value.
+func requirePanicError(t *testing.T, err error, value any) {
+	t.Helper()
+	require.ErrorIs(t, err, misas.ErrInternal)
+	var panicErr mx.PanicError
+	require.ErrorAs(t, err.(misas.Error).Cause(), &panicErr)
+	require.Equal(t, value, panicErr.Value)
+	require.NotEmpty(t, panicErr.Stack)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	t.Run("GIVEN panicking command handler WHEN handling command THEN should return internal error and dispatch hook", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		system := mx.NewSystem("test").WithPlugin(recorder)
+		system.WithBusinessSubsystem(
+			mx.NewBusinessSubsystem("panicking").
+				WithCommandHandler(mxtest.MockCommand{}, misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+					panic("boom")
+				})),
+		)
+
+		var result misas.CommandResult
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			result = system.CommandBus().HandleCommand(ctx, mxtest.MockCommand{})
+			return nil
+		}})
+		require.NoError(t, err)
+
+		requirePanicError(t, result.Error, "boom")
+
+		recovered := panicRecoveredHooks(recorder.Hooks())
+		require.Len(t, recovered, 1)
+		require.Equal(t, "panicking", recovered[0].SubsystemName)
+		require.Equal(t, "mxtest.MockCommand", recovered[0].MessageTypeName)
+		require.Equal(t, "boom", recovered[0].Value)
+		require.NotEmpty(t, recovered[0].Stack)
+		require.Equal(t, result.Error, recovered[0].Error)
+		require.False(t, recovered[0].RecoveredAt.IsZero())
+	})
+
+	t.Run("GIVEN panicking query handler WHEN handling query THEN should return internal error and dispatch hook", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		system := mx.NewSystem("test").WithPlugin(recorder)
+		system.WithQuerySubsystem(
+			mx.NewQuerySubsystem("panicking").
+				WithQueryHandler(stockLevelQuery{}, misas.QueryHandlerFunc(func(context.Context, misas.Query) misas.QueryResult {
+					panic("boom")
+				})),
+		)
+
+		var result misas.QueryResult
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			result = system.QueryBus().HandleQuery(ctx, stockLevelQuery{})
+			return nil
+		}})
+		require.NoError(t, err)
+
+		requirePanicError(t, result.Error, "boom")
+
+		recovered := panicRecoveredHooks(recorder.Hooks())
+		require.Len(t, recovered, 1)
+		require.Equal(t, "panicking", recovered[0].SubsystemName)
+		require.Equal(t, "inventory.stock_level", recovered[0].MessageTypeName)
+		require.Equal(t, result.Error, recovered[0].Error)
+	})
+
+	t.Run("GIVEN panicking event handler WHEN publishing event THEN should return internal error and dispatch hook", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		system := mx.NewSystem("test").WithPlugin(recorder)
+		events := system.EventBus("inventory.events")
+		system.WithBusinessSubsystem(
+			mx.NewBusinessSubsystem("panicking").
+				WithEventHandlers("inventory.events", misas.EventHandlerFunc(func(context.Context, misas.Event) error {
+					panic(errors.New("boom"))
+				})),
+		)
+
+		var publishErr error
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			publishErr = events.Publish(ctx, stockAdjustedEvent{})
+			return nil
+		}})
+		require.NoError(t, err)
+
+		requirePanicError(t, publishErr, errors.New("boom"))
+
+		recovered := panicRecoveredHooks(recorder.Hooks())
+		require.Len(t, recovered, 1)
+		require.Equal(t, "panicking", recovered[0].SubsystemName)
+		require.Equal(t, "inventory.stock_adjusted", recovered[0].MessageTypeName)
+		require.Equal(t, publishErr, recovered[0].Error)
+	})
+	t.Run("GIVEN panicking supervised application WHEN running THEN should report the panic as a failure and dispatch hook", func(t *testing.T) {
+		supervisor := mx.NewSupervisor().WithApplicationSubsystem(
+			funcApplicationSubsystem{name: "panicking", run: func(context.Context) error { panic("boom") }},
+			&mx.SupervisionOptions{RestartPolicy: mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyNo)},
+		)
+		hooks := runSupervisedApplications(t, supervisor, mx.SupervisedApplicationStateExited)
+
+		requirePanicError(t, supervisor.Status()[0].LastError, "boom")
+
+		recovered := panicRecoveredHooks(hooks)
+		require.Len(t, recovered, 1)
+		require.Equal(t, "panicking", recovered[0].SubsystemName)
+		require.Empty(t, recovered[0].MessageTypeName)
+	})
+}
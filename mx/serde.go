@@ -4,34 +4,192 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
 	"reflect"
+	"slices"
+	"sync"
 )
 
+// CommandRegistry, EventRegistry and QueryRegistry are the DefaultMessageRegistries. Systems register the messages of
+// their subsystems with them when they run or when their registries are used, rather than as handlers are added.
 var (
-	CommandRegistry = newMessageRegistry[misas.CommandTypeName, misas.Command]()
-	EventRegistry   = newMessageRegistry[misas.EventTypeName, misas.Event]()
-	QueryRegistry   = newMessageRegistry[misas.QueryTypeName, misas.Query]()
+	CommandRegistry = NewMessageRegistry[misas.CommandTypeName, misas.Command]()
+	EventRegistry   = NewMessageRegistry[misas.EventTypeName, misas.Event]()
+	QueryRegistry   = NewMessageRegistry[misas.QueryTypeName, misas.Query]()
 )
 
+// MessageRegistries groups the registries of the commands, events and queries of a system.
+type MessageRegistries struct {
+	Commands *MessageRegistry[misas.CommandTypeName, misas.Command]
+	Events   *MessageRegistry[misas.EventTypeName, misas.Event]
+	Queries  *MessageRegistry[misas.QueryTypeName, misas.Query]
+}
+
+// DefaultMessageRegistries returns the package level CommandRegistry, EventRegistry and QueryRegistry,
+// used by systems unless configured otherwise.
+func DefaultMessageRegistries() MessageRegistries {
+	return MessageRegistries{Commands: CommandRegistry, Events: EventRegistry, Queries: QueryRegistry}
+}
+
+// NewMessageRegistries returns empty registries, isolated from the package level ones.
+func NewMessageRegistries() MessageRegistries {
+	return MessageRegistries{
+		Commands: NewMessageRegistry[misas.CommandTypeName, misas.Command](),
+		Events:   NewMessageRegistry[misas.EventTypeName, misas.Event](),
+		Queries:  NewMessageRegistry[misas.QueryTypeName, misas.Query](),
+	}
+}
+
+// MessageRegistry maps the type names of messages to their Go types. It is safe for concurrent use.
 type MessageRegistry[TN ~string, T any] struct {
+	mu       sync.RWMutex
 	messages map[TN]reflect.Type
+	names    map[reflect.Type][]TN
+
+	codec      Codec            // encodes payloads, JSON if nil
+	typeCodecs map[TN]Codec     // overrides codec by message type
+	codecs     map[string]Codec // configured codecs by content type, decoding payloads along the builtin ones
+
+	versions            map[TN]int // current schema version by message type
+	upcasters           map[TN]map[int]upcaster
+	deprecatedVersions  map[TN]map[int]bool
+	onDeprecatedVersion func(tn TN, version int)
 }
 
-func newMessageRegistry[TN ~string, T any]() *MessageRegistry[TN, T] {
+func NewMessageRegistry[TN ~string, T any]() *MessageRegistry[TN, T] {
 	return &MessageRegistry[TN, T]{
-		messages: make(map[TN]reflect.Type),
+		messages:   make(map[TN]reflect.Type),
+		names:      make(map[reflect.Type][]TN),
+		typeCodecs: make(map[TN]Codec),
+		codecs:     make(map[string]Codec),
+
+		versions:           make(map[TN]int),
+		upcasters:          make(map[TN]map[int]upcaster),
+		deprecatedVersions: make(map[TN]map[int]bool),
 	}
 }
 
+// Register registers the Go type of the prototype under the given type name, replacing the Go type previously
+// registered under it if any. Use TryRegister or MustRegister to detect conflicting registrations.
 func (m *MessageRegistry[TN, T]) Register(tn TN, prototype T) {
+	if err := m.register(tn, prototype, true); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister registers the Go type of the prototype under the given type name. Registering a type name again with
+// the same Go type has no effect, while registering it with a different Go type returns a misas.ErrConflict.
+func (m *MessageRegistry[TN, T]) TryRegister(tn TN, prototype T) error {
+	return m.register(tn, prototype, false)
+}
+
+// MustRegister is like TryRegister but panics if the type name is already registered for a different Go type.
+func (m *MessageRegistry[TN, T]) MustRegister(tn TN, prototype T) {
+	if err := m.TryRegister(tn, prototype); err != nil {
+		panic(err)
+	}
+}
+
+func (m *MessageRegistry[TN, T]) register(tn TN, prototype T, replace bool) error {
 	t := reflect.TypeOf(prototype)
+	if t == nil {
+		return misas.ErrInvalid.WithMessage(fmt.Sprintf("cannot register message %q: prototype is nil", tn))
+	}
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem() // store underlying type
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if registered, exists := m.messages[tn]; exists {
+		if registered == t {
+			return nil
+		}
+		if !replace {
+			return misas.ErrConflict.WithMessage(fmt.Sprintf("message %q is already registered for type %s, cannot register it for type %s", tn, registered, t))
+		}
+		m.names[registered] = slices.DeleteFunc(m.names[registered], func(name TN) bool { return name == tn })
+		if len(m.names[registered]) == 0 {
+			delete(m.names, registered)
+		}
+	}
 	m.messages[tn] = t
+	m.names[t] = append(m.names[t], tn)
+	m.versions[tn] = schemaVersionOf(prototype)
+
+	return nil
 }
 
-func (m *MessageRegistry[TN, T]) Clear() { m.messages = make(map[TN]reflect.Type, len(m.messages)) }
+// TypeNames returns the registered type names, sorted.
+func (m *MessageRegistry[TN, T]) TypeNames() []TN {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := lo.Keys(m.messages)
+	slices.Sort(names)
+
+	return names
+}
+
+// Lookup returns the Go type registered under the given type name.
+func (m *MessageRegistry[TN, T]) Lookup(tn TN) (reflect.Type, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, found := m.messages[tn]
+	return t, found
+}
+
+// TypeNameOf returns the type name under which the Go type of v is registered. When the type is registered under
+// several names, the name reported by the TypeName method of v is used if registered, otherwise a misas.ErrConflict
+// is returned. A misas.ErrNotFound is returned if the type is not registered.
+func (m *MessageRegistry[TN, T]) TypeNameOf(v any) (TN, error) {
+	var zero TN
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return zero, misas.ErrNotFound.WithMessage("no message is registered for a nil value")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := m.names[t]
+	switch {
+	case len(names) == 1:
+		return names[0], nil
+	case len(names) == 0:
+		return zero, misas.ErrNotFound.WithMessage(fmt.Sprintf("no message is registered for type %s", t))
+	}
+
+	if typed, ok := v.(interface{ TypeName() TN }); ok && slices.Contains(names, typed.TypeName()) {
+		return typed.TypeName(), nil
+	}
+
+	return zero, misas.ErrConflict.WithMessage(fmt.Sprintf("type %s is registered under several messages: %v", t, names))
+}
+
+// Clear resets the registry as if it was just created, removing its messages along with their codecs, schema
+// versions, upcasters and deprecated versions.
+func (m *MessageRegistry[TN, T]) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = make(map[TN]reflect.Type, len(m.messages))
+	m.names = make(map[reflect.Type][]TN, len(m.names))
+
+	m.codec = nil
+	m.typeCodecs = make(map[TN]Codec)
+	m.codecs = make(map[string]Codec)
+
+	m.versions = make(map[TN]int, len(m.versions))
+	m.upcasters = make(map[TN]map[int]upcaster)
+	m.deprecatedVersions = make(map[TN]map[int]bool)
+	m.onDeprecatedVersion = nil
+}
 
 func (m *MessageRegistry[TN, T]) UnmarshalFromJSON(tn TN, js []byte) (T, error) {
 	var zero T
@@ -58,7 +216,7 @@ func (m *MessageRegistry[TN, T]) UnmarshalFromJSON(tn TN, js []byte) (T, error)
 }
 
 func (m *MessageRegistry[TN, T]) resolve(tn TN) (reflect.Type, error) {
-	typ, found := m.messages[tn]
+	typ, found := m.Lookup(tn)
 	if !found {
 		return nil, fmt.Errorf("unresolved message %q", tn)
 	}
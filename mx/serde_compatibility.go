@@ -0,0 +1,445 @@
+package mx
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/morebec/misas/misas"
+)
+
+// RegistrySnapshot records the shape of the messages of MessageRegistries, to detect changes breaking the producers
+// and consumers of these messages, see CheckCompatibility.
+//
+// Snapshots are typically committed alongside the code and compared against the current build in tests:
+//
+//	report, err := registries.CheckSnapshotCompatibility("testdata/messages.snapshot.json")
+//	require.NoError(t, err)
+//	require.NoError(t, report.Check(mx.BackwardCompatible))
+type RegistrySnapshot struct {
+	Commands map[string]MessageSnapshot `json:"commands"`
+	Queries  map[string]MessageSnapshot `json:"queries"`
+	Events   map[string]MessageSnapshot `json:"events"`
+}
+
+// MessageSnapshot records the shape of a message type as its JSON Schema.
+type MessageSnapshot struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	Schema        *JSONSchema `json:"schema"`
+}
+
+// Snapshot records the shape of every registered message. It fails if the JSON Schema of a message cannot be generated.
+func (r MessageRegistries) Snapshot() (RegistrySnapshot, error) {
+	commands, err := r.Commands.snapshot()
+	if err != nil {
+		return RegistrySnapshot{}, err
+	}
+	queries, err := r.Queries.snapshot()
+	if err != nil {
+		return RegistrySnapshot{}, err
+	}
+	events, err := r.Events.snapshot()
+	if err != nil {
+		return RegistrySnapshot{}, err
+	}
+
+	return RegistrySnapshot{Commands: commands, Queries: queries, Events: events}, nil
+}
+
+func (m *MessageRegistry[TN, T]) snapshot() (map[string]MessageSnapshot, error) {
+	snapshot := map[string]MessageSnapshot{}
+	for _, tn := range m.TypeNames() {
+		typ, found := m.Lookup(tn)
+		if !found {
+			continue // cleared concurrently
+		}
+		schema, err := jsonSchemaOf(typ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate JSON schema of message %q: %w", tn, err)
+		}
+		version, _ := m.SchemaVersion(tn)
+		snapshot[string(tn)] = MessageSnapshot{SchemaVersion: version, Schema: schema}
+	}
+
+	return snapshot, nil
+}
+
+// WriteSnapshot writes a snapshot of every registered message to a JSON file.
+func (r MessageRegistries) WriteSnapshot(path string) error {
+	snapshot, err := r.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	js, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(js, '\n'), 0o644)
+}
+
+// ReadRegistrySnapshot reads a snapshot written by MessageRegistries.WriteSnapshot.
+func ReadRegistrySnapshot(path string) (RegistrySnapshot, error) {
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return RegistrySnapshot{}, err
+	}
+
+	var snapshot RegistrySnapshot
+	if err := json.Unmarshal(js, &snapshot); err != nil {
+		return RegistrySnapshot{}, misas.ErrInvalid.WithMessage(fmt.Sprintf("invalid registry snapshot %q: %s", path, err))
+	}
+
+	return snapshot, nil
+}
+
+// CheckSnapshotCompatibility compares the registered messages against the snapshot of a file.
+func (r MessageRegistries) CheckSnapshotCompatibility(path string) (CompatibilityReport, error) {
+	previous, err := ReadRegistrySnapshot(path)
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+
+	current, err := r.Snapshot()
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+
+	return CheckCompatibility(previous, current), nil
+}
+
+// Compatibility classifies a change of the shape of messages. Backward compatible changes let consumers of the new
+// shape read messages of the previous shape, such as events already stored. Forward compatible changes let consumers of
+// the previous shape read messages of the new shape, such as services not yet upgraded.
+type Compatibility int
+
+const (
+	FullyCompatible Compatibility = iota
+	BackwardCompatible
+	ForwardCompatible
+	Breaking
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case FullyCompatible:
+		return "fully compatible"
+	case BackwardCompatible:
+		return "backward compatible"
+	case ForwardCompatible:
+		return "forward compatible"
+	default:
+		return "breaking"
+	}
+}
+
+// satisfies reports whether a change of this compatibility is allowed under the required compatibility.
+func (c Compatibility) satisfies(required Compatibility) bool {
+	return c == FullyCompatible || c == required || required == Breaking
+}
+
+// combine returns the compatibility of two changes made together.
+func (c Compatibility) combine(other Compatibility) Compatibility {
+	switch {
+	case c == other || other == FullyCompatible:
+		return c
+	case c == FullyCompatible:
+		return other
+	default:
+		return Breaking
+	}
+}
+
+// CompatibilityChange is a change of the shape of a message.
+type CompatibilityChange struct {
+	// MessageKind is either "command", "query" or "event".
+	MessageKind string
+	TypeName    string
+	// Path locates the changed property within the message, e.g. "lines[].quantity", empty for the message itself.
+	Path          string
+	Description   string
+	Compatibility Compatibility
+}
+
+func (c CompatibilityChange) String() string {
+	location := c.TypeName
+	if c.Path != "" {
+		location += "." + c.Path
+	}
+
+	return fmt.Sprintf("%s %s: %s (%s)", c.MessageKind, location, c.Description, c.Compatibility)
+}
+
+// CompatibilityReport lists the changes between two registry snapshots.
+type CompatibilityReport struct {
+	Changes []CompatibilityChange
+}
+
+// Compatibility returns the compatibility of all the changes together.
+func (r CompatibilityReport) Compatibility() Compatibility {
+	compatibility := FullyCompatible
+	for _, c := range r.Changes {
+		compatibility = compatibility.combine(c.Compatibility)
+	}
+
+	return compatibility
+}
+
+// Check returns a misas.ErrConflict listing the changes not satisfying the required compatibility.
+func (r CompatibilityReport) Check(required Compatibility) error {
+	var violations []string
+	for _, c := range r.Changes {
+		if !c.Compatibility.satisfies(required) {
+			violations = append(violations, c.String())
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return misas.ErrConflict.WithMessage(fmt.Sprintf("message changes are not %s:\n  %s", required, strings.Join(violations, "\n  ")))
+}
+
+// CheckCompatibility compares the shape of the messages of two snapshots:
+//   - removing a message type is breaking, and renaming a type name is reported as such;
+//   - removing or renaming a field is breaking, as is changing its type;
+//   - adding an optional field is fully compatible, while adding a required one is forward compatible;
+//   - making a field optional, nullable, widening it from integer to number or allowing more enum values is backward
+//     compatible, and the reverse changes are forward compatible.
+func CheckCompatibility(previous, current RegistrySnapshot) CompatibilityReport {
+	var changes []CompatibilityChange
+	changes = append(changes, compareMessageSnapshots("command", previous.Commands, current.Commands)...)
+	changes = append(changes, compareMessageSnapshots("query", previous.Queries, current.Queries)...)
+	changes = append(changes, compareMessageSnapshots("event", previous.Events, current.Events)...)
+
+	return CompatibilityReport{Changes: changes}
+}
+
+func compareMessageSnapshots(kind string, previous, current map[string]MessageSnapshot) []CompatibilityChange {
+	var changes []CompatibilityChange
+	change := func(tn, path, description string, compatibility Compatibility) {
+		changes = append(changes, CompatibilityChange{
+			MessageKind:   kind,
+			TypeName:      tn,
+			Path:          path,
+			Description:   description,
+			Compatibility: compatibility,
+		})
+	}
+
+	var removed, added []string
+	for _, tn := range slices.Sorted(maps.Keys(previous)) {
+		if _, found := current[tn]; !found {
+			removed = append(removed, tn)
+		}
+	}
+	for _, tn := range slices.Sorted(maps.Keys(current)) {
+		if _, found := previous[tn]; !found {
+			added = append(added, tn)
+		}
+	}
+
+	for _, tn := range removed {
+		// a type name is deemed renamed when a new type name has the same shape
+		renamed := slices.IndexFunc(added, func(newTN string) bool {
+			return equivalentJSONSchemas(previous[tn].Schema, current[newTN].Schema)
+		})
+		if renamed < 0 {
+			change(tn, "", "message removed", Breaking)
+			continue
+		}
+		change(tn, "", fmt.Sprintf("type name renamed to %q", added[renamed]), Breaking)
+		added = slices.Delete(added, renamed, renamed+1)
+	}
+	for _, tn := range added {
+		change(tn, "", "message added", FullyCompatible)
+	}
+
+	for _, tn := range slices.Sorted(maps.Keys(previous)) {
+		cur, found := current[tn]
+		if !found {
+			continue
+		}
+		prev := previous[tn]
+
+		if cur.SchemaVersion < prev.SchemaVersion {
+			change(tn, "", fmt.Sprintf("schema version decreased from %d to %d", prev.SchemaVersion, cur.SchemaVersion), Breaking)
+		}
+		for _, c := range compareJSONSchemas("", prev.Schema, cur.Schema) {
+			change(tn, c.Path, c.Description, c.Compatibility)
+		}
+	}
+
+	return changes
+}
+
+func equivalentJSONSchemas(a, b *JSONSchema) bool {
+	return len(compareJSONSchemas("", a, b)) == 0
+}
+
+// compareJSONSchemas lists the changes between two schemas generated by jsonSchemaOf. Descriptions are ignored.
+func compareJSONSchemas(path string, previous, current *JSONSchema) []CompatibilityChange {
+	var changes []CompatibilityChange
+	change := func(path, description string, compatibility Compatibility) {
+		changes = append(changes, CompatibilityChange{Path: path, Description: description, Compatibility: compatibility})
+	}
+	if previous == nil || current == nil {
+		if previous != current {
+			change(path, "schema added or removed", Breaking)
+		}
+		return changes
+	}
+
+	previous, wasNullable := unwrapNullableJSONSchema(previous)
+	current, isNullable := unwrapNullableJSONSchema(current)
+	switch {
+	case !wasNullable && isNullable:
+		change(path, "became nullable", BackwardCompatible)
+	case wasNullable && !isNullable:
+		change(path, "became non-nullable", ForwardCompatible)
+	}
+
+	if previous.Type != current.Type || previous.Format != current.Format {
+		description := fmt.Sprintf("type changed from %s to %s", jsonSchemaTypeLabel(previous), jsonSchemaTypeLabel(current))
+		switch {
+		case current.Type == "" || previous.Type == "integer" && current.Type == "number":
+			change(path, description, BackwardCompatible) // the new type accepts the previous values
+		case previous.Type == "" || previous.Type == "number" && current.Type == "integer":
+			change(path, description, ForwardCompatible)
+		default:
+			change(path, description, Breaking)
+		}
+		return changes // the shapes of different types are not comparable
+	}
+
+	changes = append(changes, compareJSONSchemaEnums(path, previous.Enum, current.Enum)...)
+	changes = append(changes, compareJSONSchemaProperties(path, previous, current)...)
+	if previous.Items != nil || current.Items != nil {
+		changes = append(changes, compareJSONSchemas(path+"[]", previous.Items, current.Items)...)
+	}
+	if previous.AdditionalProperties != nil || current.AdditionalProperties != nil {
+		changes = append(changes, compareJSONSchemas(path+"{}", previous.AdditionalProperties, current.AdditionalProperties)...)
+	}
+
+	return changes
+}
+
+func compareJSONSchemaEnums(path string, previous, current []any) []CompatibilityChange {
+	var changes []CompatibilityChange
+	change := func(description string, compatibility Compatibility) {
+		changes = append(changes, CompatibilityChange{Path: path, Description: description, Compatibility: compatibility})
+	}
+
+	switch {
+	case len(previous) == 0 && len(current) == 0:
+	case len(previous) == 0:
+		change("values restricted to an enum", ForwardCompatible)
+	case len(current) == 0:
+		change("enum restriction removed", BackwardCompatible)
+	default:
+		prev, cur := enumLabels(previous), enumLabels(current)
+		if removed := slices.DeleteFunc(slices.Clone(prev), func(v string) bool { return slices.Contains(cur, v) }); len(removed) != 0 {
+			change(fmt.Sprintf("enum values removed: %s", strings.Join(removed, ", ")), ForwardCompatible)
+		}
+		if added := slices.DeleteFunc(slices.Clone(cur), func(v string) bool { return slices.Contains(prev, v) }); len(added) != 0 {
+			change(fmt.Sprintf("enum values added: %s", strings.Join(added, ", ")), BackwardCompatible)
+		}
+	}
+
+	return changes
+}
+
+func compareJSONSchemaProperties(path string, previous, current *JSONSchema) []CompatibilityChange {
+	var changes []CompatibilityChange
+	change := func(path, description string, compatibility Compatibility) {
+		changes = append(changes, CompatibilityChange{Path: path, Description: description, Compatibility: compatibility})
+	}
+	propertyPath := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	var removed, added []string
+	for _, name := range slices.Sorted(maps.Keys(previous.Properties)) {
+		if _, found := current.Properties[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(current.Properties)) {
+		if _, found := previous.Properties[name]; !found {
+			added = append(added, name)
+		}
+	}
+
+	for _, name := range removed {
+		renamed := slices.IndexFunc(added, func(newName string) bool {
+			return equivalentJSONSchemas(previous.Properties[name], current.Properties[newName])
+		})
+		if renamed < 0 {
+			change(propertyPath(name), "field removed", Breaking)
+			continue
+		}
+		change(propertyPath(name), fmt.Sprintf("field renamed to %q", added[renamed]), Breaking)
+		added = slices.Delete(added, renamed, renamed+1)
+	}
+	for _, name := range added {
+		if slices.Contains(current.Required, name) {
+			change(propertyPath(name), "required field added", ForwardCompatible)
+		} else {
+			change(propertyPath(name), "optional field added", FullyCompatible)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(previous.Properties)) {
+		cur, found := current.Properties[name]
+		if !found {
+			continue
+		}
+
+		wasRequired, isRequired := slices.Contains(previous.Required, name), slices.Contains(current.Required, name)
+		switch {
+		case wasRequired && !isRequired:
+			change(propertyPath(name), "field became optional", BackwardCompatible)
+		case !wasRequired && isRequired:
+			change(propertyPath(name), "field became required", ForwardCompatible)
+		}
+		changes = append(changes, compareJSONSchemas(propertyPath(name), previous.Properties[name], cur)...)
+	}
+
+	return changes
+}
+
+// unwrapNullableJSONSchema returns the schema of the value of a nullable schema.
+func unwrapNullableJSONSchema(s *JSONSchema) (*JSONSchema, bool) {
+	if len(s.AnyOf) == 2 && s.AnyOf[1] != nil && s.AnyOf[1].Type == "null" && s.AnyOf[0] != nil {
+		return s.AnyOf[0], true
+	}
+
+	return s, false
+}
+
+func jsonSchemaTypeLabel(s *JSONSchema) string {
+	switch {
+	case s.Type == "":
+		return "any"
+	case s.Format != "":
+		return s.Type + " (" + s.Format + ")"
+	default:
+		return s.Type
+	}
+}
+
+func enumLabels(values []any) []string {
+	labels := make([]string, len(values))
+	for i, v := range values {
+		labels[i] = fmt.Sprint(v)
+	}
+
+	return labels
+}
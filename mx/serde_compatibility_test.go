@@ -0,0 +1,155 @@
+package mx_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlacedEventV1 struct {
+	OrderID  string   `json:"orderId"`
+	Quantity int      `json:"quantity"`
+	Status   string   `json:"status" enum:"pending,paid"`
+	Tags     []string `json:"tags,omitempty"`
+	Note     string   `json:"note"`
+}
+
+func (orderPlacedEventV1) TypeName() misas.EventTypeName { return "sales.order_placed" }
+
+type orderPlacedEventV2 struct {
+	OrderID  string   `json:"orderId"`
+	Quantity float64  `json:"quantity"`
+	Status   string   `json:"status" enum:"pending,paid,refunded"`
+	Tags     []string `json:"tags,omitempty"`
+	Comment  string   `json:"comment"`
+	Channel  *string  `json:"channel,omitempty"`
+}
+
+func (orderPlacedEventV2) TypeName() misas.EventTypeName { return "sales.order_placed" }
+
+type stockCountedEventV1 struct {
+	ProductID string `json:"productId"`
+}
+
+func (stockCountedEventV1) TypeName() misas.EventTypeName { return "inventory.stock_counted" }
+
+func snapshotOf(t *testing.T, events map[misas.EventTypeName]misas.Event) mx.RegistrySnapshot {
+	t.Helper()
+	registries := mx.NewMessageRegistries()
+	for tn, e := range events {
+		registries.Events.Register(tn, e)
+	}
+
+	snapshot, err := registries.Snapshot()
+	require.NoError(t, err)
+
+	return snapshot
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	t.Run("GIVEN identical snapshots WHEN checking THEN should report no change", func(t *testing.T) {
+		snapshot := snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV1{}})
+
+		report := mx.CheckCompatibility(snapshot, snapshot)
+		require.Empty(t, report.Changes)
+		require.Equal(t, mx.FullyCompatible, report.Compatibility())
+		require.NoError(t, report.Check(mx.FullyCompatible))
+	})
+
+	t.Run("GIVEN changed fields WHEN checking THEN should classify every change", func(t *testing.T) {
+		report := mx.CheckCompatibility(
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV1{}}),
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV2{}}),
+		)
+
+		var changes []string
+		for _, c := range report.Changes {
+			changes = append(changes, c.String())
+		}
+		require.Equal(t, []string{
+			`event sales.order_placed.note: field renamed to "comment" (breaking)`,
+			`event sales.order_placed.channel: optional field added (fully compatible)`,
+			`event sales.order_placed.quantity: type changed from integer to number (backward compatible)`,
+			`event sales.order_placed.status: enum values added: refunded (backward compatible)`,
+		}, changes)
+		require.Equal(t, mx.Breaking, report.Compatibility())
+	})
+
+	t.Run("GIVEN breaking changes WHEN requiring backward compatibility THEN should list them", func(t *testing.T) {
+		report := mx.CheckCompatibility(
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV1{}}),
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV2{}}),
+		)
+
+		err := report.Check(mx.BackwardCompatible)
+		require.ErrorIs(t, err, misas.ErrConflict)
+		require.ErrorContains(t, err, `note: field renamed to "comment"`)
+		require.NotContains(t, err.Error(), "quantity")
+		require.NoError(t, report.Check(mx.Breaking))
+	})
+
+	t.Run("GIVEN renamed type name WHEN checking THEN should be breaking", func(t *testing.T) {
+		report := mx.CheckCompatibility(
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV1{}}),
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_created": orderPlacedEventV1{}}),
+		)
+
+		require.Equal(t, []mx.CompatibilityChange{{
+			MessageKind:   "event",
+			TypeName:      "sales.order_placed",
+			Description:   `type name renamed to "sales.order_created"`,
+			Compatibility: mx.Breaking,
+		}}, report.Changes)
+	})
+
+	t.Run("GIVEN added and removed messages WHEN checking THEN should classify them", func(t *testing.T) {
+		report := mx.CheckCompatibility(
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"inventory.stock_counted": stockCountedEvent{}}),
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"sales.order_placed": orderPlacedEventV1{}}),
+		)
+
+		require.Equal(t, []mx.CompatibilityChange{
+			{MessageKind: "event", TypeName: "inventory.stock_counted", Description: "message removed", Compatibility: mx.Breaking},
+			{MessageKind: "event", TypeName: "sales.order_placed", Description: "message added", Compatibility: mx.FullyCompatible},
+		}, report.Changes)
+	})
+
+	t.Run("GIVEN required field added WHEN checking THEN should be forward compatible", func(t *testing.T) {
+		report := mx.CheckCompatibility(
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"inventory.stock_counted": stockCountedEventV1{}}),
+			snapshotOf(t, map[misas.EventTypeName]misas.Event{"inventory.stock_counted": stockCountedEvent{}}),
+		)
+
+		require.Equal(t, mx.ForwardCompatible, report.Compatibility())
+		require.NoError(t, report.Check(mx.ForwardCompatible))
+		require.ErrorIs(t, report.Check(mx.BackwardCompatible), misas.ErrConflict)
+	})
+}
+
+func TestMessageRegistries_CheckSnapshotCompatibility(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.snapshot.json")
+
+	t.Run("GIVEN snapshot file WHEN checking the same registries THEN should be fully compatible", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		registries.Events.Register("sales.order_placed", orderPlacedEventV1{})
+		registries.Commands.Register(adjustStockCommand{}.TypeName(), adjustStockCommand{})
+		require.NoError(t, registries.WriteSnapshot(path))
+
+		snapshot, err := mx.ReadRegistrySnapshot(path)
+		require.NoError(t, err)
+		require.Contains(t, snapshot.Commands, "inventory.adjust_stock")
+		require.Contains(t, snapshot.Events, "sales.order_placed")
+
+		report, err := registries.CheckSnapshotCompatibility(path)
+		require.NoError(t, err)
+		require.Empty(t, report.Changes)
+	})
+
+	t.Run("GIVEN missing snapshot file WHEN checking THEN should return an error", func(t *testing.T) {
+		_, err := mx.NewMessageRegistries().CheckSnapshotCompatibility(filepath.Join(t.TempDir(), "missing.json"))
+		require.Error(t, err)
+	})
+}
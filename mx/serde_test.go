@@ -1,11 +1,16 @@
 package mx_test
 
 import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/morebec/misas/misas"
 	"github.com/morebec/misas/mx"
 	"github.com/morebec/misas/mxtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"testing"
 )
 
 func TestMessageRegistry_JSONUnmarshal(t *testing.T) {
@@ -21,3 +26,176 @@ func TestMessageRegistry_JSONUnmarshal(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestMessageRegistry_Register(t *testing.T) {
+	t.Run("GIVEN type name registered WHEN registering it again for the same type THEN should be ignored", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.CommandTypeName, misas.Command]()
+		registry.Register("registered", mxtest.MockCommand{})
+
+		require.NoError(t, registry.TryRegister("registered", &mxtest.MockCommand{}))
+		require.Equal(t, []misas.CommandTypeName{"registered"}, registry.TypeNames())
+	})
+
+	t.Run("GIVEN type name registered WHEN trying to register it for another type THEN should fail with a conflict", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.CommandTypeName, misas.Command]()
+		registry.Register("registered", mxtest.MockCommand{})
+
+		err := registry.TryRegister("registered", adjustStockCommand{})
+		require.ErrorIs(t, err, misas.ErrConflict)
+		require.Panics(t, func() { registry.MustRegister("registered", adjustStockCommand{}) })
+
+		typ, _ := registry.Lookup("registered")
+		require.Equal(t, reflect.TypeFor[mxtest.MockCommand](), typ)
+	})
+
+	t.Run("GIVEN type name registered WHEN registering it for another type THEN should replace it", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.CommandTypeName, misas.Command]()
+		registry.Register("registered", mxtest.MockCommand{})
+
+		registry.Register("registered", adjustStockCommand{})
+
+		typ, _ := registry.Lookup("registered")
+		require.Equal(t, reflect.TypeFor[adjustStockCommand](), typ)
+		tn, err := registry.TypeNameOf(adjustStockCommand{})
+		require.NoError(t, err)
+		require.Equal(t, misas.CommandTypeName("registered"), tn)
+		_, err = registry.TypeNameOf(mxtest.MockCommand{})
+		require.ErrorIs(t, err, misas.ErrNotFound)
+	})
+
+	t.Run("GIVEN concurrent registrations WHEN listing THEN should list every message", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.CommandTypeName, misas.Command]()
+		var wg sync.WaitGroup
+		for _, tn := range []misas.CommandTypeName{"a", "b", "c", "d"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				registry.Register(tn, mxtest.MockCommand{})
+				_ = registry.TypeNames()
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, []misas.CommandTypeName{"a", "b", "c", "d"}, registry.TypeNames())
+	})
+}
+
+func TestMessageRegistry_Clear(t *testing.T) {
+	t.Run("GIVEN configured registry WHEN clearing it THEN should reset its messages, codecs and versioning", func(t *testing.T) {
+		registry := stockMovedEventRegistry().
+			WithCodec(mx.CBORCodec{}).
+			WithTypeCodec("inventory.stock_moved", mx.MessagePackCodec{}).
+			WithDeprecatedVersions("inventory.stock_moved", 1)
+
+		registry.Clear()
+
+		require.Empty(t, registry.TypeNames())
+		_, found := registry.SchemaVersion("inventory.stock_moved")
+		require.False(t, found)
+		require.False(t, registry.IsDeprecatedVersion("inventory.stock_moved", 1))
+
+		registry.Register(stockMovedEvent{}.TypeName(), stockMovedEvent{})
+		envelope, err := registry.MarshalEnvelope(stockMovedEvent{ProductID: "p1"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, mx.ContentTypeJSON, envelope.ContentType)
+
+		_, _, err = registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 1, "payload": {"product": "p1"}}`))
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "no upcaster")
+	})
+}
+
+func TestMessageRegistry_Lookup(t *testing.T) {
+	registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+	registry.Register(stockReservedEvent{}.TypeName(), stockReservedEvent{})
+	registry.Register(mx.SystemEventTypeName(mx.SystemInitializationStartedPluginHookName), mx.SystemEvent{})
+	registry.Register(mx.SystemEventTypeName(mx.SystemTeardownStartedPluginHookName), mx.SystemEvent{})
+
+	t.Run("GIVEN registered type name WHEN looking it up THEN should return its Go type", func(t *testing.T) {
+		typ, found := registry.Lookup("inventory.stock_reserved")
+		require.True(t, found)
+		require.Equal(t, reflect.TypeFor[stockReservedEvent](), typ)
+
+		_, found = registry.Lookup("not_registered")
+		require.False(t, found)
+	})
+
+	t.Run("GIVEN Go value WHEN looking up its type name THEN should return the registered name", func(t *testing.T) {
+		tn, err := registry.TypeNameOf(&stockReservedEvent{})
+		require.NoError(t, err)
+		require.Equal(t, misas.EventTypeName("inventory.stock_reserved"), tn)
+
+		tn, err = registry.TypeNameOf(mx.SystemEvent{HookName: mx.SystemTeardownStartedPluginHookName})
+		require.NoError(t, err)
+		require.Equal(t, misas.EventTypeName("mx.system.teardown.started"), tn)
+	})
+
+	t.Run("GIVEN Go value WHEN its type name cannot be determined THEN should return an error", func(t *testing.T) {
+		_, err := registry.TypeNameOf(stockAdjustedEvent{})
+		require.ErrorIs(t, err, misas.ErrNotFound)
+
+		_, err = registry.TypeNameOf(mx.SystemEvent{HookName: mx.SystemExecutionStartedPluginHookName})
+		require.ErrorIs(t, err, misas.ErrConflict)
+	})
+}
+
+type isolatedCommand struct{}
+
+func (isolatedCommand) TypeName() misas.CommandTypeName { return "isolated.adjust_stock" }
+
+// conflictingAdjustStockCommand shares the type name of adjustStockCommand.
+type conflictingAdjustStockCommand struct{}
+
+func (conflictingAdjustStockCommand) TypeName() misas.CommandTypeName {
+	return "inventory.adjust_stock"
+}
+
+func TestSystemConf_WithMessageRegistries(t *testing.T) {
+	handler := misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult { return misas.CommandResult{} })
+
+	t.Run("GIVEN isolated registries WHEN adding subsystems THEN should register messages with them only", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		system := mx.NewSystem("test").WithMessageRegistries(registries)
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+			WithCommandHandler(isolatedCommand{}, handler).
+			ProducesEvents(stockReservedEvent{}),
+		)
+
+		require.Equal(t, registries, system.MessageRegistries())
+		require.Equal(t, []misas.CommandTypeName{"isolated.adjust_stock"}, registries.Commands.TypeNames())
+		require.Equal(t, []misas.EventTypeName{"inventory.stock_reserved"}, registries.Events.TypeNames())
+		_, found := mx.CommandRegistry.Lookup("isolated.adjust_stock")
+		require.False(t, found)
+	})
+
+	t.Run("GIVEN subsystems added WHEN setting registries THEN should register their messages with them only", func(t *testing.T) {
+		previous := mx.NewMessageRegistries()
+		registries := mx.NewMessageRegistries()
+		system := mx.NewSystem("test").
+			WithMessageRegistries(previous).
+			WithSystemEvents().
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+				WithQueryHandler(stockLevelQuery{}, misas.QueryHandlerFunc(func(context.Context, misas.Query) misas.QueryResult {
+					return misas.QueryResult{}
+				})),
+			)
+
+		system.WithMessageRegistries(registries)
+
+		require.Equal(t, registries, system.MessageRegistries())
+		require.Equal(t, []misas.QueryTypeName{"inventory.stock_level"}, registries.Queries.TypeNames())
+		require.Contains(t, registries.Events.TypeNames(), mx.SystemEventTypeName(mx.SystemInitializationStartedPluginHookName))
+		require.Empty(t, previous.Queries.TypeNames())
+		require.Empty(t, previous.Events.TypeNames())
+	})
+
+	t.Run("GIVEN subsystems registering a type name for different Go types WHEN running THEN should fail with a conflict", func(t *testing.T) {
+		system := mx.NewSystem("test").WithMessageRegistries(mx.NewMessageRegistries())
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").WithCommandHandler(adjustStockCommand{}, handler))
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("shipping").WithCommandHandler(conflictingAdjustStockCommand{}, handler))
+
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.ErrorIs(t, err, misas.ErrConflict)
+		require.Panics(t, func() { system.MessageRegistries() })
+	})
+}
@@ -0,0 +1,240 @@
+package mx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"github.com/morebec/misas/misas"
+)
+
+// A registered message type is at the schema version reported by its prototype, see VersionedMessage. Envelopes
+// written at older versions are migrated to the current version while decoding, by chaining the upcasters registered
+// from each of these versions to the next one. Envelopes written at newer versions are rejected, the registry not
+// knowing how to read them.
+
+// upcaster migrates the payload of a message from a schema version to the next one.
+type upcaster struct {
+	// upcastJSON migrates a JSON payload, when registered with WithJSONUpcaster.
+	upcastJSON func(json.RawMessage) (json.RawMessage, error)
+
+	// upcastValue migrates a value of the Go type of the version, when registered with WithStructUpcaster.
+	prototype   reflect.Type
+	upcastValue func(any) (any, error)
+}
+
+// WithJSONUpcaster registers a function migrating the JSON payload of the messages of the given type from the given
+// schema version to the next one. Payloads encoded by other codecs are converted to JSON before being upcast.
+func (m *MessageRegistry[TN, T]) WithJSONUpcaster(tn TN, fromVersion int, fn func(json.RawMessage) (json.RawMessage, error)) *MessageRegistry[TN, T] {
+	if fn == nil {
+		panic(fmt.Sprintf("message registry: upcaster of message %q cannot be nil", tn))
+	}
+
+	m.addUpcaster(tn, fromVersion, upcaster{upcastJSON: fn})
+
+	return m
+}
+
+// WithStructUpcaster registers a function migrating the messages of the given type from the given schema version to the
+// next one. The payload at the given version is decoded as the Go type of the prototype, and the function returns the
+// message at the next version: either a value of the Go type of the next version, when also upcast by a struct
+// upcaster, or of any type encodable to its JSON payload.
+func (m *MessageRegistry[TN, T]) WithStructUpcaster(tn TN, fromVersion int, prototype any, fn func(any) (any, error)) *MessageRegistry[TN, T] {
+	if prototype == nil {
+		panic(fmt.Sprintf("message registry: prototype of message %q at version %d cannot be nil", tn, fromVersion))
+	}
+	if fn == nil {
+		panic(fmt.Sprintf("message registry: upcaster of message %q cannot be nil", tn))
+	}
+
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	m.addUpcaster(tn, fromVersion, upcaster{prototype: t, upcastValue: fn})
+
+	return m
+}
+
+func (m *MessageRegistry[TN, T]) addUpcaster(tn TN, fromVersion int, u upcaster) {
+	if fromVersion < defaultSchemaVersion {
+		panic(fmt.Sprintf("message registry: cannot upcast message %q from version %d, versions start at %d", tn, fromVersion, defaultSchemaVersion))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.upcasters[tn][fromVersion]; exists {
+		panic(fmt.Sprintf("message registry: message %q already has an upcaster from version %d", tn, fromVersion))
+	}
+	if m.upcasters[tn] == nil {
+		m.upcasters[tn] = map[int]upcaster{}
+	}
+	m.upcasters[tn][fromVersion] = u
+}
+
+// WithDeprecatedVersions declares schema versions of the messages of the given type as deprecated. Envelopes at these
+// versions are still decoded, and reported to the deprecation handler.
+func (m *MessageRegistry[TN, T]) WithDeprecatedVersions(tn TN, versions ...int) *MessageRegistry[TN, T] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deprecatedVersions[tn] == nil {
+		m.deprecatedVersions[tn] = map[int]bool{}
+	}
+	for _, v := range versions {
+		m.deprecatedVersions[tn][v] = true
+	}
+
+	return m
+}
+
+// WithDeprecationHandler sets the function called when decoding an envelope at a deprecated schema version.
+// By default, a warning is logged with the default logger.
+func (m *MessageRegistry[TN, T]) WithDeprecationHandler(fn func(tn TN, version int)) *MessageRegistry[TN, T] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onDeprecatedVersion = fn
+
+	return m
+}
+
+// SchemaVersion returns the current schema version of a registered message type.
+func (m *MessageRegistry[TN, T]) SchemaVersion(tn TN) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, found := m.versions[tn]
+	return v, found
+}
+
+// IsDeprecatedVersion reports whether a schema version of a message type was declared deprecated.
+func (m *MessageRegistry[TN, T]) IsDeprecatedVersion(tn TN, version int) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.deprecatedVersions[tn][version]
+}
+
+// upcastEnvelope decodes the payload of an envelope written at an older schema version into ptr, a pointer to the
+// registered Go type, running the upcasters from its version to the current one.
+func (m *MessageRegistry[TN, T]) upcastEnvelope(envelope MessageEnvelope, codec Codec, ptr reflect.Value) error {
+	tn := TN(envelope.TypeName)
+	current, _ := m.SchemaVersion(tn)
+	version := max(envelope.SchemaVersion, defaultSchemaVersion) // envelopes without version predate versioning
+
+	if version > current {
+		return misas.ErrInvalid.WithMessage(fmt.Sprintf("message %q has schema version %d, newer than the supported version %d", tn, version, current))
+	}
+	if m.IsDeprecatedVersion(tn, version) {
+		m.reportDeprecatedVersion(tn, version)
+	}
+
+	// the message being upcast is either an encoded payload, or a value once upcast by a struct upcaster
+	payload, value := envelope.Payload, any(nil)
+	for ; version < current; version++ {
+		m.mu.RLock()
+		u, ok := m.upcasters[tn][version]
+		m.mu.RUnlock()
+		if !ok {
+			return misas.ErrInvalid.WithMessage(fmt.Sprintf("message %q has schema version %d, but no upcaster migrates it to version %d", tn, version, version+1))
+		}
+
+		var err error
+		if u.upcastJSON != nil {
+			payload, err = upcastJSONPayload(u, payload, value, codec)
+			value, codec = nil, JSONCodec{}
+		} else {
+			value, err = upcastValue(u, payload, value, codec)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upcast message %q from version %d: %w", tn, version, err)
+		}
+	}
+
+	if value == nil {
+		return codec.Unmarshal(payload, ptr.Interface())
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer && rv.Type().Elem() == ptr.Type().Elem() {
+		rv = rv.Elem()
+	}
+	if rv.Type() == ptr.Type().Elem() {
+		ptr.Elem().Set(rv)
+		return nil
+	}
+
+	return convertThroughJSON(value, ptr.Interface())
+}
+
+// convertThroughJSON converts a value returned by an upcaster to the Go type of the next version through its
+// JSON encoding.
+func convertThroughJSON(value any, target any) error {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(js, target)
+}
+
+func upcastJSONPayload(u upcaster, payload []byte, value any, codec Codec) (json.RawMessage, error) {
+	var js json.RawMessage
+	var err error
+	switch {
+	case value != nil:
+		js, err = json.Marshal(value)
+	case codec.ContentType() == ContentTypeJSON:
+		js = payload
+	default:
+		var decoded any
+		if err = codec.Unmarshal(payload, &decoded); err == nil {
+			js, err = json.Marshal(decoded)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert payload to JSON: %w", err)
+	}
+
+	return u.upcastJSON(js)
+}
+
+func upcastValue(u upcaster, payload []byte, value any, codec Codec) (any, error) {
+	ptr := reflect.New(u.prototype)
+	if value != nil {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		if rv.Type() == u.prototype {
+			ptr.Elem().Set(rv)
+		} else if err := convertThroughJSON(value, ptr.Interface()); err != nil {
+			return nil, err
+		}
+	} else if err := codec.Unmarshal(payload, ptr.Interface()); err != nil {
+		return nil, err
+	}
+
+	upcast, err := u.upcastValue(ptr.Elem().Interface())
+	if err == nil && upcast == nil {
+		err = fmt.Errorf("upcaster returned no message")
+	}
+
+	return upcast, err
+}
+
+func (m *MessageRegistry[TN, T]) reportDeprecatedVersion(tn TN, version int) {
+	m.mu.RLock()
+	handler := m.onDeprecatedVersion
+	m.mu.RUnlock()
+
+	if handler != nil {
+		handler(tn, version)
+		return
+	}
+
+	slog.Default().Warn(fmt.Sprintf("decoding message %q at deprecated schema version %d", tn, version))
+}
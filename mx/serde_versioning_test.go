@@ -0,0 +1,156 @@
+package mx_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+// stockMovedEventV1 and stockMovedEventV2 are the previous schemas of stockMovedEvent.
+type stockMovedEventV1 struct {
+	Product string `json:"product"`
+	Qty     int    `json:"qty"`
+}
+
+type stockMovedEventV2 struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type stockMovedEvent struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Unit      string `json:"unit"`
+}
+
+func (stockMovedEvent) TypeName() misas.EventTypeName { return "inventory.stock_moved" }
+
+func (stockMovedEvent) SchemaVersion() int { return 3 }
+
+func stockMovedEventRegistry() *mx.MessageRegistry[misas.EventTypeName, misas.Event] {
+	registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+	registry.Register(stockMovedEvent{}.TypeName(), stockMovedEvent{})
+	registry.WithStructUpcaster("inventory.stock_moved", 1, stockMovedEventV1{}, func(v any) (any, error) {
+		e := v.(stockMovedEventV1)
+		return stockMovedEventV2{ProductID: e.Product, Quantity: e.Qty}, nil
+	})
+	registry.WithJSONUpcaster("inventory.stock_moved", 2, func(js json.RawMessage) (json.RawMessage, error) {
+		var payload map[string]any
+		if err := json.Unmarshal(js, &payload); err != nil {
+			return nil, err
+		}
+		payload["unit"] = "piece"
+		return json.Marshal(payload)
+	})
+
+	return registry
+}
+
+func TestMessageRegistry_Upcasting(t *testing.T) {
+	t.Run("GIVEN versioned message WHEN marshalling THEN envelope should hold its current version", func(t *testing.T) {
+		registry := stockMovedEventRegistry()
+
+		envelope, err := registry.MarshalEnvelope(stockMovedEvent{ProductID: "p1"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, envelope.SchemaVersion)
+
+		version, found := registry.SchemaVersion("inventory.stock_moved")
+		require.True(t, found)
+		require.Equal(t, 3, version)
+	})
+
+	t.Run("GIVEN envelope at an old version WHEN unmarshalling THEN should chain upcasters", func(t *testing.T) {
+		registry := stockMovedEventRegistry()
+
+		event, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 1, "payload": {"product": "p1", "qty": 4}}`))
+		require.NoError(t, err)
+		require.Equal(t, stockMovedEvent{ProductID: "p1", Quantity: 4, Unit: "piece"}, event)
+
+		event, _, err = registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 2, "payload": {"productId": "p2", "quantity": 5}}`))
+		require.NoError(t, err)
+		require.Equal(t, stockMovedEvent{ProductID: "p2", Quantity: 5, Unit: "piece"}, event)
+	})
+
+	t.Run("GIVEN binary envelope at an old version WHEN unmarshalling THEN should upcast it", func(t *testing.T) {
+		registry := stockMovedEventRegistry()
+		payload, err := mx.CBORCodec{}.Marshal(stockMovedEventV2{ProductID: "p1", Quantity: 2})
+		require.NoError(t, err)
+
+		event, err := registry.UnmarshalEnvelope(mx.MessageEnvelope{
+			TypeName:      "inventory.stock_moved",
+			SchemaVersion: 2,
+			ContentType:   mx.ContentTypeCBOR,
+			Payload:       payload,
+		})
+		require.NoError(t, err)
+		require.Equal(t, stockMovedEvent{ProductID: "p1", Quantity: 2, Unit: "piece"}, event)
+	})
+
+	t.Run("GIVEN struct upcaster to the current version WHEN unmarshalling THEN should return its value", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+		registry.WithStructUpcaster("inventory.stock_counted", 1, stockMovedEventV1{}, func(v any) (any, error) {
+			e := v.(stockMovedEventV1)
+			return &stockCountedEvent{ProductID: e.Product, Quantity: e.Qty}, nil
+		})
+
+		event, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_counted", "schemaVersion": 1, "payload": {"product": "p1", "qty": 4}}`))
+		require.NoError(t, err)
+		require.Equal(t, stockCountedEvent{ProductID: "p1", Quantity: 4}, event)
+	})
+
+	t.Run("GIVEN envelope at a future version WHEN unmarshalling THEN should fail", func(t *testing.T) {
+		_, _, err := stockMovedEventRegistry().UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 4, "payload": {}}`))
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "newer than the supported version 3")
+	})
+
+	t.Run("GIVEN missing upcaster WHEN unmarshalling THEN should fail", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		registry.Register(stockMovedEvent{}.TypeName(), stockMovedEvent{})
+
+		_, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 2, "payload": {}}`))
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+
+	t.Run("GIVEN failing upcaster WHEN unmarshalling THEN should return its error", func(t *testing.T) {
+		registry := mx.NewMessageRegistry[misas.EventTypeName, misas.Event]()
+		registry.Register(stockCountedEvent{}.TypeName(), stockCountedEvent{})
+		upcastErr := errors.New("cannot upcast")
+		registry.WithJSONUpcaster("inventory.stock_counted", 1, func(json.RawMessage) (json.RawMessage, error) { return nil, upcastErr })
+
+		_, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_counted", "schemaVersion": 1, "payload": {}}`))
+		require.ErrorIs(t, err, upcastErr)
+	})
+
+	t.Run("GIVEN upcaster already registered for a version WHEN registering another THEN should panic", func(t *testing.T) {
+		registry := stockMovedEventRegistry()
+
+		require.Panics(t, func() {
+			registry.WithJSONUpcaster("inventory.stock_moved", 2, func(js json.RawMessage) (json.RawMessage, error) { return js, nil })
+		})
+		require.Panics(t, func() {
+			registry.WithJSONUpcaster("inventory.stock_moved", 0, func(js json.RawMessage) (json.RawMessage, error) { return js, nil })
+		})
+	})
+
+	t.Run("GIVEN deprecated version WHEN unmarshalling THEN should report it", func(t *testing.T) {
+		var reported []int
+		registry := stockMovedEventRegistry().
+			WithDeprecatedVersions("inventory.stock_moved", 1).
+			WithDeprecationHandler(func(tn misas.EventTypeName, version int) { reported = append(reported, version) })
+
+		_, _, err := registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 1, "payload": {"product": "p1"}}`))
+		require.NoError(t, err)
+		_, _, err = registry.UnmarshalEnvelopeFromJSON([]byte(`{"type": "inventory.stock_moved", "schemaVersion": 2, "payload": {"productId": "p1"}}`))
+		require.NoError(t, err)
+
+		require.Equal(t, []int{1}, reported)
+		require.True(t, registry.IsDeprecatedVersion("inventory.stock_moved", 1))
+		require.False(t, registry.IsDeprecatedVersion("inventory.stock_moved", 2))
+	})
+}
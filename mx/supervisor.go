@@ -2,9 +2,13 @@ package mx
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/morebec/misas/misas"
 	"github.com/samber/lo"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,6 +27,15 @@ type Supervisor struct {
 	supervisedApplications map[string]*supervisedApplicationSubsystem
 	clock                  *DynamicBindingClock
 	pm                     *lateBindingSystemPluginManager
+
+	// guards the fields below as well as the application maps which are accessed by the control API
+	mu          sync.RWMutex
+	initialized bool
+	// context of the current Run, used to launch applications added at runtime or no longer supervised
+	runCtx context.Context
+
+	// serializes the initialization of the supervisor with additions and removals of applications
+	changeMu sync.Mutex
 }
 
 func NewSupervisor() *Supervisor {
@@ -57,18 +70,26 @@ func (s *Supervisor) OnHook(ctx context.Context, hook SystemPluginHook) error {
 }
 
 func (s *Supervisor) Initialize(ctx context.Context) error {
+	// applications added concurrently are either registered before and initialized here,
+	// or added once initialized and initialized by AddApplicationSubsystem
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
 	// Wrap raw application subsystems with managed application subsystems now that pm and clock are initialized
+	s.mu.Lock()
 	for name, reg := range s.rawApplications {
-		supervisedApp := &supervisedApplicationSubsystem{
-			ApplicationSubsystem: newManagedApplicationSubsystem(reg.app, s.pm, s.clock),
-			Options:              lo.Ternary(reg.options != nil, reg.options, &SupervisionOptions{}),
-			pm:                   s.pm,
-		}
-		s.supervisedApplications[name] = supervisedApp
+		s.supervisedApplications[name] = s.supervise(reg)
 	}
+	s.initialized = true
+	apps := lo.Values(s.supervisedApplications)
+	s.mu.Unlock()
 
-	Log(ctx).Debug("Initializing supervised applications...", slog.Int("nbApplications", len(s.supervisedApplications)))
-	for _, app := range s.supervisedApplications {
+	if err := validateDependencies(dependencies(apps)); err != nil {
+		return err
+	}
+
+	Log(ctx).Debug("Initializing supervised applications...", slog.Int("nbApplications", len(apps)))
+	for _, app := range apps {
 		appCtx := newSubsystemContext(ctx, SubsystemInfo{Name: app.Name()})
 		if err := app.Initialize(appCtx); err != nil {
 			return err
@@ -79,11 +100,14 @@ func (s *Supervisor) Initialize(ctx context.Context) error {
 }
 
 func (s *Supervisor) Run(ctx context.Context) error {
-	for _, app := range s.supervisedApplications {
-		go func(a *supervisedApplicationSubsystem) {
-			// we can safely ignore the error here, as it can be captured through the system's event bus.
-			_ = a.Run(ctx)
-		}(app)
+	// applications added concurrently are either part of this snapshot or launched by AddApplicationSubsystem
+	s.mu.Lock()
+	s.runCtx = ctx
+	apps := lo.Values(s.supervisedApplications)
+	s.mu.Unlock()
+
+	for _, app := range apps {
+		s.launch(ctx, app)
 	}
 
 	<-ctx.Done()
@@ -93,7 +117,7 @@ func (s *Supervisor) Run(ctx context.Context) error {
 	}
 
 	wg := sync.WaitGroup{}
-	for _, app := range s.supervisedApplications {
+	for _, app := range s.applications() {
 		wg.Add(1)
 		go func(a *supervisedApplicationSubsystem) {
 			defer wg.Done()
@@ -105,30 +129,357 @@ func (s *Supervisor) Run(ctx context.Context) error {
 	return err
 }
 
+// Teardown tears down every supervised application in parallel, an application being torn down only once the
+// applications depending on it are. Applications that do not complete their teardown within their timeout are
+// reported through an ApplicationSubsystemTeardownTimedOutHook and no longer waited on. All errors are joined.
 func (s *Supervisor) Teardown(ctx context.Context) error {
-	// per-request timeout to avoid hangs
-	ctx, cancel := context.WithTimeout(ctx, defaultTeardownTimeout)
-	defer cancel()
+	apps := s.applications()
+	// when initialization failed on invalid dependencies, they are ignored to avoid waiting on cycles
+	ordered := validateDependencies(dependencies(apps)) == nil
 
-	for _, app := range s.supervisedApplications {
-		appCtx := newSubsystemContext(ctx, SubsystemInfo{Name: app.Name()})
+	tornDown := make(map[string]chan struct{}, len(apps))
+	for _, app := range apps {
+		tornDown[app.Name()] = make(chan struct{})
+	}
 
-		// run teardown in a goroutine and wait for either completion or timeout
-		done := make(chan error, 1)
+	var mu sync.Mutex
+	var errs []error
+	wg := sync.WaitGroup{}
+	for _, app := range apps {
+		wg.Add(1)
 		go func(a *supervisedApplicationSubsystem) {
-			done <- a.Teardown(appCtx)
+			defer wg.Done()
+			defer close(tornDown[a.Name()])
+
+			// wait for dependents to be torn down first
+			for _, dependent := range apps {
+				if ordered && slices.Contains(dependent.Options.DependsOn, a.Name()) {
+					<-tornDown[dependent.Name()]
+				}
+			}
+
+			if err := s.teardownApplication(ctx, a); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
 		}(app)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// teardownApplication tears down an application, giving up on waiting for it once its teardown timeout is exceeded.
+func (s *Supervisor) teardownApplication(ctx context.Context, app *supervisedApplicationSubsystem) error {
+	timeout := lo.Ternary(app.Options.TeardownTimeout > 0, app.Options.TeardownTimeout, defaultTeardownTimeout)
+	appCtx, cancel := context.WithTimeout(newSubsystemContext(ctx, SubsystemInfo{Name: app.Name()}), timeout)
+	defer cancel()
+
+	startedAt := s.clock.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Teardown(appCtx)
+	}()
 
-		select {
-		case err := <-done:
-			if err != nil {
+	select {
+	case err := <-done:
+		return err
+	case <-appCtx.Done():
+		err := misas.ErrTimeout.WithMessage(fmt.Sprintf("teardown of supervised application %q did not complete within %s", app.Name(), timeout))
+		s.pm.DispatchHook(ctx, ApplicationSubsystemTeardownTimedOutHook{
+			ApplicationName: app.Name(),
+			Timeout:         timeout,
+			StartedAt:       startedAt,
+			Error:           err,
+		})
+		return err
+	}
+}
+
+// validateDependencies ensures supervised applications only depend on known applications, without cycles.
+func validateDependencies(options map[string]*SupervisionOptions) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(options))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			return misas.ErrInvalid.WithMessage(fmt.Sprintf(
+				"cyclic dependency between supervised applications: %s", strings.Join(append(path, name), " -> "),
+			))
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		for _, dependency := range options[name].DependsOn {
+			if _, ok := options[dependency]; !ok {
+				return misas.ErrInvalid.WithMessage(fmt.Sprintf(
+					"supervised application %q depends on unknown application %q", name, dependency,
+				))
+			}
+			if err := visit(dependency, append(path, name)); err != nil {
 				return err
 			}
-		case <-appCtx.Done():
-			// If an application blocks teardown and the appCtx times out, panic to avoid infinite loops
-			panic(fmt.Sprintf("teardown timeout for application %q: %v", app.Name(), appCtx.Err()))
 		}
+		marks[name] = visited
+
+		return nil
+	}
+
+	names := lo.Keys(options)
+	slices.Sort(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencies returns the supervision options of the given applications indexed by name.
+func dependencies(apps []*supervisedApplicationSubsystem) map[string]*SupervisionOptions {
+	return lo.SliceToMap(apps, func(app *supervisedApplicationSubsystem) (string, *SupervisionOptions) {
+		return app.Name(), app.Options
+	})
+}
+
+// Status returns a snapshot of the state of every supervised application, sorted by name.
+func (s *Supervisor) Status() []SupervisedApplicationStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]SupervisedApplicationStatus, 0, len(s.supervisedApplications))
+	for _, app := range s.supervisedApplications {
+		statuses = append(statuses, app.Status())
+	}
+	slices.SortFunc(statuses, func(a, b SupervisedApplicationStatus) int { return strings.Compare(a.Name, b.Name) })
+
+	return statuses
+}
+
+// Stop cancels the current run of the named application and prevents it from running until it is started again.
+func (s *Supervisor) Stop(ctx context.Context, name string) error {
+	app, err := s.application(name)
+	if err != nil {
+		return err
 	}
 
+	s.pm.DispatchHook(ctx, ApplicationSubsystemStopRequestedHook{ApplicationName: name, RequestedAt: s.clock.Now()})
+	app.Stop()
+
 	return nil
 }
+
+// Start resumes the named application if it was stopped, or runs it again under supervision
+// if it exited or its restart policy gave up.
+func (s *Supervisor) Start(ctx context.Context, name string) error {
+	app, err := s.application(name)
+	if err != nil {
+		return err
+	}
+
+	s.pm.DispatchHook(ctx, ApplicationSubsystemStartRequestedHook{ApplicationName: name, RequestedAt: s.clock.Now()})
+	if !s.relaunch(app) {
+		app.Start()
+	}
+
+	return nil
+}
+
+// Restart cancels the current run of the named application and runs it again. Manual restarts
+// are not counted as failures by the application's restart policy.
+func (s *Supervisor) Restart(ctx context.Context, name string) error {
+	app, err := s.application(name)
+	if err != nil {
+		return err
+	}
+
+	s.pm.DispatchHook(ctx, ApplicationSubsystemRestartRequestedHook{ApplicationName: name, RequestedAt: s.clock.Now()})
+	if !s.relaunch(app) {
+		app.Restart()
+	}
+
+	return nil
+}
+
+// AddApplicationSubsystem adds an application to the supervisor. Before the supervisor is initialized, this is
+// equivalent to WithApplicationSubsystem. Afterward, the application is initialized and, if the supervisor is running,
+// run under supervision. Adding an application with the name of an already supervised one fails with misas.ErrConflict.
+func (s *Supervisor) AddApplicationSubsystem(ctx context.Context, app ApplicationSubsystem, options *SupervisionOptions) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	name := app.Name()
+	reg := applicationSubsystemRegistration{app: app, options: options}
+
+	s.mu.Lock()
+	_, registered := s.rawApplications[name]
+	_, supervised := s.supervisedApplications[name]
+	if registered || supervised {
+		s.mu.Unlock()
+		return misas.ErrConflict.WithMessage(fmt.Sprintf("supervised application %q already exists", name))
+	}
+	if !s.initialized {
+		s.rawApplications[name] = reg
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	addedAt := s.clock.Now()
+	supervisedApp := s.supervise(reg)
+	deps := dependencies(append(s.applications(), supervisedApp))
+	err := validateDependencies(deps)
+	if err == nil {
+		err = supervisedApp.Initialize(newSubsystemContext(ctx, SubsystemInfo{Name: name}))
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.rawApplications[name] = reg
+		s.supervisedApplications[name] = supervisedApp
+		runCtx := s.runCtx
+		s.mu.Unlock()
+
+		if runCtx != nil && runCtx.Err() == nil {
+			s.launch(runCtx, supervisedApp)
+		}
+	}
+
+	s.pm.DispatchHook(ctx, ApplicationSubsystemAddedHook{
+		ApplicationName: name,
+		StartedAt:       addedAt,
+		EndedAt:         s.clock.Now(),
+		Error:           err,
+	})
+
+	return err
+}
+
+// RemoveApplicationSubsystem terminates the named application, waits for its current run to return and tears it down.
+// If the application does not stop before ctx is done, it is torn down anyway and the context error is returned.
+func (s *Supervisor) RemoveApplicationSubsystem(ctx context.Context, name string) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+
+	s.mu.Lock()
+	app, supervised := s.supervisedApplications[name]
+	_, registered := s.rawApplications[name]
+	if !registered {
+		s.mu.Unlock()
+		return misas.ErrNotFound.WithMessage(fmt.Sprintf("supervised application %q not found", name))
+	}
+	for dependentName, dependent := range s.rawApplications {
+		if dependent.options != nil && slices.Contains(dependent.options.DependsOn, name) {
+			s.mu.Unlock()
+			return misas.ErrConflict.WithMessage(fmt.Sprintf(
+				"supervised application %q cannot be removed, %q depends on it", name, dependentName,
+			))
+		}
+	}
+	delete(s.rawApplications, name)
+	delete(s.supervisedApplications, name)
+	s.mu.Unlock()
+
+	if !supervised {
+		return nil
+	}
+
+	removedAt := s.clock.Now()
+	app.Terminate()
+	err := app.wait(ctx)
+
+	if teardownErr := s.teardownApplication(ctx, app); teardownErr != nil {
+		err = errors.Join(err, teardownErr)
+	}
+
+	s.pm.DispatchHook(ctx, ApplicationSubsystemRemovedHook{
+		ApplicationName: name,
+		StartedAt:       removedAt,
+		EndedAt:         s.clock.Now(),
+		Error:           err,
+	})
+
+	return err
+}
+
+func (s *Supervisor) supervise(reg applicationSubsystemRegistration) *supervisedApplicationSubsystem {
+	return &supervisedApplicationSubsystem{
+		ApplicationSubsystem: newManagedApplicationSubsystem(reg.app, s.pm, s.clock),
+		Options:              lo.Ternary(reg.options != nil, reg.options, &SupervisionOptions{}),
+		pm:                   s.pm,
+		clock:                s.clock,
+	}
+}
+
+// topology describes the supervised applications, including those not initialized yet.
+func (s *Supervisor) topology() []TopologyApplication {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apps := make([]TopologyApplication, 0, len(s.rawApplications))
+	for name, reg := range s.rawApplications {
+		app := TopologyApplication{Name: name}
+		if reg.options != nil {
+			app.DependsOn = slices.Sorted(slices.Values(reg.options.DependsOn))
+		}
+		apps = append(apps, app)
+	}
+	slices.SortFunc(apps, func(a, b TopologyApplication) int { return strings.Compare(a.Name, b.Name) })
+
+	return apps
+}
+
+// applications returns a snapshot of the supervised applications.
+func (s *Supervisor) applications() []*supervisedApplicationSubsystem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return lo.Values(s.supervisedApplications)
+}
+
+func (s *Supervisor) application(name string) (*supervisedApplicationSubsystem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	app, ok := s.supervisedApplications[name]
+	if !ok {
+		return nil, misas.ErrNotFound.WithMessage(fmt.Sprintf("supervised application %q not found", name))
+	}
+
+	return app, nil
+}
+
+// relaunch runs an application that is no longer supervised again, it returns false if the
+// application is still supervised or the supervisor is not running.
+func (s *Supervisor) relaunch(app *supervisedApplicationSubsystem) bool {
+	s.mu.RLock()
+	ctx := s.runCtx
+	s.mu.RUnlock()
+
+	if ctx == nil || ctx.Err() != nil || !app.relaunch() {
+		return false
+	}
+	s.launch(ctx, app)
+
+	return true
+}
+
+// launch runs an application under supervision, unless it was removed from the supervisor.
+func (s *Supervisor) launch(ctx context.Context, app *supervisedApplicationSubsystem) {
+	if !app.beginRun() {
+		return
+	}
+	go func() {
+		defer app.running.Done()
+		// we can safely ignore the error here, as it can be captured through the system's event bus.
+		_ = app.Run(ctx)
+	}()
+}
@@ -3,6 +3,7 @@ package mx
 import (
 	"context"
 	"fmt"
+	"github.com/morebec/misas/mtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,31 +11,86 @@ import (
 
 type SupervisionOptions struct {
 	RestartPolicy *RestartPolicy
+
+	// TeardownTimeout bounds the teardown of the application, defaults to defaultTeardownTimeout when zero.
+	TeardownTimeout time.Duration
+
+	// DependsOn lists the names of the supervised applications this application depends on.
+	// An application is torn down before its dependencies, applications without dependencies between them
+	// are torn down in parallel.
+	DependsOn []string
 }
 
 // SupervisedApp is a control interface for a supervised application subsystem
 type SupervisedApp interface {
 	Stop()
 	Start()
+	Restart()
 	Terminate()
 }
 
+// SupervisedApplicationState represents the lifecycle state of a supervised application subsystem.
+type SupervisedApplicationState string
+
+const (
+	// SupervisedApplicationStatePending indicates the application was initialized but is not running yet.
+	SupervisedApplicationStatePending SupervisedApplicationState = "pending"
+	// SupervisedApplicationStateRunning indicates the application is currently running.
+	SupervisedApplicationStateRunning SupervisedApplicationState = "running"
+	// SupervisedApplicationStateStopped indicates the application was manually stopped and waits to be started.
+	SupervisedApplicationStateStopped SupervisedApplicationState = "stopped"
+	// SupervisedApplicationStateBackingOff indicates the application failed and waits before being restarted.
+	SupervisedApplicationStateBackingOff SupervisedApplicationState = "backing_off"
+	// SupervisedApplicationStateGivenUp indicates the restart policy gave up restarting the application.
+	SupervisedApplicationStateGivenUp SupervisedApplicationState = "given_up"
+	// SupervisedApplicationStateExited indicates the application returned and its restart policy does not restart it.
+	SupervisedApplicationStateExited SupervisedApplicationState = "exited"
+	// SupervisedApplicationStateTerminated indicates the application was terminated by the supervisor.
+	SupervisedApplicationStateTerminated SupervisedApplicationState = "terminated"
+)
+
+// SupervisedApplicationStatus is a snapshot of the state of a supervised application subsystem.
+type SupervisedApplicationStatus struct {
+	Name               string
+	State              SupervisedApplicationState
+	RestartCount       int // Restarts since the application was launched
+	FailureCount       int // Failures in current circuit breaker window
+	CircuitBreakerOpen bool
+	LastError          error
+	StartedAt          time.Time // Start of the current run, zero if not running
+	Uptime             time.Duration
+}
+
 type supervisedApplicationSubsystem struct {
 	ApplicationSubsystem
 	Options *SupervisionOptions
 	pm      SystemPluginManager
+	clock   mtime.Clock
 
 	// lazy init for channels
-	initOnce sync.Once
+	initOnce      sync.Once
+	terminateOnce sync.Once
 
 	// runtime state using atomics
-	isRunning uint32 // 0 == false, 1 == true
-	stopped   uint32 // 1 == manually stopped
+	stopped          uint32 // 1 == manually stopped
+	restartRequested uint32 // 1 == manual restart requested
+
+	// status, guarded by mu
+	mu           sync.Mutex
+	state        SupervisedApplicationState
+	restarts     *restartTracker // restart state of this application, kept apart from the possibly shared RestartPolicy
+	restartCount int
+	lastError    error
+	startedAt    time.Time
+
+	// tracks the goroutine running the application under supervision, no longer launched once retired
+	running sync.WaitGroup
+	retired bool // guarded by mu
 
 	// control channels
-	stopTrigger   chan struct{} // triggers cancellation of the current run (for Stop)
+	stopTrigger   chan struct{} // triggers cancellation of the current run (for Stop and Restart)
 	resumeChan    chan struct{} // used to resume after Stop
-	terminateChan chan struct{} // terminates the supervised application subsystem
+	terminateChan chan struct{} // closed to terminate the supervised application subsystem
 }
 
 func (s *supervisedApplicationSubsystem) Run(ctx context.Context) error {
@@ -44,49 +100,79 @@ func (s *supervisedApplicationSubsystem) Run(ctx context.Context) error {
 	if s.Options.RestartPolicy == nil {
 		s.Options.RestartPolicy = DefaultRestartPolicy
 	}
+	tracker := newRestartTracker(s.Options.RestartPolicy)
+	policy := tracker.policy
+
+	s.mu.Lock()
+	s.restarts = tracker
+	s.restartCount = 0
+	s.mu.Unlock()
 
 	for {
 		if atomic.LoadUint32(&s.stopped) == 1 {
+			s.setState(SupervisedApplicationStateStopped)
 			select {
 			case <-s.resumeChan:
 				atomic.StoreUint32(&s.stopped, 0)
 			case <-s.terminateChan:
 				Log(ctx).Info(fmt.Sprintf("terminating supervised application subsystem %q", s.Name()))
+				s.setState(SupervisedApplicationStateTerminated)
 				return nil
 			case <-ctx.Done():
+				s.setState(SupervisedApplicationStateTerminated)
 				return ctx.Err()
 			}
 		}
 
 		select {
 		case <-ctx.Done():
+			s.setState(SupervisedApplicationStateTerminated)
 			return ctx.Err()
 		case <-s.terminateChan:
 			Log(ctx).Info(fmt.Sprintf("terminating supervised application subsystem %q", s.Name()))
+			s.setState(SupervisedApplicationStateTerminated)
 			return nil
 		default:
 			now := time.Now()
 			err := s.runOnce(appCtx)
 
-			if err == nil {
-				s.Options.RestartPolicy.resetState()
+			// shutdowns, manual stops and restarts are not failures of the application
+			if ctx.Err() != nil || s.isTerminating() || atomic.LoadUint32(&s.stopped) == 1 {
+				continue
+			}
+			if atomic.CompareAndSwapUint32(&s.restartRequested, 1, 0) {
 				continue
 			}
 
-			policy := s.Options.RestartPolicy
+			if err != nil {
+				s.mu.Lock()
+				s.lastError = err
+				s.mu.Unlock()
+			}
+
+			// under the on-failure policy, an application returning nil has completed and exits
 			if !policy.shouldRestart(err) {
+				s.setState(SupervisedApplicationStateExited)
 				return err
 			}
 
-			policy.recordFailure(err, now)
+			if err == nil {
+				tracker.resetState()
+				continue
+			}
+
+			tracker.recordFailure(err, now)
 
-			if !policy.canRestart(now) {
+			if !tracker.canRestart(now) {
 				reason := "max retries exceeded"
-				if policy.isCircuitOpen() {
+				if tracker.isCircuitOpen() {
 					reason = "circuit breaker open (too many failures)"
+				} else if tracker.isRetryDurationExceeded(now) {
+					reason = "max retry duration exceeded"
 				}
 
-				state := policy.getState()
+				s.setState(SupervisedApplicationStateGivenUp)
+				state := tracker.getState()
 				s.pm.DispatchHook(ctx, ApplicationSubsystemMaxRestartReachedHook{
 					ApplicationName:         s.Name(),
 					RestartCount:            state.AttemptCount,
@@ -102,10 +188,11 @@ func (s *supervisedApplicationSubsystem) Run(ctx context.Context) error {
 				return err
 			}
 
-			delay := policy.nextRetryDelay()
-			policy.recordAttempt()
-			state := policy.getState()
+			delay := tracker.nextRetryDelay()
+			tracker.recordAttempt()
+			state := tracker.getState()
 
+			s.setState(SupervisedApplicationStateBackingOff)
 			s.pm.DispatchHook(ctx, ApplicationSubsystemWillRestartHook{
 				ApplicationName:         s.Name(),
 				RestartCount:            state.AttemptCount,
@@ -120,12 +207,22 @@ func (s *supervisedApplicationSubsystem) Run(ctx context.Context) error {
 
 			select {
 			case <-time.After(delay):
+			case <-s.stopTrigger:
+				// stopped or restarted manually while backing off
+				atomic.StoreUint32(&s.restartRequested, 0)
+				continue
 			case <-s.terminateChan:
+				s.setState(SupervisedApplicationStateTerminated)
 				return nil
 			case <-ctx.Done():
+				s.setState(SupervisedApplicationStateTerminated)
 				return ctx.Err()
 			}
 
+			s.mu.Lock()
+			s.restartCount++
+			s.mu.Unlock()
+
 			s.pm.DispatchHook(ctx, ApplicationSubsystemRestartedHook{
 				ApplicationName: s.Name(),
 				RestartCount:    state.AttemptCount,
@@ -138,7 +235,7 @@ func (s *supervisedApplicationSubsystem) Run(ctx context.Context) error {
 	}
 }
 
-func (s *supervisedApplicationSubsystem) runOnce(ctx context.Context) error {
+func (s *supervisedApplicationSubsystem) runOnce(ctx context.Context) (err error) {
 	// create a child cancellable context so we can cancel the running app on Stop/Terminate
 	ctxRun, cancel := context.WithCancel(ctx)
 
@@ -157,16 +254,33 @@ func (s *supervisedApplicationSubsystem) runOnce(ctx context.Context) error {
 		}
 	}()
 
-	atomic.StoreUint32(&s.isRunning, 1)
-	defer atomic.StoreUint32(&s.isRunning, 0)
+	s.mu.Lock()
+	s.state = SupervisedApplicationStateRunning
+	s.startedAt = s.clock.Now()
+	s.mu.Unlock()
 
-	err := s.ApplicationSubsystem.Run(ctxRun)
+	defer func() {
+		s.mu.Lock()
+		s.startedAt = time.Time{}
+		s.mu.Unlock()
+	}()
+
+	// a panicking application is reported as a failure so that its restart policy applies
+	defer func() {
+		if r := recover(); r != nil {
+			cancel()
+			err = dispatchRecoveredPanic(ctx, s.pm, s.clock, s.Name(), "", r)
+		}
+	}()
+
+	err = s.ApplicationSubsystem.Run(ctxRun)
 	cancel()
 
 	return err
 }
 
 func (s *supervisedApplicationSubsystem) Stop() {
+	s.ensureInit()
 	atomic.StoreUint32(&s.stopped, 1)
 	select {
 	case s.stopTrigger <- struct{}{}:
@@ -175,26 +289,151 @@ func (s *supervisedApplicationSubsystem) Stop() {
 }
 
 func (s *supervisedApplicationSubsystem) Start() {
+	s.ensureInit()
 	atomic.StoreUint32(&s.stopped, 0)
+
+	// discard a pending stop that was not consumed while the application was stopped
+	select {
+	case <-s.stopTrigger:
+	default:
+	}
+
 	select {
 	case s.resumeChan <- struct{}{}:
 	default:
 	}
 }
 
+// Restart cancels the current run of the application, and immediately runs it again
+// without it being considered as a failure. A stopped application is started.
+func (s *supervisedApplicationSubsystem) Restart() {
+	s.ensureInit()
+	if atomic.LoadUint32(&s.stopped) == 1 {
+		s.Start()
+		return
+	}
+
+	atomic.StoreUint32(&s.restartRequested, 1)
+	select {
+	case s.stopTrigger <- struct{}{}:
+	default:
+	}
+}
+
 func (s *supervisedApplicationSubsystem) Terminate() {
 	s.ensureInit()
+	s.terminateOnce.Do(func() { close(s.terminateChan) })
+}
+
+// Status returns a snapshot of the current state of the application.
+func (s *supervisedApplicationSubsystem) Status() SupervisedApplicationStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := SupervisedApplicationStatus{
+		Name:         s.Name(),
+		State:        s.state,
+		RestartCount: s.restartCount,
+		LastError:    s.lastError,
+		StartedAt:    s.startedAt,
+	}
+	if status.State == "" {
+		status.State = SupervisedApplicationStatePending
+	}
+	if !s.startedAt.IsZero() {
+		status.Uptime = s.clock.Now().Sub(s.startedAt)
+	}
+	if s.restarts != nil {
+		state := s.restarts.getState()
+		status.FailureCount = state.FailureCount
+		status.CircuitBreakerOpen = state.CircuitOpen
+	}
+
+	return status
+}
+
+// beginRun records a run of the application under supervision, it returns false if the application is retired.
+func (s *supervisedApplicationSubsystem) beginRun() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retired {
+		return false
+	}
+	s.running.Add(1)
+
+	return true
+}
+
+// wait retires the application and waits for it to no longer run under supervision or for ctx to be done.
+func (s *supervisedApplicationSubsystem) wait(ctx context.Context) error {
+	// once retired, the application is no longer launched so that running is not added to while waited on
+	s.mu.Lock()
+	s.retired = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// relaunch marks an application that is no longer running under supervision as pending
+// so that it can be run again. It returns false if the application is still supervised.
+func (s *supervisedApplicationSubsystem) relaunch() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case SupervisedApplicationStateExited, SupervisedApplicationStateGivenUp:
+		s.state = SupervisedApplicationStatePending
+		atomic.StoreUint32(&s.stopped, 0)
+		atomic.StoreUint32(&s.restartRequested, 0)
+
+		// discard stops and resumes requested while the application was not running, they would otherwise
+		// cancel the new run as soon as it starts
+		select {
+		case <-s.stopTrigger:
+		default:
+		}
+		select {
+		case <-s.resumeChan:
+		default:
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *supervisedApplicationSubsystem) isTerminating() bool {
 	select {
-	case s.terminateChan <- struct{}{}:
+	case <-s.terminateChan:
+		return true
 	default:
+		return false
 	}
 }
 
+func (s *supervisedApplicationSubsystem) setState(state SupervisedApplicationState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
 // ensureInit lazily initializes the control channels
 func (s *supervisedApplicationSubsystem) ensureInit() {
 	s.initOnce.Do(func() {
 		s.stopTrigger = make(chan struct{}, 1)
 		s.resumeChan = make(chan struct{}, 1)
-		s.terminateChan = make(chan struct{}, 1)
+		s.terminateChan = make(chan struct{})
 	})
 }
@@ -8,6 +8,15 @@ const (
 	ApplicationSubsystemWillRestartPluginHookName       SystemPluginHookName = "application_subsystem.will.restart"
 	ApplicationSubsystemRestartedPluginHookName         SystemPluginHookName = "application_subsystem.restarted"
 	ApplicationSubsystemMaxRestartReachedPluginHookName SystemPluginHookName = "application_subsystem.max.restart.reached"
+
+	ApplicationSubsystemStopRequestedPluginHookName    SystemPluginHookName = "application_subsystem.stop.requested"
+	ApplicationSubsystemStartRequestedPluginHookName   SystemPluginHookName = "application_subsystem.start.requested"
+	ApplicationSubsystemRestartRequestedPluginHookName SystemPluginHookName = "application_subsystem.restart.requested"
+
+	ApplicationSubsystemAddedPluginHookName   SystemPluginHookName = "application_subsystem.added"
+	ApplicationSubsystemRemovedPluginHookName SystemPluginHookName = "application_subsystem.removed"
+
+	ApplicationSubsystemTeardownTimedOutPluginHookName SystemPluginHookName = "application_subsystem.teardown.timed_out"
 )
 
 type ApplicationSubsystemWillRestartHook struct {
@@ -55,3 +64,69 @@ type ApplicationSubsystemMaxRestartReachedHook struct {
 func (e ApplicationSubsystemMaxRestartReachedHook) HookName() SystemPluginHookName {
 	return ApplicationSubsystemMaxRestartReachedPluginHookName
 }
+
+type ApplicationSubsystemStopRequestedHook struct {
+	ApplicationName string
+	RequestedAt     time.Time
+}
+
+func (e ApplicationSubsystemStopRequestedHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemStopRequestedPluginHookName
+}
+
+type ApplicationSubsystemStartRequestedHook struct {
+	ApplicationName string
+	RequestedAt     time.Time
+}
+
+func (e ApplicationSubsystemStartRequestedHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemStartRequestedPluginHookName
+}
+
+type ApplicationSubsystemRestartRequestedHook struct {
+	ApplicationName string
+	RequestedAt     time.Time
+}
+
+func (e ApplicationSubsystemRestartRequestedHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemRestartRequestedPluginHookName
+}
+
+// ApplicationSubsystemAddedHook is dispatched when an application is added to a running supervisor,
+// once it was initialized or failed to.
+type ApplicationSubsystemAddedHook struct {
+	ApplicationName string
+	StartedAt       time.Time
+	EndedAt         time.Time
+	Error           error
+}
+
+func (e ApplicationSubsystemAddedHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemAddedPluginHookName
+}
+
+// ApplicationSubsystemRemovedHook is dispatched when an application is removed from a running supervisor,
+// once it was terminated and torn down.
+type ApplicationSubsystemRemovedHook struct {
+	ApplicationName string
+	StartedAt       time.Time
+	EndedAt         time.Time
+	Error           error
+}
+
+func (e ApplicationSubsystemRemovedHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemRemovedPluginHookName
+}
+
+// ApplicationSubsystemTeardownTimedOutHook is dispatched when a supervised application does not complete
+// its teardown within its teardown timeout. The supervisor no longer waits for it.
+type ApplicationSubsystemTeardownTimedOutHook struct {
+	ApplicationName string
+	Timeout         time.Duration
+	StartedAt       time.Time
+	Error           error
+}
+
+func (e ApplicationSubsystemTeardownTimedOutHook) HookName() SystemPluginHookName {
+	return ApplicationSubsystemTeardownTimedOutPluginHookName
+}
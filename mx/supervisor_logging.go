@@ -52,9 +52,41 @@ func (p supervisorLoggingPlugin) OnHook(ctx context.Context, hook SystemPluginHo
 			slog.Time("reachedAt", e.ReachedAt),
 			slog.Any(logKeyError, e.Error),
 		)
+
+	case ApplicationSubsystemStopRequestedHook:
+		Log(ctx).Info(fmt.Sprintf("stopping supervised application subsystem %q", e.ApplicationName))
+
+	case ApplicationSubsystemStartRequestedHook:
+		Log(ctx).Info(fmt.Sprintf("starting supervised application subsystem %q", e.ApplicationName))
+
+	case ApplicationSubsystemRestartRequestedHook:
+		Log(ctx).Info(fmt.Sprintf("manually restarting supervised application subsystem %q", e.ApplicationName))
+
+	case ApplicationSubsystemAddedHook:
+		if e.Error != nil {
+			Log(ctx).Error(fmt.Sprintf("failed to add supervised application subsystem %q", e.ApplicationName), slog.Any(logKeyError, e.Error))
+			break
+		}
+		Log(ctx).Info(fmt.Sprintf("added supervised application subsystem %q", e.ApplicationName), slog.Duration("duration", e.EndedAt.Sub(e.StartedAt)))
+
+	case ApplicationSubsystemTeardownTimedOutHook:
+		Log(ctx).Error(
+			fmt.Sprintf("supervised application subsystem %q did not tear down in time", e.ApplicationName),
+			slog.Duration("timeout", e.Timeout),
+			slog.Any(logKeyError, e.Error),
+		)
+
+	case ApplicationSubsystemRemovedHook:
+		if e.Error != nil {
+			Log(ctx).Error(fmt.Sprintf("failed to cleanly remove supervised application subsystem %q", e.ApplicationName), slog.Any(logKeyError, e.Error))
+			break
+		}
+		Log(ctx).Info(fmt.Sprintf("removed supervised application subsystem %q", e.ApplicationName), slog.Duration("duration", e.EndedAt.Sub(e.StartedAt)))
 	}
 
 	return nil
 }
 
 func (supervisorLoggingPlugin) Name() string { return "supervisor.logging" }
+
+func (supervisorLoggingPlugin) builtIn() {}
@@ -2,10 +2,15 @@ package mx
 
 import (
 	"math"
+	"math/rand/v2"
 	"sync"
 	"time"
 )
 
+// Restart policies of supervised applications, deciding whether an application is run again once it returns:
+//   - no never runs it again;
+//   - always and unless-stopped run it again whether it failed or not, unless stopped manually;
+//   - on-failure runs it again only when it returned an error, an application returning nil has completed and exits.
 const (
 	ApplicationSubsystemRestartPolicyNo            = "no"
 	ApplicationSubsystemRestartPolicyAlways        = "always"
@@ -13,21 +18,47 @@ const (
 	ApplicationSubsystemRestartPolicyUnlessStopped = "unless-stopped"
 )
 
+// BackoffJitter indicates how randomness is applied to the computed backoff delay
+// of a RestartPolicy in order to avoid synchronized restarts of multiple applications.
+type BackoffJitter string
+
+const (
+	// BackoffJitterNone uses the computed exponential delay as is.
+	BackoffJitterNone BackoffJitter = "none"
+
+	// BackoffJitterFull picks a random delay between zero and the computed exponential delay.
+	BackoffJitterFull BackoffJitter = "full"
+
+	// BackoffJitterEqual keeps half of the computed exponential delay and randomizes the other half.
+	BackoffJitterEqual BackoffJitter = "equal"
+)
+
 const (
 	defaultMaxRestarts               = 5
+	defaultInitialBackoff            = 1 * time.Second
+	defaultMaxBackoff                = 5 * time.Minute
+	defaultBackoffMultiplier         = 2.0
 	defaultCircuitBreakerThreshold   = 3
 	defaultCircuitBreakerResetTimout = 30 * time.Second
 	defaultCircuitBreakerWindow      = 10 * time.Second
 )
 
+// DefaultRestartPolicy is the policy used by supervised applications that do not specify one.
+// It only holds configuration, every application keeps track of its own restart state.
 var DefaultRestartPolicy = NewApplicationSubsystemRestartPolicy(ApplicationSubsystemRestartPolicyOnFailure)
 
 type RestartPolicy struct {
 	policy string
 
-	maxRestarts      int
+	maxRestarts int
+	// maximum time spent restarting an application since its first failure, zero means no limit.
 	maxRetryDuration time.Duration
 
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	backoffMultiplier float64
+	jitter            BackoffJitter
+
 	circuitBreakerEnabled bool
 	// number of failures within the window to trigger circuit breaker and prevent
 	// rapid-fire restart because of quick errors.
@@ -37,40 +68,92 @@ type RestartPolicy struct {
 	circuitBreakerResetTimeout time.Duration
 	// time window for counting failures towards the circuit breaker threshold
 	circuitBreakerWindow time.Duration
-
-	state *restartPolicyState
-}
-
-type restartPolicyState struct {
-	mu              sync.Mutex
-	attemptCount    int
-	lastError       error
-	failureWindow   []time.Time
-	circuitOpen     bool
-	circuitOpenTime time.Time
 }
 
 func NewApplicationSubsystemRestartPolicy(policy string) *RestartPolicy {
 	return &RestartPolicy{
 		policy:                     policy,
 		maxRestarts:                defaultMaxRestarts,
+		initialBackoff:             defaultInitialBackoff,
+		maxBackoff:                 defaultMaxBackoff,
+		backoffMultiplier:          defaultBackoffMultiplier,
+		jitter:                     BackoffJitterNone,
 		circuitBreakerEnabled:      true,
 		circuitBreakerThreshold:    defaultCircuitBreakerThreshold,
 		circuitBreakerResetTimeout: defaultCircuitBreakerResetTimout,
 		circuitBreakerWindow:       defaultCircuitBreakerWindow,
-		state:                      &restartPolicyState{},
 	}
 }
 
+// WithMaxRestarts sets the maximum number of consecutive restarts, zero means no limit.
 func (p *RestartPolicy) WithMaxRestarts(max int) *RestartPolicy {
 	p.maxRestarts = max
 	return p
 }
 
-func (p *RestartPolicy) shouldRestart(err error) bool {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
+// WithMaxRetryDuration sets the maximum time spent restarting an application since its first
+// failure before giving up, zero means no limit.
+func (p *RestartPolicy) WithMaxRetryDuration(d time.Duration) *RestartPolicy {
+	p.maxRetryDuration = d
+	return p
+}
 
+// WithBackoff configures the exponential backoff between restarts: the first restart waits for initial,
+// every following restart multiplies the previous delay by multiplier, up to max.
+func (p *RestartPolicy) WithBackoff(initial time.Duration, max time.Duration, multiplier float64) *RestartPolicy {
+	if initial < 0 || max < 0 {
+		panic("restart policy: backoff durations cannot be negative")
+	}
+	if multiplier < 1 {
+		panic("restart policy: backoff multiplier cannot be lower than 1")
+	}
+	p.initialBackoff = initial
+	p.maxBackoff = max
+	p.backoffMultiplier = multiplier
+	return p
+}
+
+// WithJitter sets the jitter applied to the backoff delay.
+func (p *RestartPolicy) WithJitter(jitter BackoffJitter) *RestartPolicy {
+	switch jitter {
+	case BackoffJitterNone, BackoffJitterFull, BackoffJitterEqual:
+	default:
+		panic("restart policy: unsupported backoff jitter " + string(jitter))
+	}
+	p.jitter = jitter
+	return p
+}
+
+// WithCircuitBreaker enables the circuit breaker that gives up restarting an application
+// failing threshold times within window. Once open, it is closed again after resetTimeout.
+func (p *RestartPolicy) WithCircuitBreaker(threshold int, window time.Duration, resetTimeout time.Duration) *RestartPolicy {
+	if threshold <= 0 {
+		panic("restart policy: circuit breaker threshold must be greater than zero")
+	}
+	p.circuitBreakerEnabled = true
+	p.circuitBreakerThreshold = threshold
+	p.circuitBreakerWindow = window
+	p.circuitBreakerResetTimeout = resetTimeout
+	return p
+}
+
+// WithoutCircuitBreaker disables the circuit breaker.
+func (p *RestartPolicy) WithoutCircuitBreaker() *RestartPolicy {
+	p.circuitBreakerEnabled = false
+	return p
+}
+
+// Backoff returns the delay before the given restart attempt (starting at zero) without jitter.
+func (p *RestartPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.initialBackoff) * math.Pow(p.backoffMultiplier, float64(attempt))
+	if delay > float64(p.maxBackoff) || math.IsInf(delay, 1) {
+		return p.maxBackoff
+	}
+
+	return time.Duration(delay)
+}
+
+func (p *RestartPolicy) shouldRestart(err error) bool {
 	switch p.policy {
 	case ApplicationSubsystemRestartPolicyNo:
 		return false
@@ -85,110 +168,155 @@ func (p *RestartPolicy) shouldRestart(err error) bool {
 	}
 }
 
-func (p *RestartPolicy) recordFailure(err error, now time.Time) {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
+func (p *RestartPolicy) applyJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
 
-	p.state.lastError = err
-	p.state.failureWindow = append(p.state.failureWindow, now)
+	switch p.jitter {
+	case BackoffJitterFull:
+		return time.Duration(rand.Int64N(int64(delay) + 1))
+	case BackoffJitterEqual:
+		half := delay / 2
+		return half + time.Duration(rand.Int64N(int64(delay-half)+1))
+	default:
+		return delay
+	}
+}
 
-	p.prunFailureWindow(now)
-	p.updateCircuitBreaker(now)
+// restartTracker keeps track of the restart state of a single supervised application
+// according to a RestartPolicy.
+type restartTracker struct {
+	policy *RestartPolicy
+
+	mu              sync.Mutex
+	attemptCount    int
+	lastError       error
+	firstFailureAt  time.Time
+	failureWindow   []time.Time
+	circuitOpen     bool
+	circuitOpenTime time.Time
+}
+
+func newRestartTracker(policy *RestartPolicy) *restartTracker {
+	return &restartTracker{policy: policy}
+}
+
+func (t *restartTracker) recordFailure(err error, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastError = err
+	if t.firstFailureAt.IsZero() {
+		t.firstFailureAt = now
+	}
+	t.failureWindow = append(t.failureWindow, now)
+
+	t.pruneFailureWindow(now)
+	t.updateCircuitBreaker(now)
 }
 
-func (p *RestartPolicy) prunFailureWindow(now time.Time) {
-	cutoff := now.Add(-p.circuitBreakerWindow)
+func (t *restartTracker) pruneFailureWindow(now time.Time) {
+	cutoff := now.Add(-t.policy.circuitBreakerWindow)
 	i := 0
-	for i < len(p.state.failureWindow) && p.state.failureWindow[i].Before(cutoff) {
+	for i < len(t.failureWindow) && t.failureWindow[i].Before(cutoff) {
 		i++
 	}
-	p.state.failureWindow = p.state.failureWindow[i:]
+	t.failureWindow = t.failureWindow[i:]
 }
 
-func (p *RestartPolicy) updateCircuitBreaker(now time.Time) {
-	if !p.circuitBreakerEnabled {
+func (t *restartTracker) updateCircuitBreaker(now time.Time) {
+	if !t.policy.circuitBreakerEnabled {
 		return
 	}
 
-	if p.state.circuitOpen {
-		if now.Sub(p.state.circuitOpenTime) > p.circuitBreakerResetTimeout {
-			p.state.circuitOpen = false
-			p.state.failureWindow = nil
+	if t.circuitOpen {
+		if now.Sub(t.circuitOpenTime) > t.policy.circuitBreakerResetTimeout {
+			t.circuitOpen = false
+			t.failureWindow = nil
 		}
 		return
 	}
 
 	// Circuit breaker opens when failures reach threshold within the window (rapid-fire safety)
-	if len(p.state.failureWindow) >= p.circuitBreakerThreshold {
-		p.state.circuitOpen = true
-		p.state.circuitOpenTime = now
+	if len(t.failureWindow) >= t.policy.circuitBreakerThreshold {
+		t.circuitOpen = true
+		t.circuitOpenTime = now
 	}
 }
 
-func (p *RestartPolicy) isCircuitOpen() bool {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
-	return p.state.circuitOpen
+func (t *restartTracker) isCircuitOpen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.circuitOpen
 }
 
-func (p *RestartPolicy) canRestart(now time.Time) bool {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
+func (t *restartTracker) isRetryDurationExceeded(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retryDurationExceeded(now)
+}
+
+func (t *restartTracker) retryDurationExceeded(now time.Time) bool {
+	if t.policy.maxRetryDuration <= 0 || t.firstFailureAt.IsZero() {
+		return false
+	}
+	return now.Sub(t.firstFailureAt) > t.policy.maxRetryDuration
+}
+
+func (t *restartTracker) canRestart(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	if p.state.circuitOpen {
-		if now.Sub(p.state.circuitOpenTime) > p.circuitBreakerResetTimeout {
-			p.state.circuitOpen = false
-			p.state.failureWindow = nil
+	if t.circuitOpen {
+		if now.Sub(t.circuitOpenTime) > t.policy.circuitBreakerResetTimeout {
+			t.circuitOpen = false
+			t.failureWindow = nil
 			return true
 		}
 		return false
 	}
 
-	if p.maxRestarts > 0 && p.state.attemptCount >= p.maxRestarts {
+	if t.policy.maxRestarts > 0 && t.attemptCount >= t.policy.maxRestarts {
 		return false
 	}
 
-	return true
+	return !t.retryDurationExceeded(now)
 }
 
-func (p *RestartPolicy) nextRetryDelay() time.Duration {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
-
-	exponentialDelay := time.Duration(math.Pow(2, float64(p.state.attemptCount))) * time.Second
-	maxDelay := 5 * time.Minute
-	if exponentialDelay > maxDelay {
-		exponentialDelay = maxDelay
-	}
+func (t *restartTracker) nextRetryDelay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	return exponentialDelay
+	return t.policy.applyJitter(t.policy.Backoff(t.attemptCount))
 }
 
-func (p *RestartPolicy) recordAttempt() {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
-	p.state.attemptCount++
+func (t *restartTracker) recordAttempt() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attemptCount++
 }
 
-func (p *RestartPolicy) resetState() {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
+func (t *restartTracker) resetState() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	p.state.attemptCount = 0
-	p.state.failureWindow = nil
-	p.state.circuitOpen = false
-	p.state.lastError = nil
+	t.attemptCount = 0
+	t.failureWindow = nil
+	t.firstFailureAt = time.Time{}
+	t.circuitOpen = false
+	t.lastError = nil
 }
 
-func (p *RestartPolicy) getState() RestartPolicyState {
-	p.state.mu.Lock()
-	defer p.state.mu.Unlock()
+func (t *restartTracker) getState() RestartPolicyState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	return RestartPolicyState{
-		AttemptCount: p.state.attemptCount,
-		FailureCount: len(p.state.failureWindow),
-		CircuitOpen:  p.state.circuitOpen,
-		LastError:    p.state.lastError,
+		AttemptCount: t.attemptCount,
+		FailureCount: len(t.failureWindow),
+		CircuitOpen:  t.circuitOpen,
+		LastError:    t.lastError,
 	}
 }
 
@@ -0,0 +1,173 @@
+package mx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	t.Run("GIVEN default policy WHEN computing backoff THEN should double every attempt", func(t *testing.T) {
+		p := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyOnFailure)
+		require.Equal(t, 1*time.Second, p.Backoff(0))
+		require.Equal(t, 2*time.Second, p.Backoff(1))
+		require.Equal(t, 8*time.Second, p.Backoff(3))
+	})
+	t.Run("GIVEN custom backoff WHEN exceeding max THEN should be capped", func(t *testing.T) {
+		p := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyOnFailure).
+			WithBackoff(100*time.Millisecond, time.Second, 3)
+		require.Equal(t, 100*time.Millisecond, p.Backoff(0))
+		require.Equal(t, 900*time.Millisecond, p.Backoff(2))
+		require.Equal(t, time.Second, p.Backoff(3))
+		require.Equal(t, time.Second, p.Backoff(10_000))
+	})
+}
+
+func TestRestartPolicy_Builder(t *testing.T) {
+	t.Run("GIVEN invalid multiplier WHEN configuring backoff THEN should panic", func(t *testing.T) {
+		p := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyAlways)
+		require.Panics(t, func() { p.WithBackoff(time.Second, time.Minute, 0.5) })
+	})
+	t.Run("GIVEN unknown jitter WHEN configuring jitter THEN should panic", func(t *testing.T) {
+		p := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyAlways)
+		require.Panics(t, func() { p.WithJitter("random") })
+	})
+	t.Run("GIVEN zero threshold WHEN configuring circuit breaker THEN should panic", func(t *testing.T) {
+		p := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyAlways)
+		require.Panics(t, func() { p.WithCircuitBreaker(0, time.Second, time.Second) })
+	})
+}
+
+// runSupervisedApplications runs a supervisor until every one of its applications reaches the given state,
+// and returns the hooks dispatched meanwhile.
+func runSupervisedApplications(t *testing.T, supervisor *mx.Supervisor, state mx.SupervisedApplicationState) []mx.SystemPluginHook {
+	t.Helper()
+	recorder := &hookRecorderPlugin{}
+	runStoppableSystem(t, mx.NewSystem("test").WithPlugin(recorder), newStoppableSupervisor(supervisor))
+
+	require.Eventually(t, func() bool {
+		statuses := supervisor.Status()
+		for _, status := range statuses {
+			if status.State != state {
+				return false
+			}
+		}
+		return len(statuses) != 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	return recorder.Hooks()
+}
+
+func failingApplication(name string) funcApplicationSubsystem {
+	return funcApplicationSubsystem{name: name, run: func(context.Context) error { return errors.New("failed") }}
+}
+
+func TestRestartPolicy_Jitter(t *testing.T) {
+	const backoff = 4 * time.Millisecond
+	restartDelays := func(t *testing.T, jitter mx.BackoffJitter) []time.Duration {
+		policy := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyOnFailure).
+			WithBackoff(backoff, backoff, 1).
+			WithMaxRestarts(20).
+			WithoutCircuitBreaker().
+			WithJitter(jitter)
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(failingApplication("failing"), &mx.SupervisionOptions{RestartPolicy: policy})
+		hooks := runSupervisedApplications(t, supervisor, mx.SupervisedApplicationStateGivenUp)
+
+		var delays []time.Duration
+		for _, h := range hooks {
+			if h, ok := h.(mx.ApplicationSubsystemWillRestartHook); ok {
+				delays = append(delays, h.RestartDelay)
+			}
+		}
+		require.Len(t, delays, 20)
+
+		return delays
+	}
+
+	t.Run("GIVEN full jitter WHEN restarting THEN delays should be between zero and the backoff", func(t *testing.T) {
+		delays := restartDelays(t, mx.BackoffJitterFull)
+		for _, d := range delays {
+			require.GreaterOrEqual(t, d, time.Duration(0))
+			require.LessOrEqual(t, d, backoff)
+		}
+		require.NotEqual(t, delays[0], delays[1], "delays should be randomized")
+	})
+
+	t.Run("GIVEN equal jitter WHEN restarting THEN delays should be between half the backoff and the backoff", func(t *testing.T) {
+		delays := restartDelays(t, mx.BackoffJitterEqual)
+		for _, d := range delays {
+			require.GreaterOrEqual(t, d, backoff/2)
+			require.LessOrEqual(t, d, backoff)
+		}
+	})
+
+	t.Run("GIVEN no jitter WHEN restarting THEN delays should be the backoff", func(t *testing.T) {
+		for _, d := range restartDelays(t, mx.BackoffJitterNone) {
+			require.Equal(t, backoff, d)
+		}
+	})
+}
+
+func TestRestartPolicy_SharedBetweenApplications(t *testing.T) {
+	t.Run("GIVEN applications sharing a policy WHEN one fails THEN the restart state of the others should be untouched", func(t *testing.T) {
+		policy := mx.NewApplicationSubsystemRestartPolicy(mx.ApplicationSubsystemRestartPolicyOnFailure).
+			WithBackoff(time.Millisecond, time.Millisecond, 1).
+			WithMaxRestarts(3).
+			WithoutCircuitBreaker()
+
+		failedOnce := false
+		flaky := funcApplicationSubsystem{name: "flaky", run: func(ctx context.Context) error {
+			if !failedOnce {
+				failedOnce = true
+				return errors.New("failed")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(failingApplication("failing"), &mx.SupervisionOptions{RestartPolicy: policy}).
+			WithApplicationSubsystem(flaky, &mx.SupervisionOptions{RestartPolicy: policy})
+		runSupervisor(t, supervisor)
+
+		require.Eventually(t, func() bool {
+			statuses := supervisor.Status()
+			return len(statuses) == 2 && statuses[0].State == mx.SupervisedApplicationStateGivenUp && statuses[1].State == mx.SupervisedApplicationStateRunning
+		}, 5*time.Second, 5*time.Millisecond)
+
+		statuses := supervisor.Status()
+		require.Equal(t, "failing", statuses[0].Name)
+		require.Equal(t, 3, statuses[0].RestartCount)
+		require.Equal(t, "flaky", statuses[1].Name)
+		require.Equal(t, 1, statuses[1].RestartCount)
+	})
+
+	t.Run("GIVEN applications using the default policy WHEN one fails THEN only its restart state should change", func(t *testing.T) {
+		calls := 0
+		flaky := funcApplicationSubsystem{name: "flaky", run: func(ctx context.Context) error {
+			calls++
+			if calls == 1 {
+				return errors.New("failed")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(flaky, nil).
+			WithApplicationSubsystem(blockingApplication("blocking"), nil)
+		hooks := runSupervisedApplications(t, supervisor, mx.SupervisedApplicationStateRunning)
+
+		var restarted []string
+		for _, h := range hooks {
+			if h, ok := h.(mx.ApplicationSubsystemWillRestartHook); ok {
+				restarted = append(restarted, h.ApplicationName)
+				require.Equal(t, 1, h.RestartCount)
+			}
+		}
+		require.Equal(t, []string{"flaky"}, restarted)
+	})
+}
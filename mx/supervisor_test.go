@@ -0,0 +1,316 @@
+package mx_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+// stoppableSupervisor allows tests to shut a supervisor down without sending a signal to the process.
+type stoppableSupervisor struct {
+	*mx.Supervisor
+	stop chan struct{}
+}
+
+func newStoppableSupervisor(s *mx.Supervisor) stoppableSupervisor {
+	return stoppableSupervisor{Supervisor: s, stop: make(chan struct{})}
+}
+
+func (s stoppableSupervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return s.Supervisor.Run(ctx)
+}
+
+// runSupervisor runs the supervisor in a system, shut down once the test ends.
+func runSupervisor(t *testing.T, s *mx.Supervisor) {
+	t.Helper()
+	runStoppableSystem(t, mx.NewSystem("test"), newStoppableSupervisor(s))
+}
+
+// runStoppableSystem runs the system in the background, stopping it and waiting for it to exit once the test ends
+// so that no system outlives its test.
+func runStoppableSystem(t *testing.T, system *mx.SystemConf, supervisor stoppableSupervisor) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = system.RunE(supervisor)
+	}()
+	t.Cleanup(func() {
+		close(supervisor.stop)
+		<-done
+	})
+}
+
+func blockingApplication(name string) funcApplicationSubsystem {
+	return funcApplicationSubsystem{name: name, run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+}
+
+func requireApplicationState(t *testing.T, s *mx.Supervisor, state mx.SupervisedApplicationState) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		statuses := s.Status()
+		return len(statuses) == 1 && statuses[0].State == state
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSupervisor_Control(t *testing.T) {
+	t.Run("GIVEN running application WHEN stopping, starting and restarting THEN status should reflect it", func(t *testing.T) {
+		supervisor := mx.NewSupervisor().WithApplicationSubsystem(blockingApplication("blocking"), nil)
+		runSupervisor(t, supervisor)
+
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateRunning)
+
+		require.NoError(t, supervisor.Stop(context.Background(), "blocking"))
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateStopped)
+
+		require.NoError(t, supervisor.Start(context.Background(), "blocking"))
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateRunning)
+
+		require.NoError(t, supervisor.Restart(context.Background(), "blocking"))
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateRunning)
+
+		status := supervisor.Status()[0]
+		require.Equal(t, "blocking", status.Name)
+		require.Zero(t, status.FailureCount)
+		require.NoError(t, status.LastError)
+	})
+
+	t.Run("GIVEN unknown application WHEN stopping THEN should return not found error", func(t *testing.T) {
+		supervisor := mx.NewSupervisor()
+		err := supervisor.Stop(context.Background(), "unknown")
+		require.ErrorIs(t, err, misas.ErrNotFound)
+	})
+
+	t.Run("GIVEN exited application WHEN starting THEN should run it again", func(t *testing.T) {
+		runs := make(chan struct{}, 10)
+		app := funcApplicationSubsystem{name: "once", run: func(context.Context) error {
+			runs <- struct{}{}
+			return nil
+		}}
+		supervisor := mx.NewSupervisor().WithApplicationSubsystem(app, nil)
+		runSupervisor(t, supervisor)
+
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateExited)
+		require.NoError(t, supervisor.Start(context.Background(), "once"))
+		require.Eventually(t, func() bool { return len(runs) == 2 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("GIVEN exited application stopped WHEN starting THEN should run it again without cancelling it", func(t *testing.T) {
+		var calls atomic.Int32
+		cancelled := make(chan struct{})
+		app := funcApplicationSubsystem{name: "once", run: func(ctx context.Context) error {
+			if calls.Add(1) == 1 {
+				return nil
+			}
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		}}
+		supervisor := mx.NewSupervisor().WithApplicationSubsystem(app, nil)
+		runSupervisor(t, supervisor)
+
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateExited)
+		require.NoError(t, supervisor.Stop(context.Background(), "once"))
+		require.NoError(t, supervisor.Start(context.Background(), "once"))
+
+		requireApplicationState(t, supervisor, mx.SupervisedApplicationStateRunning)
+		require.Never(t, func() bool {
+			select {
+			case <-cancelled:
+				return true
+			default:
+				return false
+			}
+		}, 50*time.Millisecond, 5*time.Millisecond)
+
+		status := supervisor.Status()[0]
+		require.Equal(t, mx.SupervisedApplicationStateRunning, status.State)
+		require.Zero(t, status.FailureCount)
+		require.NoError(t, status.LastError)
+	})
+}
+
+// countingApplication is a blocking application counting its initializations and signaling its runs.
+type countingApplication struct {
+	funcApplicationSubsystem
+	initializations *atomic.Int32
+	started         chan struct{}
+}
+
+func newCountingApplication(name string) countingApplication {
+	app := countingApplication{initializations: &atomic.Int32{}, started: make(chan struct{}, 10)}
+	app.funcApplicationSubsystem = funcApplicationSubsystem{name: name, run: func(ctx context.Context) error {
+		app.started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	return app
+}
+
+func (a countingApplication) Initialize(context.Context) error {
+	a.initializations.Add(1)
+	return nil
+}
+
+func TestSupervisor_AddRemoveApplicationSubsystem(t *testing.T) {
+	t.Run("GIVEN running supervisor WHEN adding and removing an application THEN it should be supervised then torn down", func(t *testing.T) {
+		existing := newCountingApplication("existing")
+		supervisor := mx.NewSupervisor().WithApplicationSubsystem(existing, nil)
+		runSupervisor(t, supervisor)
+
+		// the supervisor is running once its applications are
+		select {
+		case <-existing.started:
+		case <-time.After(time.Second):
+			t.Fatal("existing application did not start")
+		}
+
+		runtime := newCountingApplication("runtime")
+		require.NoError(t, supervisor.AddApplicationSubsystem(context.Background(), runtime, nil))
+		select {
+		case <-runtime.started:
+		case <-time.After(time.Second):
+			t.Fatal("application added at runtime did not start")
+		}
+		require.Equal(t, int32(1), runtime.initializations.Load())
+		require.Eventually(t, func() bool {
+			statuses := supervisor.Status()
+			return len(statuses) == 2 && statuses[1].Name == "runtime" && statuses[1].State == mx.SupervisedApplicationStateRunning
+		}, time.Second, 5*time.Millisecond)
+
+		err := supervisor.AddApplicationSubsystem(context.Background(), blockingApplication("runtime"), nil)
+		require.ErrorIs(t, err, misas.ErrConflict)
+
+		require.NoError(t, supervisor.RemoveApplicationSubsystem(context.Background(), "runtime"))
+		require.Len(t, supervisor.Status(), 1)
+
+		err = supervisor.RemoveApplicationSubsystem(context.Background(), "runtime")
+		require.ErrorIs(t, err, misas.ErrNotFound)
+	})
+
+	t.Run("GIVEN supervisor not initialized WHEN adding an application THEN it should be initialized once and run with the others", func(t *testing.T) {
+		supervisor := mx.NewSupervisor()
+		app := newCountingApplication("early")
+		require.NoError(t, supervisor.AddApplicationSubsystem(context.Background(), app, nil))
+
+		runSupervisor(t, supervisor)
+
+		select {
+		case <-app.started:
+		case <-time.After(time.Second):
+			t.Fatal("application added before initialization did not start")
+		}
+		require.Equal(t, int32(1), app.initializations.Load())
+	})
+}
+
+type teardownApplication struct {
+	funcApplicationSubsystem
+	teardown func(ctx context.Context) error
+}
+
+func (a teardownApplication) Teardown(ctx context.Context) error { return a.teardown(ctx) }
+
+func TestSupervisor_Teardown(t *testing.T) {
+	t.Run("GIVEN blocking and failing teardowns WHEN tearing down THEN should time out and join errors without panicking", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(teardownApplication{
+				funcApplicationSubsystem: blockingApplication("blocking"),
+				teardown: func(ctx context.Context) error {
+					select {} // never completes
+				},
+			}, &mx.SupervisionOptions{TeardownTimeout: 10 * time.Millisecond}).
+			WithApplicationSubsystem(teardownApplication{
+				funcApplicationSubsystem: blockingApplication("failing"),
+				teardown:                 func(context.Context) error { return misas.ErrInternal },
+			}, nil)
+
+		system := mx.NewSystem("test").WithPlugin(recorder)
+		stoppable := newStoppableSupervisor(supervisor)
+		close(stoppable.stop)
+		require.NotPanics(t, func() { _ = system.RunE(stoppable) })
+
+		var timedOut []mx.ApplicationSubsystemTeardownTimedOutHook
+		var teardownErr error
+		for _, h := range recorder.Hooks() {
+			switch h := h.(type) {
+			case mx.ApplicationSubsystemTeardownTimedOutHook:
+				timedOut = append(timedOut, h)
+			case mx.SystemTeardownEndedHook:
+				teardownErr = h.Error
+			}
+		}
+		require.Len(t, timedOut, 1)
+		require.Equal(t, "blocking", timedOut[0].ApplicationName)
+		require.ErrorIs(t, teardownErr, misas.ErrTimeout)
+		require.ErrorIs(t, teardownErr, misas.ErrInternal)
+	})
+
+	t.Run("GIVEN dependent applications WHEN tearing down THEN dependents should be torn down first", func(t *testing.T) {
+		var mu sync.Mutex
+		var order []string
+		app := func(name string) teardownApplication {
+			return teardownApplication{
+				funcApplicationSubsystem: blockingApplication(name),
+				teardown: func(context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+					order = append(order, name)
+					return nil
+				},
+			}
+		}
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(app("database"), nil).
+			WithApplicationSubsystem(app("worker"), &mx.SupervisionOptions{DependsOn: []string{"database"}}).
+			WithApplicationSubsystem(app("api"), &mx.SupervisionOptions{DependsOn: []string{"worker"}})
+
+		stoppable := newStoppableSupervisor(supervisor)
+		close(stoppable.stop)
+		err := mx.NewSystem("test").RunE(stoppable)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, []string{"api", "worker", "database"}, order)
+	})
+
+	t.Run("GIVEN cyclic dependencies WHEN initializing THEN should fail", func(t *testing.T) {
+		supervisor := mx.NewSupervisor().
+			WithApplicationSubsystem(blockingApplication("a"), &mx.SupervisionOptions{DependsOn: []string{"b"}}).
+			WithApplicationSubsystem(blockingApplication("b"), &mx.SupervisionOptions{DependsOn: []string{"a"}})
+
+		err := mx.NewSystem("test").RunE(supervisor)
+		require.ErrorIs(t, err, misas.ErrInvalid)
+	})
+}
+
+func TestSystem_TeardownDeadline(t *testing.T) {
+	t.Run("GIVEN teardown exceeding deadline WHEN running THEN should force exit with timeout error", func(t *testing.T) {
+		app := teardownApplication{
+			funcApplicationSubsystem: funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }},
+			teardown:                 func(context.Context) error { select {} },
+		}
+
+		err := mx.NewSystem("test").WithTeardownDeadline(10 * time.Millisecond).RunE(app)
+		require.ErrorIs(t, err, misas.ErrTimeout)
+	})
+}
@@ -2,12 +2,14 @@ package mx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/morebec/misas/mtime"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/morebec/misas/misas"
 )
@@ -23,7 +25,7 @@ type System struct {
 	info               SystemInfo
 	logger             *slog.Logger
 	clock              mtime.Clock
-	pm                 SystemPluginManager
+	pm                 *systemPluginManager
 	builtInPlugins     []SystemPlugin
 	customPlugins      []SystemPlugin
 	commandBus         misas.CommandBus
@@ -31,6 +33,30 @@ type System struct {
 	businessSubsystems map[string]BusinessSubsystemConf
 	queryBus           misas.QueryBus
 	querySubsystems    map[string]QuerySubsystemConf
+	teardownDeadline   time.Duration
+
+	// teardowns of the initialized business and query subsystems, in initialization order
+	subsystemTeardowns []subsystemTeardown
+	// issues found by the non strict topology validation, logged at startup
+	topologyIssues []TopologyIssue
+	// topology written at startup in debug mode, nil if disabled
+	topologyDump *topologyDump
+}
+
+type topologyDump struct {
+	dir      string
+	topology Topology
+}
+
+type subsystemTeardown struct {
+	subsystemName string
+	teardown      func(context.Context) error
+}
+
+// subsystemLifecycleStep is either an initializer or a teardown registered with a subsystem.
+type subsystemLifecycleStep struct {
+	initialize func(context.Context) error
+	teardown   func(context.Context) error
 }
 
 func newSystem(sc *SystemConf) *System {
@@ -48,9 +74,17 @@ func newSystem(sc *SystemConf) *System {
 		sc.queryBus.Bind(misas.NewInMemoryQueryBus())
 	}
 
-	// Collect event buses for the system
+	pm := newPluginManager()
+
+	builtInPlugins := []SystemPlugin{loggingPlugin{}}
+	if sc.systemEvents {
+		builtInPlugins = append(builtInPlugins, systemEventsPlugin{eventBus: sc.eventBuses[SystemEventBusName], clock: sc.clock})
+	}
+
+	// Collect event buses for the system, intercepting publications to notify plugins
 	eventBuses := make(map[EventBusName]misas.EventBus, len(sc.eventBuses))
 	for name, eb := range sc.eventBuses {
+		eb.Bind(newHookedEventBus(name, eb.Get(), pm, sc.clock))
 		eventBuses[name] = eb
 	}
 
@@ -63,14 +97,15 @@ func newSystem(sc *SystemConf) *System {
 		},
 		clock:              sc.clock,
 		logger:             slog.New(sc.loggerHandler),
-		pm:                 newPluginManager(),
-		builtInPlugins:     []SystemPlugin{loggingPlugin{}},
+		pm:                 pm,
+		builtInPlugins:     builtInPlugins,
 		customPlugins:      sc.plugins,
 		commandBus:         sc.commandBus,
 		eventBuses:         eventBuses,
 		businessSubsystems: sc.businessSubsystems,
 		queryBus:           sc.queryBus,
 		querySubsystems:    sc.querySubsystems,
+		teardownDeadline:   sc.teardownDeadline,
 	}
 }
 
@@ -82,23 +117,44 @@ func (s *System) run(app ApplicationSubsystem) error {
 	return nil
 }
 
-func (s *System) doRun(app ApplicationSubsystem) error {
+func (s *System) doRun(app ApplicationSubsystem) (err error) {
 	ctx := newSystemContext(*s)
 
 	ctx, cancel := s.setupSignalHandling(ctx)
 	defer cancel()
 
-	s.loadPlugins(ctx, app)
+	if err := s.loadPlugins(ctx, app); err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	for _, issue := range s.topologyIssues {
+		Log(ctx).Warn(fmt.Sprintf("topology issue: %s", issue.Message), slog.String("kind", string(issue.Kind)))
+	}
+
+	if s.topologyDump != nil {
+		if err := dumpTopology(s.topologyDump.dir, s.topologyDump.topology); err != nil {
+			Log(ctx).Warn("failed to dump system topology", slog.Any(logKeyError, err))
+		} else {
+			Log(ctx).Debug(fmt.Sprintf("system topology dumped to %q", s.topologyDump.dir))
+		}
+	}
 
 	// Wrap app with management layer
 	app = newManagedApplicationSubsystem(app, s.pm, s.clock)
 	appCtx := newSubsystemContext(ctx, SubsystemInfo{Name: app.Name()})
 
+	// Deliver pending hooks to asynchronous plugins once torn down
+	defer s.closePlugins()
+
 	// Setup teardown with a fresh context (not the canceled one)
-	defer s.teardownSystem(newSystemContext(*s), app)
+	defer func() {
+		if teardownErr := s.teardownSystem(newSystemContext(*s), app); teardownErr != nil {
+			err = errors.Join(err, teardownErr)
+		}
+	}()
 
 	if err := s.initializeSystem(ctx, appCtx, app); err != nil {
-		return fmt.Errorf("failed to initialize application %q: %w", app.Name(), err)
+		return err
 	}
 
 	if err := s.executeSystem(ctx, appCtx, app); err != nil {
@@ -133,7 +189,7 @@ func (s *System) setupSignalHandling(ctx context.Context) (context.Context, cont
 	return ctx, cancel
 }
 
-func (s *System) loadPlugins(ctx context.Context, app ApplicationSubsystem) {
+func (s *System) loadPlugins(ctx context.Context, app ApplicationSubsystem) error {
 	// Collect all plugins: built-in, custom, and app-provided
 	allPlugins := make([]SystemPlugin, 0, len(s.builtInPlugins)+len(s.customPlugins)+1)
 	allPlugins = append(allPlugins, s.builtInPlugins...)
@@ -142,90 +198,152 @@ func (s *System) loadPlugins(ctx context.Context, app ApplicationSubsystem) {
 		allPlugins = append(allPlugins, plugin)
 	}
 
+	// Order plugins according to their metadata, failing on missing or cyclic dependencies
+	allPlugins, err := resolvePlugins(allPlugins)
+	if err != nil {
+		return err
+	}
+
 	// Add all plugins to the manager
 	for _, plugin := range allPlugins {
 		s.pm.AddPlugin(ctx, plugin)
 	}
+
+	return nil
 }
 
-func (s *System) initializeBusinessSubsystems(ctx context.Context) {
+func (s *System) initializeBusinessSubsystems(ctx context.Context) error {
 	for _, bsConf := range s.businessSubsystems {
-		bsCtx := newSubsystemContext(ctx, SubsystemInfo{Name: bsConf.name})
-
-		// Dispatch business subsystem initialization started hook
-		initStartedAt := s.clock.Now()
-		s.pm.DispatchHook(bsCtx, BusinessSubsystemInitializationStartedHook{
-			BusinessSubsystemName: bsConf.name,
-			StartedAt:             initStartedAt,
-		})
-
-		// Register command handlers
-		for cmdType, handler := range bsConf.commandHandlers {
-			s.commandBus.RegisterHandler(cmdType, handler)
+		if err := s.initializeBusinessSubsystem(ctx, bsConf); err != nil {
+			return fmt.Errorf("failed to initialize business subsystem %q: %w", bsConf.name, err)
 		}
+	}
+
+	return nil
+}
 
-		// Register event handlers
-		s.registerEventHandlers(bsCtx, bsConf.eventHandlers)
+func (s *System) initializeBusinessSubsystem(ctx context.Context, bsConf BusinessSubsystemConf) (err error) {
+	bsCtx := newSubsystemContext(ctx, SubsystemInfo{Name: bsConf.name})
 
+	// Dispatch business subsystem initialization started hook, guards can reject the initialization
+	initStartedAt := s.clock.Now()
+	defer func() {
 		// Dispatch business subsystem initialization ended hook
 		s.pm.DispatchHook(bsCtx, BusinessSubsystemInitializationEndedHook{
 			BusinessSubsystemName: bsConf.name,
 			StartedAt:             initStartedAt,
 			EndedAt:               s.clock.Now(),
-			Error:                 nil,
+			Error:                 err,
 		})
+	}()
+	if err := s.pm.dispatchGuardedHook(bsCtx, BusinessSubsystemInitializationStartedHook{
+		BusinessSubsystemName: bsConf.name,
+		StartedAt:             initStartedAt,
+	}); err != nil {
+		return err
 	}
-}
 
-func (s *System) initializeQuerySubsystems(ctx context.Context) {
-	for _, qsConf := range s.querySubsystems {
-		qsCtx := newSubsystemContext(ctx, SubsystemInfo{Name: qsConf.name})
+	if err := s.runSubsystemInitializers(bsCtx, bsConf.name, bsConf.lifecycle); err != nil {
+		return err
+	}
 
-		// Dispatch query subsystem initialization started hook
-		initStartedAt := s.clock.Now()
-		s.pm.DispatchHook(qsCtx, QuerySubsystemInitializationStartedHook{
-			QuerySubsystemName: qsConf.name,
-			StartedAt:          initStartedAt,
-		})
+	// Register command handlers
+	for cmdType, handler := range bsConf.commandHandlers {
+		s.commandBus.RegisterHandler(cmdType, withCommandHooks(bsConf.name, s.pm, s.clock,
+			withCommandPanicRecovery(bsConf.name, s.pm, s.clock, handler),
+		))
+	}
 
-		// Register query handlers
-		for queryType, handler := range qsConf.queryHandlers {
-			s.queryBus.RegisterHandler(queryType, handler)
+	// Register event handlers
+	s.registerEventHandlers(bsConf.name, bsConf.eventHandlers)
+
+	return nil
+}
+
+func (s *System) initializeQuerySubsystems(ctx context.Context) error {
+	for _, qsConf := range s.querySubsystems {
+		if err := s.initializeQuerySubsystem(ctx, qsConf); err != nil {
+			return fmt.Errorf("failed to initialize query subsystem %q: %w", qsConf.name, err)
 		}
+	}
+
+	return nil
+}
 
-		// Register event handlers
-		s.registerEventHandlers(qsCtx, qsConf.eventHandlers)
+func (s *System) initializeQuerySubsystem(ctx context.Context, qsConf QuerySubsystemConf) (err error) {
+	qsCtx := newSubsystemContext(ctx, SubsystemInfo{Name: qsConf.name})
 
+	// Dispatch query subsystem initialization started hook, guards can reject the initialization
+	initStartedAt := s.clock.Now()
+	defer func() {
 		// Dispatch query subsystem initialization ended hook
 		s.pm.DispatchHook(qsCtx, QuerySubsystemInitializationEndedHook{
 			QuerySubsystemName: qsConf.name,
 			StartedAt:          initStartedAt,
 			EndedAt:            s.clock.Now(),
-			Error:              nil,
+			Error:              err,
 		})
+	}()
+	if err := s.pm.dispatchGuardedHook(qsCtx, QuerySubsystemInitializationStartedHook{
+		QuerySubsystemName: qsConf.name,
+		StartedAt:          initStartedAt,
+	}); err != nil {
+		return err
+	}
+
+	if err := s.runSubsystemInitializers(qsCtx, qsConf.name, qsConf.lifecycle); err != nil {
+		return err
+	}
+
+	// Register query handlers
+	for queryType, handler := range qsConf.queryHandlers {
+		s.queryBus.RegisterHandler(queryType, withQueryHooks(qsConf.name, s.pm, s.clock,
+			withQueryPanicRecovery(qsConf.name, s.pm, s.clock, handler),
+		))
+	}
+
+	// Register event handlers
+	s.registerEventHandlers(qsConf.name, qsConf.eventHandlers)
+
+	return nil
+}
+
+// runSubsystemInitializers runs the initializers of a subsystem in registration order and records the teardowns
+// registered after them, to be run in reverse order at system teardown. When an initializer fails, only the teardowns
+// of the initializers that succeeded are recorded.
+func (s *System) runSubsystemInitializers(ctx context.Context, subsystemName string, lifecycle []subsystemLifecycleStep) error {
+	for _, step := range lifecycle {
+		if step.initialize != nil {
+			if err := step.initialize(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		s.subsystemTeardowns = append(s.subsystemTeardowns, subsystemTeardown{subsystemName: subsystemName, teardown: step.teardown})
 	}
+
+	return nil
 }
 
-func (s *System) registerEventHandlers(qsCtx context.Context, handlers map[EventBusName][]misas.EventHandler) {
+func (s *System) registerEventHandlers(subsystemName string, handlers map[EventBusName][]misas.EventHandler) {
 	for eventBusName, busHandlers := range handlers {
 		eb, ok := s.eventBuses[eventBusName]
 		if !ok {
-			Log(qsCtx).Warn(fmt.Sprintf(
-				"some event handler(s) are subscribed to event bus %q, but it does not publish events; skipping registration...",
-				eventBusName,
-			)) // This message can be suppressed by ensuring a call to system.EventBus(eventBusName)
+			// reported by the topology validation, this can be fixed by ensuring a call to system.EventBus(eventBusName)
 			continue
 		}
 		for _, h := range busHandlers {
-			eb.RegisterHandler(h)
+			eb.RegisterHandler(withEventHooks(subsystemName, eventBusName, s.pm, s.clock,
+				withEventPanicRecovery(subsystemName, s.pm, s.clock, h),
+			))
 		}
 	}
 }
 
 func (s *System) initializeSystem(ctx context.Context, appCtx context.Context, app ApplicationSubsystem) error {
-	// Dispatch initialization started hook
 	initializationStartedAt := s.clock.Now()
-	s.pm.DispatchHook(ctx, SystemInitializationStartedHook{
+	// Dispatch initialization started hook, guards can reject the initialization of the system
+	err := s.pm.dispatchGuardedHook(ctx, SystemInitializationStartedHook{
 		Name:        s.info.Name,
 		Version:     s.info.Version,
 		Environment: s.info.Environment,
@@ -234,12 +352,20 @@ func (s *System) initializeSystem(ctx context.Context, appCtx context.Context, a
 		System:      s,
 	})
 
-	s.initializeBusinessSubsystems(ctx)
+	if err == nil {
+		err = s.initializeBusinessSubsystems(ctx)
+	}
 
-	s.initializeQuerySubsystems(ctx)
+	if err == nil {
+		err = s.initializeQuerySubsystems(ctx)
+	}
 
 	// Initialize the application subsystem
-	err := app.Initialize(appCtx)
+	if err == nil {
+		if err = app.Initialize(appCtx); err != nil {
+			err = fmt.Errorf("failed to initialize application %q: %w", app.Name(), err)
+		}
+	}
 	s.pm.DispatchHook(ctx, SystemInitializationEndedHook{
 		StartedAt: initializationStartedAt,
 		EndedAt:   s.clock.Now(),
@@ -265,21 +391,63 @@ func (s *System) executeSystem(ctx context.Context, appCtx context.Context, app
 	return err
 }
 
-func (s *System) teardownSystem(ctx context.Context, app ApplicationSubsystem) {
+// teardownSystem tears down the application subsystem. Teardown errors are reported through hooks, only exceeding
+// the teardown deadline is returned as it means the system exited without completing its teardown.
+func (s *System) teardownSystem(ctx context.Context, app ApplicationSubsystem) error {
 	// Dispatch teardown started hook
 	teardownStartedAt := s.clock.Now()
 	s.pm.DispatchHook(ctx, SystemTeardownStartedHook{StartedAt: teardownStartedAt})
 
 	// Create a fresh context for teardown (not the canceled one)
-	teardownCtx := newSubsystemContext(ctx, SubsystemInfo{Name: app.Name()})
+	teardownCtx := ctx
+	if s.teardownDeadline > 0 {
+		var cancel context.CancelFunc
+		teardownCtx, cancel = context.WithTimeout(teardownCtx, s.teardownDeadline)
+		defer cancel()
+	}
+
+	// Teardown the application subsystem, then the business and query subsystems in reverse initialization order
+	done := make(chan error, 1)
+	go func() {
+		errs := []error{app.Teardown(newSubsystemContext(teardownCtx, SubsystemInfo{Name: app.Name()}))}
+		for i := len(s.subsystemTeardowns) - 1; i >= 0; i-- {
+			st := s.subsystemTeardowns[i]
+			if err := st.teardown(newSubsystemContext(teardownCtx, SubsystemInfo{Name: st.subsystemName})); err != nil {
+				errs = append(errs, fmt.Errorf("failed to teardown subsystem %q: %w", st.subsystemName, err))
+			}
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	var teardownErr, deadlineErr error
+	select {
+	case teardownErr = <-done:
+	case <-teardownCtx.Done():
+		deadlineErr = misas.ErrTimeout.WithMessage(fmt.Sprintf("system teardown did not complete within %s, forcing exit", s.teardownDeadline))
+		teardownErr = deadlineErr
+	}
 
-	// Teardown the application subsystem
-	teardownErr := app.Teardown(teardownCtx)
 	s.pm.DispatchHook(ctx, SystemTeardownEndedHook{
 		StartedAt: teardownStartedAt,
 		EndedAt:   s.clock.Now(),
 		Error:     teardownErr,
 	})
+
+	return deadlineErr
+}
+
+// closePlugins waits for asynchronous plugins to receive pending hooks, within the teardown deadline if any.
+func (s *System) closePlugins() {
+	ctx := newSystemContext(*s)
+	if s.teardownDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.teardownDeadline)
+		defer cancel()
+	}
+
+	if err := s.pm.close(ctx); err != nil {
+		Log(ctx).Warn("some hooks could not be delivered to asynchronous plugins before exiting", slog.Any(logKeyError, err))
+	}
 }
 
 func (s *System) PluginManager() SystemPluginManager { return s.pm }
@@ -1,6 +1,8 @@
 package mx
 
 import (
+	"errors"
+	"fmt"
 	"github.com/morebec/misas/mtime"
 	"github.com/samber/lo"
 	"log/slog"
@@ -32,6 +34,13 @@ type SystemConf struct {
 	eventBuses         map[EventBusName]*DynamicBindingEventBus
 	querySubsystems    map[string]QuerySubsystemConf
 	queryBus           *DynamicBindingQueryBus
+	registries         MessageRegistries
+	teardownDeadline   time.Duration
+	systemEvents       bool
+
+	strictTopology      *bool
+	topologyDumpDir     string
+	duplicateSubsystems []string // names of subsystems registered more than once
 }
 
 func NewSystem(name string) *SystemConf {
@@ -50,6 +59,7 @@ func NewSystem(name string) *SystemConf {
 		eventBuses:         make(map[EventBusName]*DynamicBindingEventBus, 10),
 		querySubsystems:    make(map[string]QuerySubsystemConf, 10),
 		queryBus:           NewDynamicBindingQueryBus(),
+		registries:         DefaultMessageRegistries(),
 	}
 }
 
@@ -58,7 +68,20 @@ func (sc *SystemConf) RunE(app ApplicationSubsystem) error {
 		sc.loggerHandler = sc.newDefaultLoggerHandler()
 	}
 
+	if err := sc.registerMessages(); err != nil {
+		return fmt.Errorf("system failed: %w", err)
+	}
+
+	issues := sc.ValidateTopology()
+	if failing := lo.Reject(issues, func(i TopologyIssue, _ int) bool { return i.Kind.isWarning() }); len(failing) != 0 && sc.isTopologyValidationStrict() {
+		return fmt.Errorf("system failed: %w", topologyError(failing))
+	}
+
 	sys := newSystem(sc)
+	sys.topologyIssues = issues
+	if sc.debug && sc.topologyDumpDir != "" {
+		sys.topologyDump = &topologyDump{dir: sc.topologyDumpDir, topology: sc.Topology(app)}
+	}
 
 	return sys.run(app)
 }
@@ -87,6 +110,45 @@ func (sc *SystemConf) WithDebug(debug bool) *SystemConf {
 	return sc
 }
 
+// WithTeardownDeadline bounds the teardown of the whole system. When the application subsystem does not complete
+// its teardown before the deadline, the system stops waiting for it and RunE returns a misas.ErrTimeout.
+// A zero deadline waits indefinitely.
+func (sc *SystemConf) WithTeardownDeadline(d time.Duration) *SystemConf {
+	if d < 0 {
+		panic("system: teardown deadline must not be negative")
+	}
+	sc.teardownDeadline = d
+
+	return sc
+}
+
+// WithSystemEvents enables the republishing of lifecycle hooks as SystemEvent on the SystemEventBusName event bus,
+// allowing business and query subsystems to react to them. Their types are registered in the message registries of
+// the system only.
+func (sc *SystemConf) WithSystemEvents() *SystemConf {
+	sc.systemEvents = true
+	sc.EventBus(SystemEventBusName)
+
+	return sc
+}
+
+// WithStrictTopologyValidation sets whether issues found by ValidateTopology fail the startup of the system instead
+// of being logged as warnings. Validation is strict by default in production. Unhandled messages are always logged as
+// warnings, as a system may register messages it only sends to other systems.
+func (sc *SystemConf) WithStrictTopologyValidation(strict bool) *SystemConf {
+	sc.strictTopology = &strict
+
+	return sc
+}
+
+func (sc *SystemConf) isTopologyValidationStrict() bool {
+	if sc.strictTopology != nil {
+		return *sc.strictTopology
+	}
+
+	return sc.environment == EnvironmentProduction
+}
+
 func (sc *SystemConf) WithClock(c mtime.Clock) *SystemConf {
 	sc.clock.Bind(c)
 
@@ -98,6 +160,9 @@ func (sc *SystemConf) Clock() mtime.Clock {
 }
 
 func (sc *SystemConf) WithBusinessSubsystem(bc *BusinessSubsystemConf) *SystemConf {
+	if _, exists := sc.businessSubsystems[bc.name]; exists {
+		sc.duplicateSubsystems = append(sc.duplicateSubsystems, bc.name)
+	}
 	sc.businessSubsystems[bc.name] = *bc
 
 	return sc
@@ -120,11 +185,54 @@ func (sc *SystemConf) EventBus(s EventBusName) misas.EventBus {
 }
 
 func (sc *SystemConf) WithQuerySubsystem(qc *QuerySubsystemConf) *SystemConf {
+	if _, exists := sc.querySubsystems[qc.name]; exists {
+		sc.duplicateSubsystems = append(sc.duplicateSubsystems, qc.name)
+	}
 	sc.querySubsystems[qc.name] = *qc
 
 	return sc
 }
 
+// WithMessageRegistries makes the system register its messages with the given registries instead of the
+// DefaultMessageRegistries, such as the isolated ones returned by NewMessageRegistries.
+func (sc *SystemConf) WithMessageRegistries(r MessageRegistries) *SystemConf {
+	if r.Commands == nil || r.Events == nil || r.Queries == nil {
+		panic("system: message registries cannot be nil")
+	}
+	sc.registries = r
+
+	return sc
+}
+
+// MessageRegistries returns the registries of the messages of the system, registering the messages of its subsystems
+// and its system events with them. It panics if a type name is registered for different Go types, see
+// MessageRegistry.TryRegister.
+func (sc *SystemConf) MessageRegistries() MessageRegistries {
+	if err := sc.registerMessages(); err != nil {
+		panic(err)
+	}
+
+	return sc.registries
+}
+
+// registerMessages registers the messages of the subsystems and the system events with the message registries of the
+// system. Messages are registered once the registries are used rather than as subsystems are added, so that they are
+// registered with the registries in effect when the system is built.
+func (sc *SystemConf) registerMessages() error {
+	var errs []error
+	for _, bs := range sc.businessSubsystems {
+		errs = append(errs, bs.registerMessages(sc.registries))
+	}
+	for _, qs := range sc.querySubsystems {
+		errs = append(errs, qs.registerMessages(sc.registries))
+	}
+	if sc.systemEvents {
+		errs = append(errs, registerSystemEvents(sc.registries.Events))
+	}
+
+	return errors.Join(errs...)
+}
+
 func (sc *SystemConf) QueryBus() misas.QueryBus { return sc.queryBus }
 
 func (sc *SystemConf) WithPlugin(p SystemPlugin) *SystemConf {
@@ -0,0 +1,164 @@
+package mx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mtime"
+)
+
+// SystemEventBusName is the event bus reserved for the SystemEvent republished by the system events plugin.
+// Business and query subsystems subscribe to it like any other event bus to react to lifecycle changes.
+const SystemEventBusName EventBusName = "mx.system"
+
+// systemEventHookNames lists the hooks republished as SystemEvent. Message hooks are excluded, the republishing
+// being itself message traffic.
+var systemEventHookNames = []SystemPluginHookName{
+	SystemInitializationStartedPluginHookName,
+	SystemInitializationEndedPluginHookName,
+	SystemExecutionStartedPluginHookName,
+	SystemExecutionEndedTypeName,
+	SystemTeardownStartedPluginHookName,
+	SystemTeardownEndedPluginHookName,
+
+	ApplicationSubsystemInitializationStartedPluginHookName,
+	ApplicationSubsystemInitializationEndedPluginHookName,
+	ApplicationSubsystemRunStartedPluginHookName,
+	ApplicationSubsystemRunEndedPluginHookName,
+	ApplicationSubsystemTeardownStartedPluginHookName,
+	ApplicationSubsystemTeardownEndedPluginHookName,
+
+	BusinessSubsystemInitializationStartedPluginHookName,
+	BusinessSubsystemInitializationEndedPluginHookName,
+	QuerySubsystemInitializationStartedPluginHookName,
+	QuerySubsystemInitializationEndedPluginHookName,
+
+	PanicRecoveredPluginHookName,
+
+	ApplicationSubsystemWillRestartPluginHookName,
+	ApplicationSubsystemRestartedPluginHookName,
+	ApplicationSubsystemMaxRestartReachedPluginHookName,
+	ApplicationSubsystemStopRequestedPluginHookName,
+	ApplicationSubsystemStartRequestedPluginHookName,
+	ApplicationSubsystemRestartRequestedPluginHookName,
+	ApplicationSubsystemAddedPluginHookName,
+	ApplicationSubsystemRemovedPluginHookName,
+	ApplicationSubsystemTeardownTimedOutPluginHookName,
+}
+
+func registerSystemEvents(r *MessageRegistry[misas.EventTypeName, misas.Event]) error {
+	var errs []error
+	for _, hookName := range systemEventHookNames {
+		errs = append(errs, r.TryRegister(SystemEventTypeName(hookName), SystemEvent{}))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SystemEventTypeName returns the stable type name of the SystemEvent republishing a hook, e.g.
+// "mx.application_subsystem.restarted" for ApplicationSubsystemRestartedHook.
+func SystemEventTypeName(hookName SystemPluginHookName) misas.EventTypeName {
+	return misas.EventTypeName("mx." + hookName)
+}
+
+// SystemEvent is a SystemPluginHook republished as an event on the SystemEventBusName event bus.
+//
+// Payload holds the exported fields of the hook keyed by their lower camel case name: errors are converted
+// to their message, durations to nanoseconds, and references to the System are omitted.
+type SystemEvent struct {
+	HookName   SystemPluginHookName `json:"hookName"`
+	SystemName string               `json:"systemName"`
+	OccurredAt time.Time            `json:"occurredAt"`
+	Payload    map[string]any       `json:"payload"`
+}
+
+func (e SystemEvent) TypeName() misas.EventTypeName { return SystemEventTypeName(e.HookName) }
+
+type systemEventsContextKey struct{}
+
+// systemEventsPlugin is the built-in plugin republishing lifecycle hooks as SystemEvent.
+type systemEventsPlugin struct {
+	eventBus misas.EventBus
+	clock    mtime.Clock
+}
+
+func (p systemEventsPlugin) OnHook(ctx context.Context, hook SystemPluginHook) error {
+	// hooks dispatched while handling a system event, such as recovered panics, are not republished to avoid loops
+	if ctx.Value(systemEventsContextKey{}) != nil {
+		return nil
+	}
+
+	event := SystemEvent{
+		HookName:   hook.HookName(),
+		SystemName: Ctx(ctx).SystemInfo().Name,
+		OccurredAt: p.clock.Now(),
+		Payload:    systemEventPayload(hook),
+	}
+
+	ctx = context.WithValue(context.WithoutCancel(ctx), systemEventsContextKey{}, true)
+	if err := p.eventBus.Publish(ctx, event); err != nil {
+		Log(ctx).Warn(fmt.Sprintf("failed to handle system event %q", event.TypeName()), slog.Any(logKeyError, err))
+	}
+
+	return nil
+}
+
+func (p systemEventsPlugin) Name() string { return "system.events" }
+
+func (systemEventsPlugin) builtIn() {}
+
+func (p systemEventsPlugin) Metadata() SystemPluginMetadata {
+	return SystemPluginMetadata{Subscriptions: systemEventHookNames}
+}
+
+// systemEventPayload converts the exported fields of a hook to JSON friendly values.
+func systemEventPayload(hook SystemPluginHook) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(hook))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	payload := make(map[string]any, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		var value any
+		switch fv := v.Field(i).Interface().(type) {
+		case *System:
+			continue
+		case error:
+			value = fv.Error()
+		case time.Duration:
+			value = int64(fv)
+		case []byte:
+			value = string(fv)
+		default:
+			if _, err := json.Marshal(fv); err != nil {
+				value = fmt.Sprint(fv)
+			} else {
+				value = fv
+			}
+		}
+		payload[lowerFirst(field.Name)] = value
+	}
+
+	return payload
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,72 @@
+package mx_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemEvents(t *testing.T) {
+	t.Run("GIVEN system events enabled WHEN running THEN subsystems should receive lifecycle events", func(t *testing.T) {
+		var events []mx.SystemEvent
+		system := mx.NewSystem("test").WithMessageRegistries(mx.NewMessageRegistries()).WithSystemEvents()
+		system.WithQuerySubsystem(
+			mx.NewQuerySubsystem("monitoring").
+				WithEventHandlers(mx.SystemEventBusName, misas.EventHandlerFunc(func(_ context.Context, e misas.Event) error {
+					events = append(events, e.(mx.SystemEvent))
+					return nil
+				})),
+		)
+
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.NoError(t, err)
+
+		typeNames := make([]misas.EventTypeName, 0, len(events))
+		for _, e := range events {
+			typeNames = append(typeNames, e.TypeName())
+		}
+		require.Contains(t, typeNames, misas.EventTypeName("mx.system.run.started"))
+		require.Contains(t, typeNames, misas.EventTypeName("mx.application_subsystem.run.ended"))
+		require.NotContains(t, typeNames, misas.EventTypeName("mx.event.published"))
+
+		var runEnded mx.SystemEvent
+		for _, e := range events {
+			if e.HookName == mx.ApplicationSubsystemRunEndedPluginHookName {
+				runEnded = e
+			}
+		}
+		require.Equal(t, "test", runEnded.SystemName)
+		require.Equal(t, "app", runEnded.Payload["applicationSubsystemName"])
+		require.Nil(t, runEnded.Payload["error"])
+	})
+
+	t.Run("GIVEN system event as JSON WHEN unmarshalling with event registry THEN should resolve its type", func(t *testing.T) {
+		registries := mx.NewSystem("test").
+			WithMessageRegistries(mx.NewMessageRegistries()).
+			WithSystemEvents().
+			MessageRegistries()
+		js, err := json.Marshal(mx.SystemEvent{
+			HookName: mx.ApplicationSubsystemRestartedPluginHookName,
+			Payload:  map[string]any{"applicationName": "worker"},
+		})
+		require.NoError(t, err)
+
+		event, err := registries.Events.UnmarshalFromJSON("mx.application_subsystem.restarted", js)
+		require.NoError(t, err)
+		require.Equal(t, misas.EventTypeName("mx.application_subsystem.restarted"), event.TypeName())
+		require.Equal(t, "worker", event.(mx.SystemEvent).Payload["applicationName"])
+	})
+
+	t.Run("GIVEN system events not enabled WHEN configuring a system THEN should not register them", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		mx.NewSystem("test").WithMessageRegistries(registries)
+
+		require.Empty(t, registries.Events.TypeNames())
+		_, found := mx.EventRegistry.Lookup(mx.SystemEventTypeName(mx.SystemInitializationStartedPluginHookName))
+		require.False(t, found)
+	})
+}
@@ -81,9 +81,16 @@ func (hl loggingPlugin) OnHook(ctx context.Context, hook SystemPluginHook) error
 			return nil
 		}
 		logger.Info(fmt.Sprintf("query subsystem %q initialized successfully", h.QuerySubsystemName), slog.Duration("duration", h.EndedAt.Sub(h.StartedAt)))
+	case PanicRecoveredHook:
+		logger.Error(
+			fmt.Sprintf("recovered from panic in subsystem %q", h.SubsystemName),
+			slog.String("message", h.MessageTypeName),
+			slog.Any(logKeyError, h.Error),
+			slog.String("stack", string(h.Stack)),
+		)
 
 	case PluginAddedHook:
-		// Display banner when logging plugin is added (it's always first)
+		// Display banner when logging plugin is added, built-in plugins being added before any other plugin
 		if h.PluginName == "system.logging" {
 			hl.logSystemInfoBanner(ctx)
 		}
@@ -96,6 +103,8 @@ func (hl loggingPlugin) OnHook(ctx context.Context, hook SystemPluginHook) error
 
 func (loggingPlugin) Name() string { return "system.logging" }
 
+func (loggingPlugin) builtIn() {}
+
 func (hl loggingPlugin) logSystemInfoBanner(ctx context.Context) {
 	logger := Log(ctx)
 	systemInfo := Ctx(ctx).SystemInfo()
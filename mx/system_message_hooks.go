@@ -0,0 +1,228 @@
+package mx
+
+import (
+	"context"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mtime"
+)
+
+const (
+	CommandHandlingStartedPluginHookName SystemPluginHookName = "command.handling.started"
+	CommandHandlingEndedPluginHookName   SystemPluginHookName = "command.handling.ended"
+	QueryHandlingStartedPluginHookName   SystemPluginHookName = "query.handling.started"
+	QueryHandlingEndedPluginHookName     SystemPluginHookName = "query.handling.ended"
+	EventPublishedPluginHookName         SystemPluginHookName = "event.published"
+	EventHandledPluginHookName           SystemPluginHookName = "event.handled"
+)
+
+// CommandHandlingStartedHook is dispatched when a business subsystem starts handling a command.
+type CommandHandlingStartedHook struct {
+	CommandTypeName     misas.CommandTypeName
+	SubsystemName       string // Subsystem handling the command
+	OriginSubsystemName string // Subsystem that sent the command, empty if unknown
+	StartedAt           time.Time
+}
+
+func (e CommandHandlingStartedHook) HookName() SystemPluginHookName {
+	return CommandHandlingStartedPluginHookName
+}
+
+// CommandHandlingEndedHook is dispatched when a business subsystem is done handling a command.
+type CommandHandlingEndedHook struct {
+	CommandTypeName     misas.CommandTypeName
+	SubsystemName       string
+	OriginSubsystemName string
+	StartedAt           time.Time
+	EndedAt             time.Time
+	Duration            time.Duration
+	Error               error
+}
+
+func (e CommandHandlingEndedHook) HookName() SystemPluginHookName {
+	return CommandHandlingEndedPluginHookName
+}
+
+// QueryHandlingStartedHook is dispatched when a query subsystem starts handling a query.
+type QueryHandlingStartedHook struct {
+	QueryTypeName       misas.QueryTypeName
+	SubsystemName       string // Subsystem handling the query
+	OriginSubsystemName string // Subsystem that sent the query, empty if unknown
+	StartedAt           time.Time
+}
+
+func (e QueryHandlingStartedHook) HookName() SystemPluginHookName {
+	return QueryHandlingStartedPluginHookName
+}
+
+// QueryHandlingEndedHook is dispatched when a query subsystem is done handling a query.
+type QueryHandlingEndedHook struct {
+	QueryTypeName       misas.QueryTypeName
+	SubsystemName       string
+	OriginSubsystemName string
+	StartedAt           time.Time
+	EndedAt             time.Time
+	Duration            time.Duration
+	Error               error
+}
+
+func (e QueryHandlingEndedHook) HookName() SystemPluginHookName {
+	return QueryHandlingEndedPluginHookName
+}
+
+// EventPublishedHook is dispatched once an event was published on one of the system's event buses.
+// Depending on the event bus implementation, the duration may include the handling of the event.
+type EventPublishedHook struct {
+	EventTypeName       misas.EventTypeName
+	EventBusName        EventBusName
+	SubsystemName       string // Subsystem that published the event, empty if unknown
+	OriginSubsystemName string // Subsystem that triggered the publishing subsystem, empty if unknown
+	StartedAt           time.Time
+	EndedAt             time.Time
+	Duration            time.Duration
+	Error               error
+}
+
+func (e EventPublishedHook) HookName() SystemPluginHookName {
+	return EventPublishedPluginHookName
+}
+
+// EventHandledHook is dispatched once an event handler of a subsystem handled an event.
+type EventHandledHook struct {
+	EventTypeName       misas.EventTypeName
+	EventBusName        EventBusName
+	SubsystemName       string // Subsystem handling the event
+	OriginSubsystemName string // Subsystem that published the event, empty if unknown
+	StartedAt           time.Time
+	EndedAt             time.Time
+	Duration            time.Duration
+	Error               error
+}
+
+func (e EventHandledHook) HookName() SystemPluginHookName {
+	return EventHandledPluginHookName
+}
+
+// withCommandHooks wraps a command handler to dispatch command handling hooks.
+func withCommandHooks(subsystemName string, pm SystemPluginManager, clock mtime.Clock, h misas.CommandHandler) misas.CommandHandler {
+	return misas.CommandHandlerFunc(func(ctx context.Context, cmd misas.Command) misas.CommandResult {
+		origin := Ctx(ctx).SubsystemInfo().Name
+		hookCtx := newSubsystemContext(ctx, SubsystemInfo{Name: subsystemName})
+
+		startedAt := clock.Now()
+		pm.DispatchHook(hookCtx, CommandHandlingStartedHook{
+			CommandTypeName:     cmd.TypeName(),
+			SubsystemName:       subsystemName,
+			OriginSubsystemName: origin,
+			StartedAt:           startedAt,
+		})
+
+		result := h.Handle(ctx, cmd)
+
+		endedAt := clock.Now()
+		pm.DispatchHook(hookCtx, CommandHandlingEndedHook{
+			CommandTypeName:     cmd.TypeName(),
+			SubsystemName:       subsystemName,
+			OriginSubsystemName: origin,
+			StartedAt:           startedAt,
+			EndedAt:             endedAt,
+			Duration:            endedAt.Sub(startedAt),
+			Error:               result.Error,
+		})
+
+		return result
+	})
+}
+
+// withQueryHooks wraps a query handler to dispatch query handling hooks.
+func withQueryHooks(subsystemName string, pm SystemPluginManager, clock mtime.Clock, h misas.QueryHandler) misas.QueryHandler {
+	return misas.QueryHandlerFunc(func(ctx context.Context, q misas.Query) misas.QueryResult {
+		origin := Ctx(ctx).SubsystemInfo().Name
+		hookCtx := newSubsystemContext(ctx, SubsystemInfo{Name: subsystemName})
+
+		startedAt := clock.Now()
+		pm.DispatchHook(hookCtx, QueryHandlingStartedHook{
+			QueryTypeName:       q.TypeName(),
+			SubsystemName:       subsystemName,
+			OriginSubsystemName: origin,
+			StartedAt:           startedAt,
+		})
+
+		result := h.Handle(ctx, q)
+
+		endedAt := clock.Now()
+		pm.DispatchHook(hookCtx, QueryHandlingEndedHook{
+			QueryTypeName:       q.TypeName(),
+			SubsystemName:       subsystemName,
+			OriginSubsystemName: origin,
+			StartedAt:           startedAt,
+			EndedAt:             endedAt,
+			Duration:            endedAt.Sub(startedAt),
+			Error:               result.Error,
+		})
+
+		return result
+	})
+}
+
+// withEventHooks wraps an event handler to dispatch an EventHandledHook.
+func withEventHooks(subsystemName string, eventBusName EventBusName, pm SystemPluginManager, clock mtime.Clock, h misas.EventHandler) misas.EventHandler {
+	return misas.EventHandlerFunc(func(ctx context.Context, e misas.Event) error {
+		origin := Ctx(ctx).SubsystemInfo().Name
+
+		startedAt := clock.Now()
+		err := h.Handle(ctx, e)
+		endedAt := clock.Now()
+
+		pm.DispatchHook(newSubsystemContext(ctx, SubsystemInfo{Name: subsystemName}), EventHandledHook{
+			EventTypeName:       e.TypeName(),
+			EventBusName:        eventBusName,
+			SubsystemName:       subsystemName,
+			OriginSubsystemName: origin,
+			StartedAt:           startedAt,
+			EndedAt:             endedAt,
+			Duration:            endedAt.Sub(startedAt),
+			Error:               err,
+		})
+
+		return err
+	})
+}
+
+// hookedEventBus is an event bus dispatching an EventPublishedHook for every published event.
+type hookedEventBus struct {
+	misas.EventBus
+	name  EventBusName
+	pm    SystemPluginManager
+	clock mtime.Clock
+}
+
+// newHookedEventBus wraps an event bus, replacing any previous hooked wrapper so that a configuration
+// run more than once does not dispatch the hooks of previous systems.
+func newHookedEventBus(name EventBusName, eb misas.EventBus, pm SystemPluginManager, clock mtime.Clock) *hookedEventBus {
+	if hooked, ok := eb.(*hookedEventBus); ok {
+		eb = hooked.EventBus
+	}
+
+	return &hookedEventBus{EventBus: eb, name: name, pm: pm, clock: clock}
+}
+
+func (b *hookedEventBus) Publish(ctx context.Context, event misas.Event) error {
+	startedAt := b.clock.Now()
+	err := b.EventBus.Publish(ctx, event)
+	endedAt := b.clock.Now()
+
+	b.pm.DispatchHook(ctx, EventPublishedHook{
+		EventTypeName:       event.TypeName(),
+		EventBusName:        b.name,
+		SubsystemName:       Ctx(ctx).SubsystemInfo().Name,
+		OriginSubsystemName: Ctx(ctx).SubsystemOrigin().Name,
+		StartedAt:           startedAt,
+		EndedAt:             endedAt,
+		Duration:            endedAt.Sub(startedAt),
+		Error:               err,
+	})
+
+	return err
+}
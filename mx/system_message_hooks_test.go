@@ -0,0 +1,85 @@
+package mx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/morebec/misas/mxtest"
+	"github.com/stretchr/testify/require"
+)
+
+type stockAdjustedEvent struct{}
+
+func (stockAdjustedEvent) TypeName() misas.EventTypeName { return "inventory.stock_adjusted" }
+
+type stockLevelQuery struct{}
+
+func (stockLevelQuery) TypeName() misas.QueryTypeName { return "inventory.stock_level" }
+
+func TestSystem_MessageHooks(t *testing.T) {
+	t.Run("GIVEN subsystems exchanging messages WHEN handling them THEN plugins should receive message hooks", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		system := mx.NewSystem("test").WithPlugin(recorder)
+		events := system.EventBus("inventory.events")
+
+		system.WithBusinessSubsystem(
+			mx.NewBusinessSubsystem("inventory").
+				WithCommandHandler(mxtest.MockCommand{}, misas.CommandHandlerFunc(func(ctx context.Context, _ misas.Command) misas.CommandResult {
+					_ = events.Publish(ctx, stockAdjustedEvent{}) // failing event handlers are not a failure of the command
+					return misas.CommandResult{}
+				})),
+		)
+		system.WithQuerySubsystem(
+			mx.NewQuerySubsystem("reporting").
+				WithEventHandlers("inventory.events", misas.EventHandlerFunc(func(context.Context, misas.Event) error {
+					return misas.ErrConflict
+				})).
+				WithQueryHandler(stockLevelQuery{}, misas.QueryHandlerFunc(func(context.Context, misas.Query) misas.QueryResult {
+					return misas.QueryResult{}
+				})),
+		)
+
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			system.CommandBus().HandleCommand(ctx, mxtest.MockCommand{})
+			system.QueryBus().HandleQuery(ctx, stockLevelQuery{})
+			return nil
+		}})
+		require.NoError(t, err)
+
+		var names []mx.SystemPluginHookName
+		for _, h := range recorder.Hooks() {
+			switch h := h.(type) {
+			case mx.CommandHandlingEndedHook:
+				require.Equal(t, misas.CommandTypeName("mxtest.MockCommand"), h.CommandTypeName)
+				require.Equal(t, "inventory", h.SubsystemName)
+				require.Equal(t, "app", h.OriginSubsystemName)
+				require.NoError(t, h.Error)
+			case mx.EventPublishedHook:
+				require.Equal(t, mx.EventBusName("inventory.events"), h.EventBusName)
+				require.Equal(t, "inventory", h.SubsystemName)
+				require.Equal(t, "app", h.OriginSubsystemName)
+				require.ErrorIs(t, h.Error, misas.ErrConflict)
+			case mx.EventHandledHook:
+				require.Equal(t, misas.EventTypeName("inventory.stock_adjusted"), h.EventTypeName)
+				require.Equal(t, "reporting", h.SubsystemName)
+				require.Equal(t, "inventory", h.OriginSubsystemName)
+				require.ErrorIs(t, h.Error, misas.ErrConflict)
+			case mx.QueryHandlingEndedHook:
+				require.Equal(t, "reporting", h.SubsystemName)
+				require.Equal(t, "app", h.OriginSubsystemName)
+			default:
+				continue
+			}
+			names = append(names, h.HookName())
+		}
+
+		require.Equal(t, []mx.SystemPluginHookName{
+			mx.EventHandledPluginHookName,
+			mx.EventPublishedPluginHookName,
+			mx.CommandHandlingEndedPluginHookName,
+			mx.QueryHandlingEndedPluginHookName,
+		}, names)
+	})
+}
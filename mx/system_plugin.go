@@ -2,7 +2,6 @@ package mx
 
 import (
 	"context"
-	"log/slog"
 	"time"
 )
 
@@ -29,6 +28,8 @@ const (
 	QuerySubsystemInitializationStartedPluginHookName SystemPluginHookName = "query_subsystem.initialization.started"
 	QuerySubsystemInitializationEndedPluginHookName   SystemPluginHookName = "query_subsystem.initialization.ended"
 
+	PanicRecoveredPluginHookName SystemPluginHookName = "panic.recovered"
+
 	PluginAddedHookName SystemPluginHookName = "plugin.added"
 )
 
@@ -191,6 +192,19 @@ func (e QuerySubsystemInitializationEndedHook) HookName() SystemPluginHookName {
 	return QuerySubsystemInitializationEndedPluginHookName
 }
 
+// PanicRecoveredHook is dispatched when a panic is recovered from a supervised application subsystem
+// or from a command, query or event handler.
+type PanicRecoveredHook struct {
+	SubsystemName   string
+	MessageTypeName string // Type name of the message being handled, empty for application subsystems
+	Value           any
+	Stack           []byte
+	Error           error
+	RecoveredAt     time.Time
+}
+
+func (e PanicRecoveredHook) HookName() SystemPluginHookName { return PanicRecoveredPluginHookName }
+
 type PluginAddedHook struct {
 	PluginName string
 }
@@ -209,34 +223,8 @@ type SystemPlugin interface {
 type SystemPluginManager interface {
 	DispatchHook(context.Context, SystemPluginHook)
 	AddPlugin(context.Context, SystemPlugin)
-}
-
-type systemPluginManager struct {
-	plugins []SystemPlugin
-}
-
-func newPluginManager() *systemPluginManager {
-	return &systemPluginManager{}
-}
-
-func (pm *systemPluginManager) DispatchHook(ctx context.Context, hook SystemPluginHook) {
-	// used an indexed for loop to allow plugins to add more plugins during execution
-	for i := 0; i < len(pm.plugins); i++ {
-		if err := pm.plugins[i].OnHook(ctx, hook); err != nil {
-			// Log the error but continue executing other plugins
-			Log(ctx).Error(
-				"SystemPlugin hook execution failed",
-				slog.String("plugin", pm.plugins[i].Name()),
-				slog.String("hook", string(hook.HookName())),
-				slog.Any(logKeyError, err),
-			)
-		}
-	}
-}
-
-func (pm *systemPluginManager) AddPlugin(ctx context.Context, plugin SystemPlugin) {
-	pm.plugins = append(pm.plugins, plugin)
-	pm.DispatchHook(ctx, PluginAddedHook{PluginName: plugin.Name()})
+	// Metrics returns the hook delivery metrics of every plugin, in the order they were added.
+	Metrics() []SystemPluginMetrics
 }
 
 // lateBindingSystemPluginManager is an implementation of SystemPluginManager that allows
@@ -259,3 +247,7 @@ func (h *lateBindingSystemPluginManager) AddPlugin(ctx context.Context, p System
 func (h *lateBindingSystemPluginManager) DispatchHook(ctx context.Context, hook SystemPluginHook) {
 	h.Get().DispatchHook(ctx, hook)
 }
+
+func (h *lateBindingSystemPluginManager) Metrics() []SystemPluginMetrics {
+	return h.Get().Metrics()
+}
@@ -0,0 +1,448 @@
+package mx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
+)
+
+const defaultAsyncPluginQueueSize = 1024
+
+// AsyncPluginOptions configures the asynchronous delivery of hooks to a plugin.
+type AsyncPluginOptions struct {
+	// QueueSize is the maximum number of hooks waiting to be delivered, defaults to defaultAsyncPluginQueueSize.
+	QueueSize int
+	// BlockWhenFull makes dispatchers wait for room in the queue instead of dropping hooks when it is full.
+	BlockWhenFull bool
+}
+
+// asyncSystemPlugin marks a plugin as receiving hooks asynchronously.
+type asyncSystemPlugin struct {
+	SystemPlugin
+	options AsyncPluginOptions
+}
+
+// NewAsyncPlugin wraps a plugin so that it receives hooks asynchronously, in dispatch order, from a bounded queue
+// consumed by a dedicated goroutine. A slow plugin can therefore not stall the system: when its queue is full, hooks
+// are dropped unless AsyncPluginOptions.BlockWhenFull is set. The context received by the plugin may be done by the
+// time the hook is delivered.
+func NewAsyncPlugin(p SystemPlugin, options AsyncPluginOptions) SystemPlugin {
+	if _, ok := p.(guardSystemPlugin); ok {
+		panic("async plugin: guard plugins cannot receive hooks asynchronously")
+	}
+	if options.QueueSize < 0 {
+		panic("async plugin: queue size must not be negative")
+	}
+	if options.QueueSize == 0 {
+		options.QueueSize = defaultAsyncPluginQueueSize
+	}
+
+	return asyncSystemPlugin{SystemPlugin: p, options: options}
+}
+
+// guardSystemPlugin marks a plugin as a guard.
+type guardSystemPlugin struct {
+	SystemPlugin
+}
+
+// NewGuardPlugin wraps a plugin so that it acts as a guard rather than an observer: an error it returns for a guarded
+// hook aborts the operation the hook announces instead of only being logged. Guarded hooks are
+// SystemInitializationStartedHook, BusinessSubsystemInitializationStartedHook and
+// QuerySubsystemInitializationStartedHook, a rejection making the initialization of the system fail with the error
+// returned by the guard.
+func NewGuardPlugin(p SystemPlugin) SystemPlugin {
+	if _, ok := p.(asyncSystemPlugin); ok {
+		panic("guard plugin: asynchronous plugins cannot be guards")
+	}
+
+	return guardSystemPlugin{SystemPlugin: p}
+}
+
+// SystemPluginMetadata describes which hooks a plugin receives and in which order relative to other plugins.
+type SystemPluginMetadata struct {
+	// Subscriptions lists the hooks delivered to the plugin, all hooks are delivered when empty.
+	Subscriptions []SystemPluginHookName
+	// Priority orders the delivery of hooks to plugins not depending on one another, higher priorities first.
+	// Built-in plugins such as system.logging always receive hooks before other plugins.
+	Priority int
+	// DependsOn lists the names of the plugins that must receive hooks before this plugin.
+	DependsOn []string
+}
+
+// SystemPluginWithMetadata is implemented by plugins declaring metadata. Plugins without metadata receive every hook,
+// with a priority of 0, in the order they were added.
+type SystemPluginWithMetadata interface {
+	SystemPlugin
+	Metadata() SystemPluginMetadata
+}
+
+// pluginMetadata returns the metadata of a plugin, looking through the async and guard wrappers.
+func pluginMetadata(p SystemPlugin) SystemPluginMetadata {
+	switch p := p.(type) {
+	case asyncSystemPlugin:
+		return pluginMetadata(p.SystemPlugin)
+	case guardSystemPlugin:
+		return pluginMetadata(p.SystemPlugin)
+	case SystemPluginWithMetadata:
+		return p.Metadata()
+	default:
+		return SystemPluginMetadata{}
+	}
+}
+
+// SystemPluginMetrics describes the delivery of hooks to a plugin.
+type SystemPluginMetrics struct {
+	PluginName    string
+	Async         bool
+	Delivered     uint64        // Hooks delivered to the plugin
+	Failed        uint64        // Hooks for which the plugin returned an error
+	Dropped       uint64        // Hooks dropped because the queue was full or the manager closed
+	QueueLength   int           // Hooks waiting to be delivered, always 0 for synchronous plugins
+	QueueCapacity int           // Always 0 for synchronous plugins
+	LastLag       time.Duration // Time between the dispatch and the delivery of the last hook
+	MaxLag        time.Duration
+}
+
+type queuedHook struct {
+	ctx          context.Context
+	hook         SystemPluginHook
+	dispatchedAt time.Time
+}
+
+// builtInSystemPlugin is implemented by the plugins provided by mx, which receive hooks before any other plugin.
+type builtInSystemPlugin interface {
+	SystemPlugin
+	builtIn()
+}
+
+// registeredPlugin holds the delivery state of a plugin added to the manager.
+type registeredPlugin struct {
+	plugin   SystemPlugin
+	async    *asyncSystemPlugin
+	guard    bool
+	builtIn  bool
+	metadata SystemPluginMetadata
+	seq      int // insertion order
+
+	queue   chan queuedHook
+	stopped chan struct{} // closed by the worker once its queue is drained after the manager closed
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+	lastLag   atomic.Int64
+	maxLag    atomic.Int64
+}
+
+// deliver delivers a hook to the plugin. Errors of observers are logged, while errors of guards are returned
+// for guarded hooks.
+func (r *registeredPlugin) deliver(qh queuedHook, guarded bool) error {
+	lag := time.Since(qh.dispatchedAt)
+	r.lastLag.Store(int64(lag))
+	for {
+		maxLag := r.maxLag.Load()
+		if int64(lag) <= maxLag || r.maxLag.CompareAndSwap(maxLag, int64(lag)) {
+			break
+		}
+	}
+
+	r.delivered.Add(1)
+	err := r.plugin.OnHook(qh.ctx, qh.hook)
+	if err == nil {
+		return nil
+	}
+
+	r.failed.Add(1)
+	if guarded && r.guard {
+		return err
+	}
+
+	// Log the error but continue executing other plugins
+	Log(qh.ctx).Error(
+		"SystemPlugin hook execution failed",
+		slog.String("plugin", r.plugin.Name()),
+		slog.String("hook", string(qh.hook.HookName())),
+		slog.Any(logKeyError, err),
+	)
+
+	return nil
+}
+
+// subscribed reports whether the plugin receives the given hook.
+func (r *registeredPlugin) subscribed(hook SystemPluginHook) bool {
+	return len(r.metadata.Subscriptions) == 0 || slices.Contains(r.metadata.Subscriptions, hook.HookName())
+}
+
+func (r *registeredPlugin) metrics() SystemPluginMetrics {
+	m := SystemPluginMetrics{
+		PluginName: r.plugin.Name(),
+		Async:      r.async != nil,
+		Delivered:  r.delivered.Load(),
+		Failed:     r.failed.Load(),
+		Dropped:    r.dropped.Load(),
+		LastLag:    time.Duration(r.lastLag.Load()),
+		MaxLag:     time.Duration(r.maxLag.Load()),
+	}
+	if r.async != nil {
+		m.QueueLength = len(r.queue)
+		m.QueueCapacity = cap(r.queue)
+	}
+
+	return m
+}
+
+// systemPluginManager is the concurrency-safe SystemPluginManager of a System. Hooks may be dispatched
+// from any goroutine, and plugins may add plugins while handling a hook.
+type systemPluginManager struct {
+	mu      sync.RWMutex
+	plugins []*registeredPlugin
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func newPluginManager() *systemPluginManager {
+	return &systemPluginManager{closing: make(chan struct{})}
+}
+
+func (pm *systemPluginManager) DispatchHook(ctx context.Context, hook SystemPluginHook) {
+	_ = pm.dispatch(ctx, hook, false)
+}
+
+// dispatchGuardedHook dispatches a hook announcing an operation that guard plugins can abort. Every plugin receives
+// the hook, and the errors returned by guards are joined.
+func (pm *systemPluginManager) dispatchGuardedHook(ctx context.Context, hook SystemPluginHook) error {
+	return pm.dispatch(ctx, hook, true)
+}
+
+func (pm *systemPluginManager) dispatch(ctx context.Context, hook SystemPluginHook, guarded bool) error {
+	dispatchedAt := time.Now()
+
+	// plugins added while dispatching receive subsequent hooks
+	pm.mu.RLock()
+	plugins := pm.plugins
+	pm.mu.RUnlock()
+
+	var errs []error
+	for _, p := range plugins {
+		if !p.subscribed(hook) {
+			continue
+		}
+
+		qh := queuedHook{ctx: ctx, hook: hook, dispatchedAt: dispatchedAt}
+		if p.async == nil {
+			if err := p.deliver(qh, guarded); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		pm.enqueue(p, qh)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (pm *systemPluginManager) enqueue(p *registeredPlugin, qh queuedHook) {
+	select {
+	case <-pm.closing:
+		p.dropped.Add(1)
+		return
+	default:
+	}
+
+	if p.async.options.BlockWhenFull {
+		select {
+		case p.queue <- qh:
+		case <-pm.closing:
+			p.dropped.Add(1)
+		}
+		return
+	}
+
+	select {
+	case p.queue <- qh:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// AddPlugin adds a plugin, ordering it according to its metadata. Plugins added at load time are validated by
+// the System beforehand, for plugins added afterward, missing dependencies are ignored and a plugin that would
+// introduce a dependency cycle is delivered hooks last.
+func (pm *systemPluginManager) AddPlugin(ctx context.Context, plugin SystemPlugin) {
+	p := newRegisteredPlugin(plugin)
+	if p.async != nil {
+		p.queue = make(chan queuedHook, p.async.options.QueueSize)
+		p.stopped = make(chan struct{})
+		go pm.consume(p)
+	}
+
+	pm.mu.Lock()
+	p.seq = len(pm.plugins)
+	plugins := append(slices.Clip(pm.plugins), p)
+	ordered, err := orderPlugins(plugins, false)
+	pm.plugins = lo.Ternary(err == nil, ordered, plugins)
+	pm.mu.Unlock()
+
+	if err != nil {
+		Log(ctx).Warn(fmt.Sprintf("plugin %q could not be ordered according to its dependencies", plugin.Name()), slog.Any(logKeyError, err))
+	}
+
+	pm.DispatchHook(ctx, PluginAddedHook{PluginName: plugin.Name()})
+}
+
+func newRegisteredPlugin(plugin SystemPlugin) *registeredPlugin {
+	p := &registeredPlugin{plugin: plugin, metadata: pluginMetadata(plugin)}
+	if _, ok := plugin.(guardSystemPlugin); ok {
+		p.guard = true
+	}
+	if _, ok := plugin.(builtInSystemPlugin); ok {
+		p.builtIn = true
+	}
+	if async, ok := plugin.(asyncSystemPlugin); ok {
+		p.async = &async
+	}
+
+	return p
+}
+
+// resolvePlugins orders plugins according to their metadata, failing on duplicate names as well as missing or cyclic
+// dependencies.
+func resolvePlugins(plugins []SystemPlugin) ([]SystemPlugin, error) {
+	registered := make([]*registeredPlugin, 0, len(plugins))
+	for i, plugin := range plugins {
+		p := newRegisteredPlugin(plugin)
+		p.seq = i
+		registered = append(registered, p)
+	}
+
+	ordered, err := orderPlugins(registered, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(ordered, func(p *registeredPlugin, _ int) SystemPlugin { return p.plugin }), nil
+}
+
+// orderPlugins orders plugins so that every plugin comes after its dependencies, built-in plugins first in insertion
+// order, then other plugins by descending priority and insertion order. In strict mode, duplicate names and missing
+// dependencies are errors, otherwise missing dependencies are ignored.
+func orderPlugins(plugins []*registeredPlugin, strict bool) ([]*registeredPlugin, error) {
+	names := make(map[string]bool, len(plugins))
+	for _, p := range plugins {
+		if strict && names[p.plugin.Name()] {
+			return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("plugin %q is registered more than once", p.plugin.Name()))
+		}
+		names[p.plugin.Name()] = true
+	}
+
+	for _, p := range plugins {
+		for _, dependency := range p.metadata.DependsOn {
+			if strict && !names[dependency] {
+				return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("plugin %q depends on unknown plugin %q", p.plugin.Name(), dependency))
+			}
+		}
+	}
+
+	ordered := make([]*registeredPlugin, 0, len(plugins))
+	placed := make(map[string]bool, len(plugins))
+	remaining := slices.Clone(plugins)
+	for len(remaining) > 0 {
+		next := -1
+		for i, p := range remaining {
+			ready := lo.EveryBy(p.metadata.DependsOn, func(dependency string) bool { return placed[dependency] || !names[dependency] })
+			if !ready {
+				continue
+			}
+			if next == -1 || precedes(p, remaining[next]) {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			cyclic := lo.Map(remaining, func(p *registeredPlugin, _ int) string { return p.plugin.Name() })
+			return nil, misas.ErrInvalid.WithMessage(fmt.Sprintf("cyclic dependency between plugins: %s", strings.Join(cyclic, ", ")))
+		}
+
+		ordered = append(ordered, remaining[next])
+		placed[remaining[next].plugin.Name()] = true
+		remaining = slices.Delete(remaining, next, next+1)
+	}
+
+	return ordered, nil
+}
+
+// consume delivers the queued hooks of an asynchronous plugin until the manager is closed and its queue drained.
+func (pm *systemPluginManager) consume(p *registeredPlugin) {
+	defer close(p.stopped)
+	for {
+		select {
+		case qh := <-p.queue:
+			_ = p.deliver(qh, false)
+		case <-pm.closing:
+			for {
+				select {
+				case qh := <-p.queue:
+					_ = p.deliver(qh, false)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (pm *systemPluginManager) Metrics() []SystemPluginMetrics {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	metrics := make([]SystemPluginMetrics, 0, len(pm.plugins))
+	for _, p := range pm.plugins {
+		metrics = append(metrics, p.metrics())
+	}
+
+	return metrics
+}
+
+// close stops accepting hooks for asynchronous plugins and waits for their queues to be drained or for ctx to be done.
+func (pm *systemPluginManager) close(ctx context.Context) error {
+	pm.closeOnce.Do(func() { close(pm.closing) })
+
+	pm.mu.RLock()
+	plugins := append([]*registeredPlugin(nil), pm.plugins...)
+	pm.mu.RUnlock()
+
+	for _, p := range plugins {
+		if p.async == nil {
+			continue
+		}
+		select {
+		case <-p.stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// precedes reports whether a plugin receives hooks before another one, both having their dependencies placed.
+func precedes(p *registeredPlugin, other *registeredPlugin) bool {
+	switch {
+	case p.builtIn != other.builtIn:
+		return p.builtIn
+	case !p.builtIn && p.metadata.Priority != other.metadata.Priority:
+		return p.metadata.Priority > other.metadata.Priority
+	default:
+		return p.seq < other.seq
+	}
+}
@@ -0,0 +1,255 @@
+package mx_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+type gatedPlugin struct {
+	gate     chan struct{}
+	received atomic.Uint64
+}
+
+func (p *gatedPlugin) OnHook(context.Context, mx.SystemPluginHook) error {
+	<-p.gate
+	p.received.Add(1)
+	return nil
+}
+
+func (p *gatedPlugin) Name() string { return "test.gated" }
+
+type testHook struct{}
+
+func (testHook) HookName() mx.SystemPluginHookName { return "test.hook" }
+
+// pluginManager returns the plugin manager of the system that dispatched hooks to the recorder.
+func pluginManager(t *testing.T, recorder *hookRecorderPlugin) mx.SystemPluginManager {
+	t.Helper()
+	for _, h := range recorder.Hooks() {
+		if h, ok := h.(mx.SystemInitializationStartedHook); ok {
+			return h.System.PluginManager()
+		}
+	}
+	require.FailNow(t, "system initialization hook not recorded")
+	return nil
+}
+
+func pluginMetrics(pm mx.SystemPluginManager, name string) mx.SystemPluginMetrics {
+	for _, m := range pm.Metrics() {
+		if m.PluginName == name {
+			return m
+		}
+	}
+	return mx.SystemPluginMetrics{}
+}
+
+func TestSystemPluginManager_AsyncPlugin(t *testing.T) {
+	t.Run("GIVEN slow async plugin WHEN its queue is full THEN hooks should be dropped without blocking", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		gated := &gatedPlugin{gate: make(chan struct{})}
+		system := mx.NewSystem("test").
+			WithPlugin(recorder).
+			WithPlugin(mx.NewAsyncPlugin(gated, mx.AsyncPluginOptions{QueueSize: 1}))
+
+		var metrics mx.SystemPluginMetrics
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			pm := pluginManager(t, recorder)
+			for i := 0; i < 10; i++ {
+				pm.DispatchHook(ctx, testHook{})
+			}
+			metrics = pluginMetrics(pm, "test.gated")
+			close(gated.gate)
+			return nil
+		}})
+		require.NoError(t, err)
+
+		require.True(t, metrics.Async)
+		require.Equal(t, 1, metrics.QueueCapacity)
+		require.NotZero(t, metrics.Dropped)
+
+		// pending hooks are delivered before the system exits
+		final := pluginMetrics(pluginManager(t, recorder), "test.gated")
+		require.Equal(t, final.Delivered, gated.received.Load())
+		require.Zero(t, final.QueueLength)
+	})
+
+	t.Run("GIVEN async plugin blocking when full WHEN dispatching hooks THEN all hooks should be delivered", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		gated := &gatedPlugin{gate: make(chan struct{})}
+		close(gated.gate)
+		system := mx.NewSystem("test").
+			WithPlugin(recorder).
+			WithPlugin(mx.NewAsyncPlugin(gated, mx.AsyncPluginOptions{QueueSize: 1, BlockWhenFull: true}))
+
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(ctx context.Context) error {
+			pm := pluginManager(t, recorder)
+			for i := 0; i < 100; i++ {
+				pm.DispatchHook(ctx, testHook{})
+			}
+			return nil
+		}})
+		require.NoError(t, err)
+
+		metrics := pluginMetrics(pluginManager(t, recorder), "test.gated")
+		require.Zero(t, metrics.Dropped)
+		// the recorder was added first and also received its own PluginAddedHook
+		require.Equal(t, uint64(len(recorder.Hooks())-1), metrics.Delivered)
+		require.Equal(t, metrics.Delivered, gated.received.Load())
+	})
+}
+
+type rejectingPlugin struct {
+	hookName mx.SystemPluginHookName
+	err      error
+}
+
+func (p rejectingPlugin) OnHook(_ context.Context, hook mx.SystemPluginHook) error {
+	if hook.HookName() == p.hookName {
+		return p.err
+	}
+	return nil
+}
+
+func (p rejectingPlugin) Name() string { return "test.rejecting" }
+
+func TestSystemPluginManager_GuardPlugin(t *testing.T) {
+	errLicense := misas.ErrUnauthorized.WithMessage("license expired")
+	app := funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }}
+
+	t.Run("GIVEN guard rejecting system initialization WHEN running THEN should fail with guard error", func(t *testing.T) {
+		err := mx.NewSystem("test").
+			WithPlugin(mx.NewGuardPlugin(rejectingPlugin{hookName: mx.SystemInitializationStartedPluginHookName, err: errLicense})).
+			RunE(app)
+		require.ErrorIs(t, err, errLicense)
+	})
+
+	t.Run("GIVEN observer failing on system initialization WHEN running THEN should not fail", func(t *testing.T) {
+		err := mx.NewSystem("test").
+			WithPlugin(rejectingPlugin{hookName: mx.SystemInitializationStartedPluginHookName, err: errLicense}).
+			RunE(app)
+		require.NoError(t, err)
+	})
+
+	t.Run("GIVEN guard rejecting business subsystem WHEN running THEN initialization ended hook should report error", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		system := mx.NewSystem("test").
+			WithPlugin(recorder).
+			WithPlugin(mx.NewGuardPlugin(rejectingPlugin{hookName: mx.BusinessSubsystemInitializationStartedPluginHookName, err: errLicense})).
+			WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory"))
+
+		ran := false
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error {
+			ran = true
+			return nil
+		}})
+		require.ErrorIs(t, err, errLicense)
+		require.False(t, ran)
+
+		var ended []mx.BusinessSubsystemInitializationEndedHook
+		for _, h := range recorder.Hooks() {
+			if h, ok := h.(mx.BusinessSubsystemInitializationEndedHook); ok {
+				ended = append(ended, h)
+			}
+		}
+		require.Len(t, ended, 1)
+		require.ErrorIs(t, ended[0].Error, errLicense)
+	})
+
+	t.Run("GIVEN async plugin WHEN making it a guard THEN should panic", func(t *testing.T) {
+		async := mx.NewAsyncPlugin(rejectingPlugin{}, mx.AsyncPluginOptions{})
+		require.Panics(t, func() { mx.NewGuardPlugin(async) })
+	})
+}
+
+// orderRecorderPlugin records the order in which plugins receive the hooks it is subscribed to.
+type orderRecorderPlugin struct {
+	name     string
+	metadata mx.SystemPluginMetadata
+	order    *[]string
+}
+
+func (p orderRecorderPlugin) OnHook(context.Context, mx.SystemPluginHook) error {
+	*p.order = append(*p.order, p.name)
+	return nil
+}
+
+func (p orderRecorderPlugin) Name() string                      { return p.name }
+func (p orderRecorderPlugin) Metadata() mx.SystemPluginMetadata { return p.metadata }
+
+type prioritizedPlugin struct {
+	*hookRecorderPlugin
+	priority int
+}
+
+func (p prioritizedPlugin) Metadata() mx.SystemPluginMetadata {
+	return mx.SystemPluginMetadata{Priority: p.priority}
+}
+
+func TestSystemPluginManager_Metadata(t *testing.T) {
+	app := funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }}
+	initialization := []mx.SystemPluginHookName{mx.SystemInitializationStartedPluginHookName}
+
+	t.Run("GIVEN plugins with priorities and dependencies WHEN dispatching hooks THEN should deliver them in resolved order", func(t *testing.T) {
+		var order []string
+		err := mx.NewSystem("test").
+			WithPlugin(orderRecorderPlugin{name: "audit", order: &order, metadata: mx.SystemPluginMetadata{
+				Subscriptions: initialization,
+				DependsOn:     []string{"tracing"},
+			}}).
+			WithPlugin(orderRecorderPlugin{name: "metrics", order: &order, metadata: mx.SystemPluginMetadata{
+				Subscriptions: initialization,
+			}}).
+			WithPlugin(orderRecorderPlugin{name: "tracing", order: &order, metadata: mx.SystemPluginMetadata{
+				Subscriptions: initialization,
+				Priority:      10,
+				DependsOn:     []string{"system.logging"},
+			}}).
+			RunE(app)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"tracing", "audit", "metrics"}, order)
+	})
+
+	t.Run("GIVEN plugin with a high priority WHEN loading plugins THEN built-in plugins should still be added first", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		err := mx.NewSystem("test").
+			WithPlugin(prioritizedPlugin{hookRecorderPlugin: recorder, priority: 100}).
+			RunE(app)
+		require.NoError(t, err)
+
+		var added []string
+		for _, h := range recorder.Hooks() {
+			if h, ok := h.(mx.PluginAddedHook); ok {
+				added = append(added, h.PluginName)
+			}
+		}
+		// the recorder only receives the hooks dispatched once added, system.logging having been added before
+		require.Equal(t, "test.recorder", added[0])
+		require.NotContains(t, added, "system.logging")
+	})
+
+	t.Run("GIVEN missing dependency WHEN running THEN should fail", func(t *testing.T) {
+		var order []string
+		err := mx.NewSystem("test").
+			WithPlugin(orderRecorderPlugin{name: "audit", order: &order, metadata: mx.SystemPluginMetadata{DependsOn: []string{"tracing"}}}).
+			RunE(app)
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, `plugin "audit" depends on unknown plugin "tracing"`)
+		require.Empty(t, order)
+	})
+
+	t.Run("GIVEN cyclic dependencies WHEN running THEN should fail", func(t *testing.T) {
+		var order []string
+		err := mx.NewSystem("test").
+			WithPlugin(orderRecorderPlugin{name: "a", order: &order, metadata: mx.SystemPluginMetadata{DependsOn: []string{"b"}}}).
+			WithPlugin(mx.NewAsyncPlugin(orderRecorderPlugin{name: "b", order: &order, metadata: mx.SystemPluginMetadata{DependsOn: []string{"a"}}}, mx.AsyncPluginOptions{})).
+			RunE(app)
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, "cyclic dependency between plugins: a, b")
+	})
+}
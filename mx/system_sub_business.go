@@ -2,6 +2,7 @@ package mx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/morebec/misas/misas"
 	"github.com/samber/lo"
@@ -13,6 +14,18 @@ type BusinessSubsystemConf struct {
 	name            string
 	commandHandlers map[misas.CommandTypeName]misas.CommandHandler
 	eventHandlers   map[EventBusName][]misas.EventHandler
+	// initializers and teardowns, in registration order
+	lifecycle []subsystemLifecycleStep
+
+	// command types registered more than once, reported by the topology validation
+	duplicateCommandHandlers []misas.CommandTypeName
+	// messages registered with the message registries of the system the subsystem is added to
+	commandPrototypes []misas.Command
+	eventPrototypes   []misas.Event
+	// events declared through ProducesEvents, exported in the topology
+	producedEvents []misas.EventTypeName
+	// events declared through ProducesEventsOn, by event bus, exported in the AsyncAPI document
+	publishedEvents map[EventBusName][]misas.EventTypeName
 }
 
 func NewBusinessSubsystem(name string) *BusinessSubsystemConf {
@@ -23,11 +36,13 @@ func NewBusinessSubsystem(name string) *BusinessSubsystemConf {
 		name:            name,
 		commandHandlers: make(map[misas.CommandTypeName]misas.CommandHandler),
 		eventHandlers:   make(map[EventBusName][]misas.EventHandler),
+		publishedEvents: make(map[EventBusName][]misas.EventTypeName),
 	}
 }
 
 // WithCommandHandler registers a command handler for the given command type with the system's command bus.
-// It also automatically the command type in the global command registry for serialization purposes.
+// It also registers the command type with the message registries of the system for serialization purposes, once the
+// system runs or its registries are used, see SystemConf.MessageRegistries.
 func (bc *BusinessSubsystemConf) WithCommandHandler(ct misas.Command, h misas.CommandHandler) *BusinessSubsystemConf {
 	if ct == nil {
 		panic(fmt.Sprintf("business subsystem %s: command cannot be empty", bc.name))
@@ -35,11 +50,13 @@ func (bc *BusinessSubsystemConf) WithCommandHandler(ct misas.Command, h misas.Co
 	if h == nil {
 		panic(fmt.Sprintf("business subsystem %s: handler cannot be nil", bc.name))
 	}
+	if _, exists := bc.commandHandlers[ct.TypeName()]; exists {
+		bc.duplicateCommandHandlers = append(bc.duplicateCommandHandlers, ct.TypeName())
+	}
 	h = withCommandLogging(h)
 	h = withCommandContextPropagation(bc.name, h)
 	bc.commandHandlers[ct.TypeName()] = h
-
-	CommandRegistry.Register(ct.TypeName(), ct)
+	bc.commandPrototypes = append(bc.commandPrototypes, ct)
 
 	return bc
 }
@@ -60,15 +77,57 @@ func (bc *BusinessSubsystemConf) WithEventHandlers(eventBusName EventBusName, ha
 	return bc
 }
 
-// ProducesEvents registers the given events in the global event registry for serialization purposes.
+// ProducesEvents registers the given events with the message registries of the system for serialization purposes, once
+// the system runs or its registries are used, see SystemConf.MessageRegistries.
 func (bc *BusinessSubsystemConf) ProducesEvents(events ...misas.Event) *BusinessSubsystemConf {
 	for _, e := range events {
-		EventRegistry.Register(e.TypeName(), e)
+		bc.eventPrototypes = append(bc.eventPrototypes, e)
+		bc.producedEvents = append(bc.producedEvents, e.TypeName())
+	}
+
+	return bc
+}
+
+// ProducesEventsOn declares the given events as published by the subsystem on the given event bus.
+// It registers them like ProducesEvents.
+func (bc *BusinessSubsystemConf) ProducesEventsOn(eventBusName EventBusName, events ...misas.Event) *BusinessSubsystemConf {
+	if eventBusName == "" {
+		panic(fmt.Sprintf("business subsystem %s: event bus name cannot be empty", bc.name))
+	}
+
+	bc.ProducesEvents(events...)
+	for _, e := range events {
+		bc.publishedEvents[eventBusName] = append(bc.publishedEvents[eventBusName], e.TypeName())
 	}
 
 	return bc
 }
 
+// WithInitializer registers a function initializing resources of the subsystem, such as opening a database or warming
+// a cache. Initializers run in registration order during the initialization of the subsystem, before its handlers are
+// registered. An error aborts the initialization of the system.
+func (bc *BusinessSubsystemConf) WithInitializer(fn func(context.Context) error) *BusinessSubsystemConf {
+	if fn == nil {
+		panic(fmt.Sprintf("business subsystem %s: initializer cannot be nil", bc.name))
+	}
+	bc.lifecycle = append(bc.lifecycle, subsystemLifecycleStep{initialize: fn})
+
+	return bc
+}
+
+// WithTeardown registers a function releasing resources of the subsystem. A teardown is paired with the initializers
+// registered before it: it runs at system teardown only if all of them succeeded, even when a later initializer of the
+// subsystem failed. Registering a teardown right after the initializer acquiring its resources therefore releases them
+// exactly when they were acquired. Teardowns run in reverse registration order.
+func (bc *BusinessSubsystemConf) WithTeardown(fn func(context.Context) error) *BusinessSubsystemConf {
+	if fn == nil {
+		panic(fmt.Sprintf("business subsystem %s: teardown cannot be nil", bc.name))
+	}
+	bc.lifecycle = append(bc.lifecycle, subsystemLifecycleStep{teardown: fn})
+
+	return bc
+}
+
 type DynamicBindingCommandBus struct {
 	*DynamicBinding[misas.CommandBus]
 }
@@ -100,3 +159,16 @@ func (d DynamicBindingEventBus) RegisterHandler(handler misas.EventHandler) {
 func (d DynamicBindingEventBus) Publish(ctx context.Context, event misas.Event) error {
 	return d.Get().Publish(ctx, event)
 }
+
+// registerMessages registers the commands handled and the events produced by the subsystem.
+func (bc *BusinessSubsystemConf) registerMessages(r MessageRegistries) error {
+	var errs []error
+	for _, c := range bc.commandPrototypes {
+		errs = append(errs, r.Commands.TryRegister(c.TypeName(), c))
+	}
+	for _, e := range bc.eventPrototypes {
+		errs = append(errs, r.Events.TryRegister(e.TypeName(), e))
+	}
+
+	return errors.Join(errs...)
+}
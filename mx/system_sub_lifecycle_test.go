@@ -0,0 +1,124 @@
+package mx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubsystemLifecycle(t *testing.T) {
+	app := funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }}
+
+	t.Run("GIVEN subsystems with initializers and teardowns WHEN running THEN teardowns should run in reverse order", func(t *testing.T) {
+		var calls []string
+		record := func(call string) func(context.Context) error {
+			return func(ctx context.Context) error {
+				calls = append(calls, call+"@"+mx.Ctx(ctx).SubsystemInfo().Name)
+				return nil
+			}
+		}
+
+		err := mx.NewSystem("test").
+			WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+				WithInitializer(record("open database")).
+				WithTeardown(record("close database")).
+				WithTeardown(record("flush outbox")),
+			).
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+				WithInitializer(record("warm cache")).
+				WithTeardown(record("drop cache")),
+			).
+			RunE(app)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{
+			"open database@inventory",
+			"warm cache@reporting",
+			"drop cache@reporting",
+			"flush outbox@inventory",
+			"close database@inventory",
+		}, calls)
+	})
+
+	t.Run("GIVEN failing initializer WHEN running THEN should fail without tearing the subsystem down", func(t *testing.T) {
+		recorder := &hookRecorderPlugin{}
+		tornDown := false
+		err := mx.NewSystem("test").
+			WithPlugin(recorder).
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+				WithInitializer(func(context.Context) error { return misas.ErrInternal }).
+				WithTeardown(func(context.Context) error { tornDown = true; return nil }),
+			).
+			RunE(app)
+		require.ErrorIs(t, err, misas.ErrInternal)
+		require.ErrorContains(t, err, `failed to initialize query subsystem "reporting"`)
+		require.False(t, tornDown)
+
+		for _, h := range recorder.Hooks() {
+			if h, ok := h.(mx.QuerySubsystemInitializationEndedHook); ok {
+				require.ErrorIs(t, h.Error, misas.ErrInternal)
+			}
+		}
+	})
+
+	t.Run("GIVEN initializer failing after others succeeded WHEN running THEN should tear down the initialized subsystems", func(t *testing.T) {
+		var calls []string
+		record := func(call string, err error) func(context.Context) error {
+			return func(ctx context.Context) error {
+				calls = append(calls, call+"@"+mx.Ctx(ctx).SubsystemInfo().Name)
+				return err
+			}
+		}
+
+		err := mx.NewSystem("test").
+			WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+				WithInitializer(record("open database", nil)).
+				WithTeardown(record("close database", nil)),
+			).
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+				WithInitializer(record("warm cache", nil)).
+				WithTeardown(record("drop cache", nil)).
+				WithInitializer(record("subscribe projections", misas.ErrInternal)).
+				WithTeardown(record("unsubscribe projections", nil)),
+			).
+			RunE(app)
+		require.ErrorIs(t, err, misas.ErrInternal)
+		require.ErrorContains(t, err, `failed to initialize query subsystem "reporting"`)
+
+		require.Equal(t, []string{
+			"open database@inventory",
+			"warm cache@reporting",
+			"subscribe projections@reporting",
+			"drop cache@reporting",
+			"close database@inventory",
+		}, calls)
+	})
+
+	t.Run("GIVEN second of three initializers failing WHEN running THEN should only tear down the first one", func(t *testing.T) {
+		var calls []string
+		record := func(call string, err error) func(context.Context) error {
+			return func(context.Context) error {
+				calls = append(calls, call)
+				return err
+			}
+		}
+
+		err := mx.NewSystem("test").
+			WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+				WithInitializer(record("open database", nil)).
+				WithTeardown(record("close database", nil)).
+				WithInitializer(record("open broker", misas.ErrInternal)).
+				WithTeardown(record("close broker", nil)).
+				WithInitializer(record("warm cache", nil)).
+				WithTeardown(record("drop cache", nil)),
+			).
+			RunE(app)
+		require.ErrorIs(t, err, misas.ErrInternal)
+		require.ErrorContains(t, err, `failed to initialize business subsystem "inventory"`)
+
+		require.Equal(t, []string{"open database", "open broker", "close database"}, calls)
+	})
+}
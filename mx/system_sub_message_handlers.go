@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mtime"
 )
 
 // withCommandContextPropagation wraps a command handler to propagate subsystem context.
@@ -109,3 +110,55 @@ func withEventLogging(h misas.EventHandler) misas.EventHandler {
 		return err
 	})
 }
+
+// withCommandPanicRecovery wraps a command handler to convert panics into a misas.ErrInternal command result.
+func withCommandPanicRecovery(subsystemName string, pm SystemPluginManager, clock mtime.Clock, h misas.CommandHandler) misas.CommandHandler {
+	return misas.CommandHandlerFunc(func(ctx context.Context, cmd misas.Command) (result misas.CommandResult) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = misas.CommandResult{Error: dispatchRecoveredPanic(ctx, pm, clock, subsystemName, string(cmd.TypeName()), r)}
+			}
+		}()
+		return h.Handle(ctx, cmd)
+	})
+}
+
+// withQueryPanicRecovery wraps a query handler to convert panics into a misas.ErrInternal query result.
+func withQueryPanicRecovery(subsystemName string, pm SystemPluginManager, clock mtime.Clock, h misas.QueryHandler) misas.QueryHandler {
+	return misas.QueryHandlerFunc(func(ctx context.Context, q misas.Query) (result misas.QueryResult) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = misas.QueryResult{Error: dispatchRecoveredPanic(ctx, pm, clock, subsystemName, string(q.TypeName()), r)}
+			}
+		}()
+		return h.Handle(ctx, q)
+	})
+}
+
+// withEventPanicRecovery wraps an event handler to convert panics into a misas.ErrInternal error.
+func withEventPanicRecovery(subsystemName string, pm SystemPluginManager, clock mtime.Clock, h misas.EventHandler) misas.EventHandler {
+	return misas.EventHandlerFunc(func(ctx context.Context, e misas.Event) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = dispatchRecoveredPanic(ctx, pm, clock, subsystemName, string(e.TypeName()), r)
+			}
+		}()
+		return h.Handle(ctx, e)
+	})
+}
+
+// dispatchRecoveredPanic converts a recovered panic into an error and notifies plugins about it.
+func dispatchRecoveredPanic(ctx context.Context, pm SystemPluginManager, clock mtime.Clock, subsystemName string, messageTypeName string, recovered any) error {
+	err := newPanicError(recovered)
+	cause, _ := err.Cause().(PanicError)
+	pm.DispatchHook(ctx, PanicRecoveredHook{
+		SubsystemName:   subsystemName,
+		MessageTypeName: messageTypeName,
+		Value:           recovered,
+		Stack:           cause.Stack,
+		Error:           err,
+		RecoveredAt:     clock.Now(),
+	})
+
+	return err
+}
@@ -2,6 +2,7 @@ package mx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/morebec/misas/misas"
 	"github.com/samber/lo"
@@ -11,6 +12,14 @@ type QuerySubsystemConf struct {
 	name          string
 	queryHandlers map[misas.QueryTypeName]misas.QueryHandler
 	eventHandlers map[EventBusName][]misas.EventHandler
+	// initializers and teardowns, in registration order
+	lifecycle []subsystemLifecycleStep
+
+	// queries registered with the message registries of the system the subsystem is added to
+	queryPrototypes []misas.Query
+
+	// query types registered more than once, reported by the topology validation
+	duplicateQueryHandlers []misas.QueryTypeName
 }
 
 func NewQuerySubsystem(name string) *QuerySubsystemConf {
@@ -21,11 +30,13 @@ func NewQuerySubsystem(name string) *QuerySubsystemConf {
 	return &QuerySubsystemConf{
 		name:          name,
 		queryHandlers: make(map[misas.QueryTypeName]misas.QueryHandler),
+		eventHandlers: make(map[EventBusName][]misas.EventHandler),
 	}
 }
 
 // WithQueryHandler registers a query handler for the given query type with the system's query bus.
-// It also automatically the query type in the global query registry for serialization purposes.
+// It also registers the query type with the message registries of the system for serialization purposes, once the
+// system runs or its registries are used, see SystemConf.MessageRegistries.
 func (qc *QuerySubsystemConf) WithQueryHandler(qt misas.Query, h misas.QueryHandler) *QuerySubsystemConf {
 	if qt == nil {
 		panic(fmt.Sprintf("query subsystem %s: query cannot be empty", qc.name))
@@ -33,10 +44,13 @@ func (qc *QuerySubsystemConf) WithQueryHandler(qt misas.Query, h misas.QueryHand
 	if h == nil {
 		panic(fmt.Sprintf("query subsystem %s: handler cannot be nil", qc.name))
 	}
+	if _, exists := qc.queryHandlers[qt.TypeName()]; exists {
+		qc.duplicateQueryHandlers = append(qc.duplicateQueryHandlers, qt.TypeName())
+	}
 	h = withQueryLogging(h)
 	h = withQueryContextPropagation(qc.name, h)
 	qc.queryHandlers[qt.TypeName()] = h
-	QueryRegistry.Register(qt.TypeName(), qt)
+	qc.queryPrototypes = append(qc.queryPrototypes, qt)
 
 	return qc
 }
@@ -57,6 +71,37 @@ func (qc *QuerySubsystemConf) WithEventHandlers(eventBusName EventBusName, handl
 	return qc
 }
 
+// WithInitializer registers a function initializing resources of the query subsystem, such as warming a read model.
+// It follows the same rules as BusinessSubsystemConf.WithInitializer.
+func (qc *QuerySubsystemConf) WithInitializer(fn func(context.Context) error) *QuerySubsystemConf {
+	if fn == nil {
+		panic(fmt.Sprintf("query subsystem %s: initializer cannot be nil", qc.name))
+	}
+	qc.lifecycle = append(qc.lifecycle, subsystemLifecycleStep{initialize: fn})
+
+	return qc
+}
+
+// WithTeardown registers a function releasing resources of the query subsystem, see BusinessSubsystemConf.WithTeardown.
+func (qc *QuerySubsystemConf) WithTeardown(fn func(context.Context) error) *QuerySubsystemConf {
+	if fn == nil {
+		panic(fmt.Sprintf("query subsystem %s: teardown cannot be nil", qc.name))
+	}
+	qc.lifecycle = append(qc.lifecycle, subsystemLifecycleStep{teardown: fn})
+
+	return qc
+}
+
+// registerMessages registers the queries handled by the subsystem.
+func (qc *QuerySubsystemConf) registerMessages(r MessageRegistries) error {
+	var errs []error
+	for _, q := range qc.queryPrototypes {
+		errs = append(errs, r.Queries.TryRegister(q.TypeName(), q))
+	}
+
+	return errors.Join(errs...)
+}
+
 type DynamicBindingQueryBus struct {
 	*DynamicBinding[misas.QueryBus]
 }
@@ -0,0 +1,164 @@
+package mx
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
+)
+
+// TopologyIssueKind categorizes the misconfigurations found by SystemConf.ValidateTopology.
+type TopologyIssueKind string
+
+const (
+	// TopologyIssueDuplicateSubsystem indicates a subsystem name registered more than once, the last registration winning.
+	TopologyIssueDuplicateSubsystem TopologyIssueKind = "duplicate_subsystem"
+	// TopologyIssueDuplicateHandler indicates a command or query type handled more than once, the last handler winning.
+	TopologyIssueDuplicateHandler TopologyIssueKind = "duplicate_handler"
+	// TopologyIssueOrphanEventBus indicates event handlers subscribed to an event bus that does not publish events.
+	TopologyIssueOrphanEventBus TopologyIssueKind = "orphan_event_bus"
+	// TopologyIssueUnsubscribedEventBus indicates an event bus without subscribers. Since an event bus may only exist
+	// to publish events to other systems, it is a warning never failing the startup of the system.
+	TopologyIssueUnsubscribedEventBus TopologyIssueKind = "unsubscribed_event_bus"
+	// TopologyIssueUnhandledMessage indicates a command or query registered in a registry without a handler. Since
+	// registries may hold messages sent to other systems, it is a warning never failing the startup of the system.
+	TopologyIssueUnhandledMessage TopologyIssueKind = "unhandled_message"
+	// TopologyIssueEmptySubsystem indicates a subsystem without any handler.
+	TopologyIssueEmptySubsystem TopologyIssueKind = "empty_subsystem"
+	// TopologyIssueMessageConflict indicates a message type name registered for different Go types, see
+	// MessageRegistry.TryRegister.
+	TopologyIssueMessageConflict TopologyIssueKind = "message_conflict"
+)
+
+// isWarning indicates whether issues of this kind are only logged, even when the validation is strict.
+func (k TopologyIssueKind) isWarning() bool {
+	return k == TopologyIssueUnhandledMessage || k == TopologyIssueUnsubscribedEventBus
+}
+
+// TopologyIssue is a misconfiguration of the topology of a system.
+type TopologyIssue struct {
+	Kind      TopologyIssueKind
+	Subsystem string // Subsystem concerned by the issue, empty if it concerns the system
+	Message   string
+}
+
+func (i TopologyIssue) String() string { return fmt.Sprintf("%s: %s", i.Kind, i.Message) }
+
+// topologyError converts topology issues into a misas.ErrInvalid listing them.
+func topologyError(issues []TopologyIssue) error {
+	return misas.ErrInvalid.WithMessage("invalid topology: " + strings.Join(
+		lo.Map(issues, func(i TopologyIssue, _ int) string { return i.String() }), "; ",
+	))
+}
+
+// ValidateTopology checks the configuration of the system for duplicate subsystems and handlers, conflicting messages,
+// orphan and unsubscribed event buses, commands and queries registered without handlers and subsystems without
+// handlers. RunE validates the topology before starting the system: issues are logged as warnings, or fail the startup
+// when the validation is strict (see WithStrictTopologyValidation), unhandled messages and unsubscribed event buses
+// excepted.
+func (sc *SystemConf) ValidateTopology() []TopologyIssue {
+	var issues []TopologyIssue
+	issue := func(kind TopologyIssueKind, subsystem string, format string, args ...any) {
+		issues = append(issues, TopologyIssue{Kind: kind, Subsystem: subsystem, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, name := range lo.Uniq(sc.duplicateSubsystems) {
+		issue(TopologyIssueDuplicateSubsystem, name, "subsystem %q is registered more than once", name)
+	}
+
+	commandHandlers := make(map[misas.CommandTypeName][]string)
+	queryHandlers := make(map[misas.QueryTypeName][]string)
+	subscribers := make(map[EventBusName][]string)
+
+	for _, bs := range sc.businessSubsystems {
+		for _, ct := range lo.Uniq(bs.duplicateCommandHandlers) {
+			issue(TopologyIssueDuplicateHandler, bs.name, "business subsystem %q handles command %q more than once", bs.name, ct)
+		}
+		for ct := range bs.commandHandlers {
+			commandHandlers[ct] = append(commandHandlers[ct], bs.name)
+		}
+		for bus := range bs.eventHandlers {
+			subscribers[bus] = append(subscribers[bus], bs.name)
+		}
+		if len(bs.commandHandlers) == 0 && len(bs.eventHandlers) == 0 {
+			issue(TopologyIssueEmptySubsystem, bs.name, "business subsystem %q has no command or event handlers", bs.name)
+		}
+	}
+
+	for _, qs := range sc.querySubsystems {
+		for _, qt := range lo.Uniq(qs.duplicateQueryHandlers) {
+			issue(TopologyIssueDuplicateHandler, qs.name, "query subsystem %q handles query %q more than once", qs.name, qt)
+		}
+		for qt := range qs.queryHandlers {
+			queryHandlers[qt] = append(queryHandlers[qt], qs.name)
+		}
+		for bus := range qs.eventHandlers {
+			subscribers[bus] = append(subscribers[bus], qs.name)
+		}
+		if len(qs.queryHandlers) == 0 && len(qs.eventHandlers) == 0 {
+			issue(TopologyIssueEmptySubsystem, qs.name, "query subsystem %q has no query or event handlers", qs.name)
+		}
+	}
+
+	for ct, subsystems := range commandHandlers {
+		if len(subsystems) > 1 {
+			slices.Sort(subsystems)
+			issue(TopologyIssueDuplicateHandler, "", "command %q is handled by several business subsystems: %s", ct, strings.Join(subsystems, ", "))
+		}
+	}
+	for qt, subsystems := range queryHandlers {
+		if len(subsystems) > 1 {
+			slices.Sort(subsystems)
+			issue(TopologyIssueDuplicateHandler, "", "query %q is handled by several query subsystems: %s", qt, strings.Join(subsystems, ", "))
+		}
+	}
+
+	for bus, subsystems := range subscribers {
+		if _, ok := sc.eventBuses[bus]; !ok {
+			for _, subsystem := range subsystems {
+				issue(TopologyIssueOrphanEventBus, subsystem, "subsystem %q subscribes to event bus %q, but it does not publish events", subsystem, bus)
+			}
+		}
+	}
+	for bus := range sc.eventBuses {
+		if _, ok := subscribers[bus]; !ok && bus != SystemEventBusName {
+			issue(TopologyIssueUnsubscribedEventBus, "", "event bus %q has no subscribers", bus)
+		}
+	}
+
+	if err := sc.registerMessages(); err != nil {
+		for _, err := range joinedErrors(err) {
+			issue(TopologyIssueMessageConflict, "", "%s", err)
+		}
+	}
+	registries := sc.registries
+	for _, ct := range registries.Commands.TypeNames() {
+		if _, ok := commandHandlers[ct]; !ok {
+			issue(TopologyIssueUnhandledMessage, "", "command %q is registered without handler", ct)
+		}
+	}
+	for _, qt := range registries.Queries.TypeNames() {
+		if _, ok := queryHandlers[qt]; !ok {
+			issue(TopologyIssueUnhandledMessage, "", "query %q is registered without handler", qt)
+		}
+	}
+
+	slices.SortFunc(issues, func(a, b TopologyIssue) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Message, b.Message))
+	})
+
+	return issues
+}
+
+// joinedErrors returns the errors joined by errors.Join, recursively.
+func joinedErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	return lo.FlatMap(joined.Unwrap(), func(err error, _ int) []error { return joinedErrors(err) })
+}
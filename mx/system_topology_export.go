@@ -0,0 +1,290 @@
+package mx
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/morebec/misas/misas"
+	"github.com/samber/lo"
+)
+
+// Topology describes the architecture of a system: its subsystems, the messages they handle and produce,
+// the event buses connecting them, its plugins and application subsystem.
+type Topology struct {
+	System             string               `json:"system"`
+	BusinessSubsystems []TopologySubsystem  `json:"businessSubsystems"`
+	QuerySubsystems    []TopologySubsystem  `json:"querySubsystems"`
+	EventBuses         []EventBusName       `json:"eventBuses"`
+	Plugins            []string             `json:"plugins"`
+	Application        *TopologyApplication `json:"application,omitempty"`
+}
+
+// TopologySubsystem describes a business or query subsystem.
+type TopologySubsystem struct {
+	Name           string                  `json:"name"`
+	Commands       []misas.CommandTypeName `json:"commands,omitempty"`
+	Queries        []misas.QueryTypeName   `json:"queries,omitempty"`
+	Subscriptions  []EventBusName          `json:"subscriptions,omitempty"`
+	ProducedEvents []misas.EventTypeName   `json:"producedEvents,omitempty"`
+}
+
+// TopologyApplication describes an application subsystem, and the applications it supervises if any.
+type TopologyApplication struct {
+	Name         string                `json:"name"`
+	DependsOn    []string              `json:"dependsOn,omitempty"`
+	Applications []TopologyApplication `json:"applications,omitempty"`
+}
+
+// Topology returns the topology of the system, as it would run the given application subsystem.
+// The application may be nil, in which case the topology does not describe it.
+func (sc *SystemConf) Topology(app ApplicationSubsystem) Topology {
+	t := Topology{
+		System:             sc.name,
+		BusinessSubsystems: []TopologySubsystem{},
+		QuerySubsystems:    []TopologySubsystem{},
+		EventBuses:         slices.Sorted(maps.Keys(sc.eventBuses)),
+	}
+
+	for _, bs := range sc.businessSubsystems {
+		t.BusinessSubsystems = append(t.BusinessSubsystems, TopologySubsystem{
+			Name:           bs.name,
+			Commands:       slices.Sorted(maps.Keys(bs.commandHandlers)),
+			Subscriptions:  slices.Sorted(maps.Keys(bs.eventHandlers)),
+			ProducedEvents: lo.Uniq(slices.Sorted(slices.Values(bs.producedEvents))),
+		})
+	}
+	for _, qs := range sc.querySubsystems {
+		t.QuerySubsystems = append(t.QuerySubsystems, TopologySubsystem{
+			Name:          qs.name,
+			Queries:       slices.Sorted(maps.Keys(qs.queryHandlers)),
+			Subscriptions: slices.Sorted(maps.Keys(qs.eventHandlers)),
+		})
+	}
+	bySubsystemName := func(a, b TopologySubsystem) int { return cmp.Compare(a.Name, b.Name) }
+	slices.SortFunc(t.BusinessSubsystems, bySubsystemName)
+	slices.SortFunc(t.QuerySubsystems, bySubsystemName)
+
+	t.Plugins = []string{loggingPlugin{}.Name()}
+	if sc.systemEvents {
+		t.Plugins = append(t.Plugins, systemEventsPlugin{}.Name())
+	}
+	for _, p := range sc.plugins {
+		t.Plugins = append(t.Plugins, p.Name())
+	}
+
+	if app != nil {
+		t.Application = &TopologyApplication{Name: app.Name()}
+		if p, ok := app.(SystemPlugin); ok {
+			t.Plugins = append(t.Plugins, p.Name())
+		}
+		if s, ok := app.(*Supervisor); ok {
+			t.Application.Applications = s.topology()
+		}
+	}
+
+	return t
+}
+
+// JSON renders the topology as indented JSON.
+func (t Topology) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// Mermaid renders the topology as a Mermaid flowchart.
+func (t Topology) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	nodes := topologyNodes{}
+	writeSubsystems := func(kind string, subsystems []TopologySubsystem) {
+		fmt.Fprintf(&b, "  subgraph %s[%q]\n", kind, kind+" subsystems")
+		for _, s := range subsystems {
+			fmt.Fprintf(&b, "    %s[%q]\n", nodes.id(kind, s.Name), s.Name)
+		}
+		b.WriteString("  end\n")
+	}
+	writeSubsystems("business", t.BusinessSubsystems)
+	writeSubsystems("query", t.QuerySubsystems)
+
+	for _, bus := range t.EventBuses {
+		fmt.Fprintf(&b, "  %s[(%q)]\n", nodes.id("bus", string(bus)), bus)
+	}
+
+	for _, s := range t.BusinessSubsystems {
+		for _, c := range s.Commands {
+			fmt.Fprintf(&b, "  %s([%q]) --> %s\n", nodes.id("command", string(c)), c, nodes.id("business", s.Name))
+		}
+		for _, e := range s.ProducedEvents {
+			fmt.Fprintf(&b, "  %s -- produces --> %s>%q]\n", nodes.id("business", s.Name), nodes.id("event", string(e)), e)
+		}
+	}
+	for _, s := range t.QuerySubsystems {
+		for _, q := range s.Queries {
+			fmt.Fprintf(&b, "  %s([%q]) --> %s\n", nodes.id("query_type", string(q)), q, nodes.id("query", s.Name))
+		}
+	}
+	t.forEachSubscription(func(kind string, s TopologySubsystem, bus EventBusName) {
+		fmt.Fprintf(&b, "  %s -.-> %s\n", nodes.id("bus", string(bus)), nodes.id(kind, s.Name))
+	})
+
+	if len(t.Plugins) != 0 {
+		b.WriteString("  subgraph plugins[\"plugins\"]\n")
+		for _, p := range t.Plugins {
+			fmt.Fprintf(&b, "    %s{{%q}}\n", nodes.id("plugin", p), p)
+		}
+		b.WriteString("  end\n")
+	}
+
+	if t.Application != nil {
+		fmt.Fprintf(&b, "  %s[[%q]]\n", nodes.id("app", t.Application.Name), t.Application.Name)
+		for _, a := range t.Application.Applications {
+			fmt.Fprintf(&b, "  %s -- supervises --> %s[[%q]]\n", nodes.id("app", t.Application.Name), nodes.id("app", a.Name), a.Name)
+			for _, dependency := range a.DependsOn {
+				fmt.Fprintf(&b, "  %s -. depends on .-> %s\n", nodes.id("app", a.Name), nodes.id("app", dependency))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// DOT renders the topology as a Graphviz DOT digraph.
+func (t Topology) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", t.System)
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+
+	nodes := topologyNodes{}
+	writeSubsystems := func(kind string, subsystems []TopologySubsystem) {
+		fmt.Fprintf(&b, "  subgraph \"cluster_%s\" {\n    label=%q;\n", kind, kind+" subsystems")
+		for _, s := range subsystems {
+			fmt.Fprintf(&b, "    %s [label=%q];\n", nodes.id(kind, s.Name), s.Name)
+		}
+		b.WriteString("  }\n")
+	}
+	writeSubsystems("business", t.BusinessSubsystems)
+	writeSubsystems("query", t.QuerySubsystems)
+
+	for _, bus := range t.EventBuses {
+		fmt.Fprintf(&b, "  %s [label=%q, shape=cylinder];\n", nodes.id("bus", string(bus)), bus)
+	}
+
+	for _, s := range t.BusinessSubsystems {
+		for _, c := range s.Commands {
+			fmt.Fprintf(&b, "  %s [label=%q, shape=ellipse];\n", nodes.id("command", string(c)), c)
+			fmt.Fprintf(&b, "  %s -> %s;\n", nodes.id("command", string(c)), nodes.id("business", s.Name))
+		}
+		for _, e := range s.ProducedEvents {
+			fmt.Fprintf(&b, "  %s [label=%q, shape=note];\n", nodes.id("event", string(e)), e)
+			fmt.Fprintf(&b, "  %s -> %s [label=\"produces\"];\n", nodes.id("business", s.Name), nodes.id("event", string(e)))
+		}
+	}
+	for _, s := range t.QuerySubsystems {
+		for _, q := range s.Queries {
+			fmt.Fprintf(&b, "  %s [label=%q, shape=ellipse];\n", nodes.id("query_type", string(q)), q)
+			fmt.Fprintf(&b, "  %s -> %s;\n", nodes.id("query_type", string(q)), nodes.id("query", s.Name))
+		}
+	}
+	t.forEachSubscription(func(kind string, s TopologySubsystem, bus EventBusName) {
+		fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", nodes.id("bus", string(bus)), nodes.id(kind, s.Name))
+	})
+
+	if len(t.Plugins) != 0 {
+		b.WriteString("  subgraph \"cluster_plugins\" {\n    label=\"plugins\";\n")
+		for _, p := range t.Plugins {
+			fmt.Fprintf(&b, "    %s [label=%q, shape=hexagon];\n", nodes.id("plugin", p), p)
+		}
+		b.WriteString("  }\n")
+	}
+
+	if t.Application != nil {
+		fmt.Fprintf(&b, "  %s [label=%q, shape=component];\n", nodes.id("app", t.Application.Name), t.Application.Name)
+		for _, a := range t.Application.Applications {
+			fmt.Fprintf(&b, "  %s [label=%q, shape=component];\n", nodes.id("app", a.Name), a.Name)
+			fmt.Fprintf(&b, "  %s -> %s [label=\"supervises\"];\n", nodes.id("app", t.Application.Name), nodes.id("app", a.Name))
+			for _, dependency := range a.DependsOn {
+				fmt.Fprintf(&b, "  %s -> %s [label=\"depends on\", style=dotted];\n", nodes.id("app", a.Name), nodes.id("app", dependency))
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func (t Topology) forEachSubscription(fn func(kind string, s TopologySubsystem, bus EventBusName)) {
+	for _, s := range t.BusinessSubsystems {
+		for _, bus := range s.Subscriptions {
+			fn("business", s, bus)
+		}
+	}
+	for _, s := range t.QuerySubsystems {
+		for _, bus := range s.Subscriptions {
+			fn("query", s, bus)
+		}
+	}
+}
+
+// topologyNodes assigns stable identifiers, valid in Mermaid and DOT, to the nodes of a topology.
+type topologyNodes map[string]string
+
+func (n topologyNodes) id(kind string, name string) string {
+	key := kind + ":" + name
+	if id, ok := n[key]; ok {
+		return id
+	}
+
+	id := kind + "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	// names differing only by special characters must not share a node
+	for slices.Contains(lo.Values(n), id) {
+		id += "_"
+	}
+	n[key] = id
+
+	return id
+}
+
+// WithTopologyDump makes the system write its topology as topology.json, topology.mmd and topology.dot into dir
+// at startup, when debug mode is enabled.
+func (sc *SystemConf) WithTopologyDump(dir string) *SystemConf {
+	sc.topologyDumpDir = dir
+
+	return sc
+}
+
+// dumpTopology writes the topology renderings into dir.
+func dumpTopology(dir string, t Topology) error {
+	js, err := t.JSON()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"topology.json": js,
+		"topology.mmd":  []byte(t.Mermaid()),
+		"topology.dot":  []byte(t.DOT()),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mx_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/morebec/misas/mxtest"
+	"github.com/stretchr/testify/require"
+)
+
+func topologyTestSystem() *mx.SystemConf {
+	system := mx.NewSystem("shop")
+	system.EventBus("inventory.events")
+	system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").
+		WithCommandHandler(mxtest.MockCommand{}, misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult {
+			return misas.CommandResult{}
+		})).
+		ProducesEvents(stockAdjustedEvent{}),
+	)
+	system.WithQuerySubsystem(mx.NewQuerySubsystem("reporting").
+		WithQueryHandler(stockLevelQuery{}, misas.QueryHandlerFunc(func(context.Context, misas.Query) misas.QueryResult {
+			return misas.QueryResult{}
+		})).
+		WithEventHandlers("inventory.events", misas.EventHandlerFunc(func(context.Context, misas.Event) error { return nil })),
+	)
+
+	return system
+}
+
+func TestSystemConf_Topology(t *testing.T) {
+	supervisor := mx.NewSupervisor().
+		WithApplicationSubsystem(blockingApplication("api"), &mx.SupervisionOptions{DependsOn: []string{"worker"}}).
+		WithApplicationSubsystem(blockingApplication("worker"), nil)
+
+	t.Run("GIVEN configured system WHEN exporting topology as JSON THEN should describe subsystems and applications", func(t *testing.T) {
+		js, err := topologyTestSystem().Topology(supervisor).JSON()
+		require.NoError(t, err)
+
+		var topology mx.Topology
+		require.NoError(t, json.Unmarshal(js, &topology))
+		require.Equal(t, mx.Topology{
+			System: "shop",
+			BusinessSubsystems: []mx.TopologySubsystem{{
+				Name:           "inventory",
+				Commands:       []misas.CommandTypeName{"mxtest.MockCommand"},
+				ProducedEvents: []misas.EventTypeName{"inventory.stock_adjusted"},
+			}},
+			QuerySubsystems: []mx.TopologySubsystem{{
+				Name:          "reporting",
+				Queries:       []misas.QueryTypeName{"inventory.stock_level"},
+				Subscriptions: []mx.EventBusName{"inventory.events"},
+			}},
+			EventBuses: []mx.EventBusName{"inventory.events"},
+			Plugins:    []string{"system.logging", "supervisor"},
+			Application: &mx.TopologyApplication{
+				Name: "supervisor",
+				Applications: []mx.TopologyApplication{
+					{Name: "api", DependsOn: []string{"worker"}},
+					{Name: "worker"},
+				},
+			},
+		}, topology)
+	})
+
+	t.Run("GIVEN configured system WHEN rendering topology THEN should produce Mermaid and DOT graphs", func(t *testing.T) {
+		topology := topologyTestSystem().Topology(supervisor)
+
+		mermaid := topology.Mermaid()
+		require.Contains(t, mermaid, "flowchart LR\n")
+		require.Contains(t, mermaid, `command_mxtest_MockCommand(["mxtest.MockCommand"]) --> business_inventory`)
+		require.Contains(t, mermaid, `bus_inventory_events -.-> query_reporting`)
+		require.Contains(t, mermaid, `app_api -. depends on .-> app_worker`)
+
+		dot := topology.DOT()
+		require.Contains(t, dot, `digraph "shop" {`)
+		require.Contains(t, dot, `business_inventory -> event_inventory_stock_adjusted [label="produces"];`)
+		require.Contains(t, dot, `bus_inventory_events -> query_reporting [style=dashed];`)
+	})
+
+	t.Run("GIVEN topology dump in debug mode WHEN running THEN should write renderings", func(t *testing.T) {
+		dir := t.TempDir()
+		err := topologyTestSystem().
+			WithDebug(true).
+			WithTopologyDump(dir).
+			RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.NoError(t, err)
+
+		for _, name := range []string{"topology.json", "topology.mmd", "topology.dot"} {
+			_, err := os.Stat(filepath.Join(dir, name))
+			require.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package mx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+	"github.com/morebec/misas/mxtest"
+	"github.com/stretchr/testify/require"
+)
+
+type unhandledQuery struct{}
+
+func (unhandledQuery) TypeName() misas.QueryTypeName { return "topology.unhandled_query" }
+
+func topologyIssues(issues []mx.TopologyIssue, kind mx.TopologyIssueKind) []string {
+	var messages []string
+	for _, issue := range issues {
+		if issue.Kind == kind {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return messages
+}
+
+func TestSystemConf_ValidateTopology(t *testing.T) {
+	handler := misas.CommandHandlerFunc(func(context.Context, misas.Command) misas.CommandResult { return misas.CommandResult{} })
+
+	t.Run("GIVEN misconfigured system WHEN validating topology THEN should report issues", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		registries.Queries.Register(unhandledQuery{}.TypeName(), unhandledQuery{})
+
+		system := mx.NewSystem("test").WithMessageRegistries(registries)
+		system.EventBus("inventory.events")
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").WithCommandHandler(mxtest.MockCommand{}, handler))
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("shipping").
+			WithCommandHandler(mxtest.MockCommand{}, handler).
+			WithEventHandlers("orders.events", misas.EventHandlerFunc(func(context.Context, misas.Event) error { return nil })),
+		)
+		system.WithQuerySubsystem(mx.NewQuerySubsystem("reporting"))
+
+		issues := system.ValidateTopology()
+
+		require.Equal(t, []string{
+			`command "mxtest.MockCommand" is handled by several business subsystems: inventory, shipping`,
+		}, topologyIssues(issues, mx.TopologyIssueDuplicateHandler))
+		require.Equal(t, []string{
+			`subsystem "shipping" subscribes to event bus "orders.events", but it does not publish events`,
+		}, topologyIssues(issues, mx.TopologyIssueOrphanEventBus))
+		require.Equal(t, []string{
+			`event bus "inventory.events" has no subscribers`,
+		}, topologyIssues(issues, mx.TopologyIssueUnsubscribedEventBus))
+		require.Equal(t, []string{
+			`query subsystem "reporting" has no query or event handlers`,
+		}, topologyIssues(issues, mx.TopologyIssueEmptySubsystem))
+		require.Equal(t, []string{
+			`query "topology.unhandled_query" is registered without handler`,
+		}, topologyIssues(issues, mx.TopologyIssueUnhandledMessage))
+	})
+
+	t.Run("GIVEN production system with issues WHEN running THEN should fail startup", func(t *testing.T) {
+		ran := false
+		err := mx.NewSystem("test").
+			WithEnvironment(mx.EnvironmentProduction).
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting")).
+			RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error {
+				ran = true
+				return nil
+			}})
+		require.ErrorIs(t, err, misas.ErrInvalid)
+		require.ErrorContains(t, err, `query subsystem "reporting" has no query or event handlers`)
+		require.False(t, ran)
+	})
+
+	t.Run("GIVEN production system with unhandled messages WHEN running THEN should start", func(t *testing.T) {
+		registries := mx.NewMessageRegistries()
+		registries.Queries.Register(unhandledQuery{}.TypeName(), unhandledQuery{})
+
+		err := mx.NewSystem("test").
+			WithEnvironment(mx.EnvironmentProduction).
+			WithMessageRegistries(registries).
+			RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.NoError(t, err)
+	})
+
+	t.Run("GIVEN production system with event bus without subscribers WHEN running THEN should start", func(t *testing.T) {
+		system := mx.NewSystem("test").
+			WithEnvironment(mx.EnvironmentProduction).
+			WithMessageRegistries(mx.NewMessageRegistries()).
+			WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").WithCommandHandler(mxtest.MockCommand{}, handler))
+		system.EventBus("inventory.events")
+
+		err := system.RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.NoError(t, err)
+	})
+
+	t.Run("GIVEN subsystems registering a type name for different Go types WHEN validating topology THEN should report a conflict", func(t *testing.T) {
+		system := mx.NewSystem("test").WithMessageRegistries(mx.NewMessageRegistries())
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("inventory").WithCommandHandler(adjustStockCommand{}, handler))
+		system.WithBusinessSubsystem(mx.NewBusinessSubsystem("shipping").WithCommandHandler(conflictingAdjustStockCommand{}, handler))
+
+		var issues []mx.TopologyIssue
+		require.NotPanics(t, func() { issues = system.ValidateTopology() })
+
+		conflicts := topologyIssues(issues, mx.TopologyIssueMessageConflict)
+		require.Len(t, conflicts, 1)
+		require.Contains(t, conflicts[0], `message "inventory.adjust_stock" is already registered`)
+	})
+
+	t.Run("GIVEN non strict validation WHEN running with issues THEN should start", func(t *testing.T) {
+		err := mx.NewSystem("test").
+			WithEnvironment(mx.EnvironmentProduction).
+			WithStrictTopologyValidation(false).
+			WithQuerySubsystem(mx.NewQuerySubsystem("reporting")).
+			RunE(funcApplicationSubsystem{name: "app", run: func(context.Context) error { return nil }})
+		require.NoError(t, err)
+	})
+}
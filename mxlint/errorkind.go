@@ -0,0 +1,72 @@
+package mxlint
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"github.com/morebec/misas/misas"
+)
+
+// ErrorKindAnalyzer reports misas.ErrorKind values converted from strings only known at runtime, and string literals
+// spelling the value of a misas error kind. Error kinds are matched by value, a misspelled or computed kind silently
+// failing to match.
+var ErrorKindAnalyzer = &analysis.Analyzer{
+	Name:     "errorkind",
+	Doc:      "reports misas.ErrorKind values built at runtime or spelled as literals instead of the misas constants",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runErrorKind,
+}
+
+// misasErrorKinds maps the values of the misas error kinds to their names.
+var misasErrorKinds = map[string]string{
+	string(misas.ErrorKindBadLogic):        "ErrorKindBadLogic",
+	string(misas.ErrorKindInternal):        "ErrorKindInternal",
+	string(misas.ErrorKindInvalid):         "ErrorKindInvalid",
+	string(misas.ErrorKindNotFound):        "ErrorKindNotFound",
+	string(misas.ErrorKindConflict):        "ErrorKindConflict",
+	string(misas.ErrorKindTimeout):         "ErrorKindTimeout",
+	string(misas.ErrorKindUnauthenticated): "ErrorKindUnauthenticated",
+	string(misas.ErrorKindUnauthorized):    "ErrorKindUnauthorized",
+	string(misas.ErrorKindNotImplemented):  "ErrorKindNotImplemented",
+}
+
+func runErrorKind(pass *analysis.Pass) (any, error) {
+	if pass.Pkg.Path() == misasPath {
+		return nil, nil // declares the error kinds
+	}
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	reported := map[ast.Expr]bool{}
+	ins.Preorder([]ast.Node{(*ast.CallExpr)(nil), (*ast.BasicLit)(nil)}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			tv, ok := pass.TypesInfo.Types[n.Fun]
+			if !ok || !tv.IsType() || !isNamedType(tv.Type, misasPath, "ErrorKind") || len(n.Args) != 1 {
+				return
+			}
+			arg := ast.Unparen(n.Args[0])
+			reported[arg] = true
+
+			value, isConstant := constantString(pass, arg)
+			switch {
+			case !isConstant:
+				pass.Reportf(n.Pos(), "misas.ErrorKind built at runtime, use a misas.ErrorKind constant or declare a package-level ErrorKind")
+			case misasErrorKinds[value] != "":
+				pass.Reportf(n.Pos(), "use misas.%s instead of the literal %q", misasErrorKinds[value], value)
+			}
+		case *ast.BasicLit:
+			if n.Kind != token.STRING || reported[n] || !isNamedType(pass.TypesInfo.TypeOf(n), misasPath, "ErrorKind") {
+				return
+			}
+			if value, _ := constantString(pass, n); misasErrorKinds[value] != "" {
+				pass.Reportf(n.Pos(), "use misas.%s instead of the literal %q", misasErrorKinds[value], value)
+			}
+		}
+	})
+
+	return nil, nil
+}
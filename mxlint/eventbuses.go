@@ -0,0 +1,143 @@
+package mxlint
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// EventBusesAnalyzer reports event buses subscribed to with WithEventHandlers or published on as declared by
+// ProducesEventsOn, without being configured with SystemConf.EventBus. Handlers of unconfigured buses are never
+// registered by the system.
+//
+// Buses are checked in the packages creating a system with mx.NewSystem, against the buses configured and used by the
+// package and its dependencies. Checks are skipped when a bus is configured by a name only known at runtime.
+var EventBusesAnalyzer = &analysis.Analyzer{
+	Name:      "eventbuses",
+	Doc:       "reports event buses used by subsystems without being configured with SystemConf.EventBus",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       runEventBuses,
+	FactTypes: []analysis.Fact{new(eventBusesFact)},
+}
+
+// eventBusesFact records the event buses configured and used by a package.
+type eventBusesFact struct {
+	Configured []string
+	Used       []eventBusUse
+	// Dynamic is set when a bus is configured by a name only known at runtime.
+	Dynamic bool
+}
+
+func (*eventBusesFact) AFact() {}
+
+func (f *eventBusesFact) String() string {
+	used := make([]string, len(f.Used))
+	for i, u := range f.Used {
+		used[i] = u.Name
+	}
+
+	return "eventBuses(configured: " + strings.Join(f.Configured, ", ") + "; used: " + strings.Join(used, ", ") + ")"
+}
+
+type eventBusUse struct {
+	Name   string
+	Method string
+	Pos    string
+}
+
+func runEventBuses(pass *analysis.Pass) (any, error) {
+	if pass.Pkg.Path() == mxPath {
+		return nil, nil // the buses configured by mx itself depend on the systems using it
+	}
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	fact := &eventBusesFact{}
+	var localUses []*ast.CallExpr
+	var systems []*ast.CallExpr
+	ins.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		receiver, method, ok := calledMethod(pass, call, mxPath)
+		if !ok {
+			return
+		}
+
+		switch {
+		case receiver == "" && method == "NewSystem":
+			systems = append(systems, call)
+		case receiver == "SystemConf" && method == "WithSystemEvents":
+			fact.Configured = append(fact.Configured, systemEventBusName(pass, call))
+		case receiver == "SystemConf" && method == "EventBus" && len(call.Args) == 1:
+			if name, isConstant := constantString(pass, call.Args[0]); isConstant {
+				fact.Configured = append(fact.Configured, name)
+			} else {
+				fact.Dynamic = true
+			}
+		case (receiver == "BusinessSubsystemConf" || receiver == "QuerySubsystemConf") &&
+			(method == "WithEventHandlers" || method == "ProducesEventsOn") && len(call.Args) > 0:
+			if name, isConstant := constantString(pass, call.Args[0]); isConstant {
+				fact.Used = append(fact.Used, eventBusUse{Name: name, Method: method, Pos: pass.Fset.Position(methodPos(call)).String()})
+				localUses = append(localUses, call)
+			}
+		}
+	})
+
+	if len(fact.Configured) != 0 || len(fact.Used) != 0 || fact.Dynamic {
+		pass.ExportPackageFact(fact)
+	}
+	if len(systems) == 0 {
+		return nil, nil
+	}
+
+	configured, dynamic := slices.Clone(fact.Configured), fact.Dynamic
+	var importedUses []eventBusUse
+	for _, pf := range pass.AllPackageFacts() {
+		if f, ok := pf.Fact.(*eventBusesFact); ok && pf.Package != pass.Pkg {
+			configured = append(configured, f.Configured...)
+			importedUses = append(importedUses, f.Used...)
+			dynamic = dynamic || f.Dynamic
+		}
+	}
+	if dynamic {
+		return nil, nil
+	}
+
+	for i, use := range fact.Used {
+		if !slices.Contains(configured, use.Name) {
+			pass.Reportf(methodPos(localUses[i]), "event bus %q is never configured, call SystemConf.EventBus(%q)", use.Name, use.Name)
+		}
+	}
+	for _, use := range importedUses {
+		if !slices.Contains(configured, use.Name) {
+			pass.Reportf(systems[0].Pos(), "event bus %q used by %s at %s is never configured, call SystemConf.EventBus(%q)", use.Name, use.Method, use.Pos, use.Name)
+		}
+	}
+
+	return nil, nil
+}
+
+// systemEventBusName returns the name of the event bus configured by SystemConf.WithSystemEvents.
+func systemEventBusName(pass *analysis.Pass, call *ast.CallExpr) string {
+	fn := typeutil.Callee(pass.TypesInfo, call)
+	if c, ok := fn.Pkg().Scope().Lookup("SystemEventBusName").(*types.Const); ok && c.Val().Kind() == constant.String {
+		return constant.StringVal(c.Val())
+	}
+
+	return "mx.system"
+}
+
+// methodPos returns the position of the method called by a call expression, rather than the start of a call chain.
+func methodPos(call *ast.CallExpr) token.Pos {
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+		return sel.Sel.Pos()
+	}
+
+	return call.Pos()
+}
@@ -0,0 +1,131 @@
+package mxlint
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// HandlerAssertionAnalyzer reports command and query handlers registered with WithCommandHandler or WithQueryHandler
+// asserting their message as a type other than the one they are registered for, an assertion bound to fail.
+// Handlers are checked when given as function literals or functions of the package, possibly converted to
+// misas.CommandHandlerFunc or misas.QueryHandlerFunc.
+var HandlerAssertionAnalyzer = &analysis.Analyzer{
+	Name:     "handlerassertion",
+	Doc:      "reports handlers asserting their message as a type other than the one they are registered for",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runHandlerAssertion,
+}
+
+var handlerRegistrations = map[string]struct{ receiver, message string }{
+	"WithCommandHandler": {receiver: "BusinessSubsystemConf", message: "Command"},
+	"WithQueryHandler":   {receiver: "QuerySubsystemConf", message: "Query"},
+}
+
+func runHandlerAssertion(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	funcDecls := map[types.Object]*ast.FuncDecl{}
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil {
+				funcDecls[pass.TypesInfo.Defs[fd.Name]] = fd
+			}
+		}
+	}
+
+	ins.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		receiver, method, ok := calledMethod(pass, call, mxPath)
+		registration, isRegistration := handlerRegistrations[method]
+		if !ok || !isRegistration || receiver != registration.receiver || len(call.Args) != 2 {
+			return
+		}
+
+		registered := pass.TypesInfo.TypeOf(call.Args[0])
+		if registered == nil || types.IsInterface(registered) {
+			return
+		}
+		fnType, body := handlerFunc(pass, call.Args[1], funcDecls)
+		if body == nil {
+			return
+		}
+		param := messageParam(pass, fnType, registration.message)
+		if param == nil {
+			return
+		}
+
+		ast.Inspect(body, func(n ast.Node) bool {
+			ta, ok := n.(*ast.TypeAssertExpr)
+			if !ok || ta.Type == nil {
+				return true
+			}
+			if x, isIdent := ast.Unparen(ta.X).(*ast.Ident); !isIdent || pass.TypesInfo.Uses[x] != param {
+				return true
+			}
+
+			asserted := pass.TypesInfo.TypeOf(ta.Type)
+			if asserted == nil || types.IsInterface(asserted) || matchesRegisteredType(asserted, registered) {
+				return true
+			}
+			pass.Reportf(ta.Type.Pos(), "handler registered for %s asserts its %s as %s",
+				types.TypeString(registered, types.RelativeTo(pass.Pkg)),
+				registration.message,
+				types.TypeString(asserted, types.RelativeTo(pass.Pkg)),
+			)
+
+			return true
+		})
+	})
+
+	return nil, nil
+}
+
+// handlerFunc returns the signature and body of a handler given as a function literal or a function of the package,
+// possibly converted to a handler func type.
+func handlerFunc(pass *analysis.Pass, expr ast.Expr, funcDecls map[types.Object]*ast.FuncDecl) (*ast.FuncType, *ast.BlockStmt) {
+	expr = ast.Unparen(expr)
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 && pass.TypesInfo.Types[call.Fun].IsType() {
+		expr = ast.Unparen(call.Args[0])
+	}
+
+	switch e := expr.(type) {
+	case *ast.FuncLit:
+		return e.Type, e.Body
+	case *ast.Ident:
+		if fd, ok := funcDecls[pass.TypesInfo.Uses[e]]; ok {
+			return fd.Type, fd.Body
+		}
+	}
+
+	return nil, nil
+}
+
+// messageParam returns the parameter of a handler receiving the message.
+func messageParam(pass *analysis.Pass, fnType *ast.FuncType, message string) types.Object {
+	for _, field := range fnType.Params.List {
+		if !isNamedType(pass.TypesInfo.TypeOf(field.Type), misasPath, message) {
+			continue
+		}
+		for _, name := range field.Names {
+			return pass.TypesInfo.Defs[name]
+		}
+	}
+
+	return nil
+}
+
+// matchesRegisteredType reports whether a handler registered with a prototype may receive a message of a type.
+func matchesRegisteredType(t types.Type, registered types.Type) bool {
+	base := func(t types.Type) types.Type {
+		if p, ok := types.Unalias(t).(*types.Pointer); ok {
+			return p.Elem()
+		}
+		return t
+	}
+
+	return types.Identical(base(t), base(registered))
+}
@@ -0,0 +1,78 @@
+// Package mxlint provides analyzers reporting common mistakes in the use of misas and mx:
+//   - resultpayload: errors returned in the Payload of a command or query result instead of its Error;
+//   - typenames: TypeName methods of messages returning a type name already declared by another message;
+//   - eventbuses: event buses subscribed to or published on without being configured by SystemConf.EventBus;
+//   - handlerassertion: handlers asserting their message as a type other than the one they are registered for;
+//   - errorkind: misas.ErrorKind values built at runtime or spelled as literals instead of the misas constants.
+//
+// The analyzers are run by the mxlint command, or by any driver of golang.org/x/tools/go/analysis:
+//
+//	go run github.com/morebec/misas/cmd/mxlint ./...
+package mxlint
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const (
+	misasPath = "github.com/morebec/misas/misas"
+	mxPath    = "github.com/morebec/misas/mx"
+)
+
+// Analyzers lists the analyzers of the package.
+var Analyzers = []*analysis.Analyzer{
+	ResultPayloadAnalyzer,
+	TypeNamesAnalyzer,
+	EventBusesAnalyzer,
+	HandlerAssertionAnalyzer,
+	ErrorKindAnalyzer,
+}
+
+// isNamedType reports whether t is the named type pkgPath.name.
+func isNamedType(t types.Type, pkgPath string, name string) bool {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+// calledMethod returns the receiver type name and the name of the method of pkgPath called by a call expression.
+func calledMethod(pass *analysis.Pass, call *ast.CallExpr, pkgPath string) (receiver string, method string, ok bool) {
+	fn, isFunc := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !isFunc || fn.Pkg() == nil || fn.Pkg().Path() != pkgPath {
+		return "", "", false
+	}
+	recv := fn.Signature().Recv()
+	if recv == nil {
+		return "", fn.Name(), true
+	}
+
+	t := recv.Type()
+	if p, isPointer := t.(*types.Pointer); isPointer {
+		t = p.Elem()
+	}
+	n, isNamed := types.Unalias(t).(*types.Named)
+	if !isNamed {
+		return "", "", false
+	}
+
+	return n.Obj().Name(), fn.Name(), true
+}
+
+// constantString returns the value of a constant string expression.
+func constantString(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
@@ -0,0 +1,33 @@
+package mxlint_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/morebec/misas/mxlint"
+)
+
+func TestAnalyzers(t *testing.T) {
+	testdata := analysistest.TestData()
+
+	t.Run("GIVEN errors in result payloads WHEN analyzing THEN should report them", func(t *testing.T) {
+		analysistest.Run(t, testdata, mxlint.ResultPayloadAnalyzer, "resultpayload")
+	})
+
+	t.Run("GIVEN type names declared twice WHEN analyzing THEN should report them across packages", func(t *testing.T) {
+		analysistest.Run(t, testdata, mxlint.TypeNamesAnalyzer, "typenames/orders", "typenames/sales")
+	})
+
+	t.Run("GIVEN unconfigured event buses WHEN analyzing THEN should report them in the system package", func(t *testing.T) {
+		analysistest.Run(t, testdata, mxlint.EventBusesAnalyzer, "eventbuses/app")
+	})
+
+	t.Run("GIVEN handlers asserting the wrong message WHEN analyzing THEN should report them", func(t *testing.T) {
+		analysistest.Run(t, testdata, mxlint.HandlerAssertionAnalyzer, "handlerassertion")
+	})
+
+	t.Run("GIVEN error kinds built at runtime or spelled as literals WHEN analyzing THEN should report them", func(t *testing.T) {
+		analysistest.Run(t, testdata, mxlint.ErrorKindAnalyzer, "errorkind")
+	})
+}
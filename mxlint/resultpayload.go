@@ -0,0 +1,88 @@
+package mxlint
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// ResultPayloadAnalyzer reports errors set as the Payload of a misas.CommandResult or misas.QueryResult. Buses, hooks
+// and callers only see failures reported in Error, an error in Payload being taken for a successful result.
+var ResultPayloadAnalyzer = &analysis.Analyzer{
+	Name:     "resultpayload",
+	Doc:      "reports errors returned in the Payload of a misas.CommandResult or misas.QueryResult instead of its Error",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runResultPayload,
+}
+
+var errorType = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+func runResultPayload(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	ins.Preorder([]ast.Node{(*ast.CompositeLit)(nil), (*ast.AssignStmt)(nil)}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			result := resultTypeName(pass.TypesInfo.TypeOf(n))
+			if result == "" {
+				return
+			}
+			for _, elt := range n.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if key, isIdent := kv.Key.(*ast.Ident); ok && isIdent && key.Name == "Payload" {
+					checkResultPayload(pass, result, kv.Value)
+				}
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return
+			}
+			for i, lhs := range n.Lhs {
+				sel, ok := lhs.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != "Payload" {
+					continue
+				}
+				if result := resultTypeName(pass.TypesInfo.TypeOf(sel.X)); result != "" {
+					checkResultPayload(pass, result, n.Rhs[i])
+				}
+			}
+		}
+	})
+
+	return nil, nil
+}
+
+// resultTypeName returns the name of the misas result type of t, if any.
+func resultTypeName(t types.Type) string {
+	if t == nil {
+		return ""
+	}
+	if p, ok := types.Unalias(t).(*types.Pointer); ok {
+		t = p.Elem()
+	}
+
+	for _, name := range []string{"CommandResult", "QueryResult"} {
+		if isNamedType(t, misasPath, name) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func checkResultPayload(pass *analysis.Pass, result string, payload ast.Expr) {
+	t := pass.TypesInfo.TypeOf(payload)
+	if t == nil {
+		return
+	}
+	if b, isBasic := t.(*types.Basic); isBasic && b.Kind() == types.UntypedNil {
+		return
+	}
+
+	if types.Implements(t, errorType) {
+		pass.Reportf(payload.Pos(), "misas.%s.Payload holds an error, set it as the Error of the result instead", result)
+	}
+}
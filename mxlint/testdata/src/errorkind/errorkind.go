@@ -0,0 +1,20 @@
+package errorkind
+
+import "github.com/morebec/misas/misas"
+
+var ErrorKindOutOfStock misas.ErrorKind = "out_of_stock"
+
+var ErrorKindMissing misas.ErrorKind = "not_found" // want `use misas.ErrorKindNotFound instead of the literal "not_found"`
+
+func kinds(kind string, err error) []misas.ErrorKind {
+	_ = misas.NewError("conflict") // want `use misas.ErrorKindConflict instead of the literal "conflict"`
+	_ = misas.ErrorHasKind(err, misas.ErrorKindNotFound)
+	_ = misas.NewError(misas.ErrorKind("not_found")) // want `use misas.ErrorKindNotFound instead of the literal "not_found"`
+
+	return []misas.ErrorKind{
+		misas.ErrorKind(kind),          // want `misas.ErrorKind built at runtime, use a misas.ErrorKind constant or declare a package-level ErrorKind`
+		misas.ErrorKind("out_" + kind), // want `misas.ErrorKind built at runtime`
+		misas.ErrorKind("out_of_stock"),
+		ErrorKindOutOfStock,
+	}
+}
@@ -0,0 +1,19 @@
+package app // want package:`eventBuses\(configured: mx.system, orders; used: shipments, orders\)`
+
+import (
+	"github.com/morebec/misas/mx"
+
+	"eventbuses/billing"
+)
+
+func main() {
+	system := mx.NewSystem("shop").WithSystemEvents() // want `event bus "invoices" used by ProducesEventsOn at .*billing.go:11:.* is never configured, call SystemConf.EventBus\("invoices"\)`
+	system.EventBus("orders")
+
+	system.WithBusinessSubsystem(billing.Subsystem())
+	system.WithBusinessSubsystem(
+		mx.NewBusinessSubsystem("shipping").
+			WithEventHandlers("orders").
+			WithEventHandlers("shipments"), // want `event bus "shipments" is never configured, call SystemConf.EventBus\("shipments"\)`
+	)
+}
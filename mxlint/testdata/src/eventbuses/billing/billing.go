@@ -0,0 +1,12 @@
+package billing
+
+import "github.com/morebec/misas/mx"
+
+const InvoicesBus mx.EventBusName = "invoices"
+
+func Subsystem() *mx.BusinessSubsystemConf {
+	return mx.NewBusinessSubsystem("billing").
+		WithEventHandlers("orders").
+		WithEventHandlers(mx.SystemEventBusName).
+		ProducesEventsOn(InvoicesBus)
+}
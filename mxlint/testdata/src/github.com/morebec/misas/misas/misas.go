@@ -0,0 +1,52 @@
+// Package misas stubs the declarations of misas used by the analyzers.
+package misas
+
+import "context"
+
+type CommandTypeName string
+type Command interface{ TypeName() CommandTypeName }
+type CommandResult struct {
+	Payload any
+	Error   error
+}
+type CommandHandler interface {
+	Handle(context.Context, Command) CommandResult
+}
+type CommandHandlerFunc func(context.Context, Command) CommandResult
+
+func (f CommandHandlerFunc) Handle(ctx context.Context, c Command) CommandResult { return f(ctx, c) }
+
+type QueryTypeName string
+type Query interface{ TypeName() QueryTypeName }
+type QueryResult struct {
+	Payload any
+	Error   error
+}
+type QueryHandler interface {
+	Handle(context.Context, Query) QueryResult
+}
+type QueryHandlerFunc func(context.Context, Query) QueryResult
+
+func (f QueryHandlerFunc) Handle(ctx context.Context, q Query) QueryResult { return f(ctx, q) }
+
+type EventTypeName string
+type Event interface{ TypeName() EventTypeName }
+type EventHandler interface {
+	Handle(context.Context, Event) error
+}
+type EventBus interface {
+	Publish(context.Context, Event) error
+}
+
+type ErrorKind string
+
+var ErrorKindNotFound ErrorKind = "not_found"
+var ErrorKindConflict ErrorKind = "conflict"
+
+type Error struct{ kind ErrorKind }
+
+func NewError(kind ErrorKind) Error { return Error{kind: kind} }
+
+func (e Error) Error() string { return string(e.kind) }
+
+func ErrorHasKind(err error, kind ErrorKind) bool { return false }
@@ -0,0 +1,46 @@
+// Package mx stubs the declarations of mx used by the analyzers.
+package mx
+
+import "github.com/morebec/misas/misas"
+
+type EventBusName string
+
+const SystemEventBusName EventBusName = "mx.system"
+
+type SystemConf struct{}
+
+func NewSystem(name string) *SystemConf { return &SystemConf{} }
+
+func (sc *SystemConf) EventBus(name EventBusName) misas.EventBus { return nil }
+
+func (sc *SystemConf) WithSystemEvents() *SystemConf { return sc }
+
+func (sc *SystemConf) WithBusinessSubsystem(c *BusinessSubsystemConf) *SystemConf { return sc }
+
+type BusinessSubsystemConf struct{}
+
+func NewBusinessSubsystem(name string) *BusinessSubsystemConf { return &BusinessSubsystemConf{} }
+
+func (bc *BusinessSubsystemConf) WithCommandHandler(ct misas.Command, h misas.CommandHandler) *BusinessSubsystemConf {
+	return bc
+}
+
+func (bc *BusinessSubsystemConf) WithEventHandlers(name EventBusName, handlers ...misas.EventHandler) *BusinessSubsystemConf {
+	return bc
+}
+
+func (bc *BusinessSubsystemConf) ProducesEventsOn(name EventBusName, events ...misas.Event) *BusinessSubsystemConf {
+	return bc
+}
+
+type QuerySubsystemConf struct{}
+
+func NewQuerySubsystem(name string) *QuerySubsystemConf { return &QuerySubsystemConf{} }
+
+func (qc *QuerySubsystemConf) WithQueryHandler(qt misas.Query, h misas.QueryHandler) *QuerySubsystemConf {
+	return qc
+}
+
+func (qc *QuerySubsystemConf) WithEventHandlers(name EventBusName, handlers ...misas.EventHandler) *QuerySubsystemConf {
+	return qc
+}
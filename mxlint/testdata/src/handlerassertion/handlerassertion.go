@@ -0,0 +1,51 @@
+package handlerassertion
+
+import (
+	"context"
+
+	"github.com/morebec/misas/misas"
+	"github.com/morebec/misas/mx"
+)
+
+type AdjustStock struct{ Quantity int }
+
+func (AdjustStock) TypeName() misas.CommandTypeName { return "inventory.adjust_stock" }
+
+type ReserveStock struct{ Quantity int }
+
+func (ReserveStock) TypeName() misas.CommandTypeName { return "inventory.reserve_stock" }
+
+type GetStock struct{}
+
+func (GetStock) TypeName() misas.QueryTypeName { return "inventory.get_stock" }
+
+type GetReservations struct{}
+
+func (GetReservations) TypeName() misas.QueryTypeName { return "inventory.get_reservations" }
+
+func handleReserveStock(ctx context.Context, cmd misas.Command) misas.CommandResult {
+	reserve := cmd.(ReserveStock) // want `handler registered for AdjustStock asserts its Command as ReserveStock`
+	return misas.CommandResult{Payload: reserve.Quantity}
+}
+
+func configure() {
+	mx.NewBusinessSubsystem("inventory").
+		WithCommandHandler(AdjustStock{}, misas.CommandHandlerFunc(func(ctx context.Context, cmd misas.Command) misas.CommandResult {
+			adjust := cmd.(ReserveStock) // want `handler registered for AdjustStock asserts its Command as ReserveStock`
+			return misas.CommandResult{Payload: adjust.Quantity}
+		})).
+		WithCommandHandler(&ReserveStock{}, misas.CommandHandlerFunc(func(ctx context.Context, cmd misas.Command) misas.CommandResult {
+			if reserve, ok := cmd.(*ReserveStock); ok {
+				return misas.CommandResult{Payload: reserve.Quantity}
+			}
+			_ = cmd.(interface{ Validate() error })
+			return misas.CommandResult{Payload: cmd.(ReserveStock).Quantity}
+		})).
+		WithCommandHandler(AdjustStock{}, misas.CommandHandlerFunc(handleReserveStock))
+
+	mx.NewQuerySubsystem("inventory_reporting").
+		WithQueryHandler(GetStock{}, misas.QueryHandlerFunc(func(ctx context.Context, q misas.Query) misas.QueryResult {
+			_ = q.(GetReservations) // want `handler registered for GetStock asserts its Query as GetReservations`
+			return misas.QueryResult{}
+		}))
+}
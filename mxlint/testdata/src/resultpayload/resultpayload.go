@@ -0,0 +1,31 @@
+package resultpayload
+
+import (
+	"context"
+	"errors"
+
+	"github.com/morebec/misas/misas"
+)
+
+type outOfStockError struct{}
+
+func (*outOfStockError) Error() string { return "out of stock" }
+
+func handle(ctx context.Context, cmd misas.Command) misas.CommandResult {
+	if cmd == nil {
+		return misas.CommandResult{Payload: errors.New("no command")} // want `misas.CommandResult.Payload holds an error, set it as the Error of the result instead`
+	}
+	if ctx == nil {
+		return misas.CommandResult{Payload: &outOfStockError{}} // want `misas.CommandResult.Payload holds an error`
+	}
+
+	return misas.CommandResult{Payload: "ok", Error: errors.New("failed")}
+}
+
+func query(err error) *misas.QueryResult {
+	result := &misas.QueryResult{Payload: nil}
+	result.Payload = err // want `misas.QueryResult.Payload holds an error`
+	result.Payload = 42
+
+	return result
+}
@@ -0,0 +1,21 @@
+package orders // want package:`typeNames\(sales.order_placed, sales.place_order\)`
+
+import "github.com/morebec/misas/misas"
+
+const orderPlacedTypeName misas.EventTypeName = "sales.order_placed"
+
+type OrderPlaced struct{}
+
+func (OrderPlaced) TypeName() misas.EventTypeName { return orderPlacedTypeName }
+
+type PlaceOrder struct{}
+
+func (PlaceOrder) TypeName() misas.CommandTypeName { return "sales.place_order" }
+
+type OrderReplaced struct{}
+
+func (*OrderReplaced) TypeName() misas.EventTypeName { return "sales.order_placed" } // want `type name "sales.order_placed" of event orders.OrderReplaced is already declared by event orders.OrderPlaced at .*orders.go:9:`
+
+type dynamic struct{ name string }
+
+func (d dynamic) TypeName() misas.EventTypeName { return misas.EventTypeName(d.name) }
@@ -0,0 +1,17 @@
+package sales // want package:`typeNames\(sales.cancel_order\)`
+
+import (
+	"github.com/morebec/misas/misas"
+
+	"typenames/orders"
+)
+
+var _ = orders.OrderPlaced{}
+
+type PlaceOrder struct{}
+
+func (PlaceOrder) TypeName() misas.CommandTypeName { return "sales.place_order" } // want `type name "sales.place_order" of command sales.PlaceOrder is already declared by command orders.PlaceOrder at .*orders.go:13:`
+
+type CancelOrder struct{}
+
+func (CancelOrder) TypeName() misas.CommandTypeName { return "sales.cancel_order" }
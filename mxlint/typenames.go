@@ -0,0 +1,137 @@
+package mxlint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// TypeNamesAnalyzer reports TypeName methods of commands, queries and events returning a constant type name already
+// returned by another message type of the package or of its dependencies. Messages sharing a type name cannot both be
+// registered, and are confused once serialized. Test files, declaring successive versions of messages, are ignored.
+var TypeNamesAnalyzer = &analysis.Analyzer{
+	Name:      "typenames",
+	Doc:       "reports message types declaring the type name of another message type",
+	Run:       runTypeNames,
+	FactTypes: []analysis.Fact{new(typeNamesFact)},
+}
+
+// typeNamesFact lists the type names declared by the messages of a package.
+type typeNamesFact struct {
+	TypeNames []declaredTypeName
+}
+
+func (*typeNamesFact) AFact() {}
+
+func (f *typeNamesFact) String() string {
+	names := make([]string, len(f.TypeNames))
+	for i, tn := range f.TypeNames {
+		names[i] = tn.Name
+	}
+
+	return "typeNames(" + strings.Join(names, ", ") + ")"
+}
+
+// declaredTypeName is a type name returned by the TypeName method of a message type.
+type declaredTypeName struct {
+	Name    string
+	Kind    string
+	Message string
+	Pos     string
+}
+
+func (tn declaredTypeName) String() string {
+	return fmt.Sprintf("%s %s at %s", tn.Kind, tn.Message, tn.Pos)
+}
+
+var typeNameKinds = map[string]string{
+	"CommandTypeName": "command",
+	"QueryTypeName":   "query",
+	"EventTypeName":   "event",
+}
+
+func runTypeNames(pass *analysis.Pass) (any, error) {
+	declared := map[string]declaredTypeName{}
+	for _, pf := range pass.AllPackageFacts() {
+		if f, ok := pf.Fact.(*typeNamesFact); ok && pf.Package != pass.Pkg {
+			for _, tn := range f.TypeNames {
+				declared[tn.Name] = tn
+			}
+		}
+	}
+
+	fact := &typeNamesFact{}
+	for _, file := range pass.Files {
+		if strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go") {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			tn, ok := messageTypeName(pass, fd)
+			if !ok {
+				continue
+			}
+
+			if first, exists := declared[tn.Name]; exists {
+				pass.Reportf(fd.Name.Pos(), "type name %q of %s %s is already declared by %s", tn.Name, tn.Kind, tn.Message, first)
+				continue
+			}
+			declared[tn.Name] = tn
+			fact.TypeNames = append(fact.TypeNames, tn)
+		}
+	}
+
+	if len(fact.TypeNames) != 0 {
+		pass.ExportPackageFact(fact)
+	}
+
+	return nil, nil
+}
+
+// messageTypeName returns the type name returned by a TypeName method when it returns a constant.
+func messageTypeName(pass *analysis.Pass, fd *ast.FuncDecl) (declaredTypeName, bool) {
+	if fd.Recv == nil || fd.Name.Name != "TypeName" || fd.Body == nil || len(fd.Body.List) != 1 {
+		return declaredTypeName{}, false
+	}
+	fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func)
+	if !ok {
+		return declaredTypeName{}, false
+	}
+	sig := fn.Signature()
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return declaredTypeName{}, false
+	}
+
+	var kind string
+	for typeName, k := range typeNameKinds {
+		if isNamedType(sig.Results().At(0).Type(), misasPath, typeName) {
+			kind = k
+		}
+	}
+	ret, ok := fd.Body.List[0].(*ast.ReturnStmt)
+	if kind == "" || !ok || len(ret.Results) != 1 {
+		return declaredTypeName{}, false
+	}
+	name, ok := constantString(pass, ret.Results[0])
+	if !ok {
+		return declaredTypeName{}, false
+	}
+
+	recv := sig.Recv().Type()
+	if p, isPointer := recv.(*types.Pointer); isPointer {
+		recv = p.Elem()
+	}
+
+	return declaredTypeName{
+		Name:    name,
+		Kind:    kind,
+		Message: types.TypeString(recv, (*types.Package).Name),
+		Pos:     pass.Fset.Position(fd.Name.Pos()).String(),
+	}, true
+}